@@ -7,12 +7,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
 )
 
+// Looks up executables in $PATH. Overridden by tests.
+var lookPath = exec.LookPath
+
 // AMP reads an AMP HTML document from r and validates it by running the amphtml-validator program,
 // which must be present in $PATH. Issues identified by the validator are parsed and returned.
 // If the returned error is non-nil, an issue occurred in the validation process.
@@ -39,40 +50,327 @@ import (
 //
 // There's more discussion at https://github.com/ampproject/amphtml/issues/1968.
 func AMP(ctx context.Context, r io.Reader) ([]Issue, error) {
-	issues, err := runAMP(ctx, []string{"-"}, r)
-	return issues["-"], err
+	return AMPWithOptions(ctx, r, nil)
+}
+
+// AMPWithOptions is similar to AMP but accepts additional options.
+// opts may be nil.
+func AMPWithOptions(ctx context.Context, r io.Reader, opts *Options) ([]Issue, error) {
+	ctx, cancel := opts.ampContext(ctx)
+	defer cancel()
+
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	in, extra, err := opts.prepareInput(in)
+	if err != nil {
+		return nil, err
+	}
+	fileIssues, err := runAMP(ctx, opts, opts.ampFormat(in), []string{"-"}, bytes.NewReader(in))
+	issues := opts.collapseAMPIssues(fileIssues["-"])
+	setSource(issues, AMPSource)
+	issues = append(issues, extra...)
+	opts.addContextWindows(issues, in)
+	normalizeColumns(issues)
+	return issues, err
 }
 
 // AMPFiles runs amphtml-validator to validate multiple AMP HTML files at the supplied paths.
-// The returned map is keyed by the filenames from the paths argument.
+// The returned map is keyed by the filenames from the paths argument, which are also used
+// to set each issue's File field. See RelativizeResults. If some paths aren't readable
+// regular files, the others are still validated and an *AMPPathError is returned.
 //
 // AMPFiles may be much faster than AMP when validating multiple files, since the
 // WebAssembly-based amphtml-validator can take a substantial amount of time to start:
 // https://github.com/ampproject/amphtml/issues/37585.
 func AMPFiles(ctx context.Context, paths []string) (map[string][]Issue, error) {
-	return runAMP(ctx, paths, nil)
+	return AMPFilesWithOptions(ctx, paths, nil)
 }
 
-// runAMP runs the amphtml-validator command with the provided filename arguments and stdin
-// (possibly nil) and parses the results. The returned map is keyed by filename (or "-" if
-// it was passed to tell the validator to read input from stdin).
-func runAMP(ctx context.Context, fileArgs []string, stdin io.Reader) (map[string][]Issue, error) {
-	const exe = "amphtml-validator"
-	if _, err := exec.LookPath(exe); err != nil {
-		return nil, err
+// AMPFilesWithOptions is similar to AMPFiles but accepts additional options.
+// opts may be nil.
+func AMPFilesWithOptions(ctx context.Context, paths []string, opts *Options) (map[string][]Issue, error) {
+	ctx, cancel := opts.ampContext(ctx)
+	defer cancel()
+
+	// Check the paths first, since amphtml-validator reports unhelpful errors for e.g. directories.
+	var good []string
+	var pathErr *AMPPathError
+	for _, p := range paths {
+		if err := checkAMPPath(p); err != nil {
+			if pathErr == nil {
+				pathErr = &AMPPathError{Errs: make(map[string]error)}
+			}
+			pathErr.Errs[p] = err
+		} else {
+			good = append(good, p)
+		}
+	}
+
+	fileIssues := make(map[string][]Issue)
+	var err error
+	if len(good) > 0 {
+		if opts != nil && opts.FailFast {
+			fileIssues, err = runAMPFailFast(ctx, good, opts)
+		} else {
+			fileIssues, err = runAMPByFormat(ctx, good, opts)
+		}
+	}
+	if fileIssues == nil {
+		fileIssues = make(map[string][]Issue)
+	}
+	if pathErr != nil && opts != nil && opts.SkipBadAMPPaths {
+		for p, perr := range pathErr.Errs {
+			fileIssues[p] = []Issue{{Severity: Warning, Message: perr.Error(), Code: unreadableFileCode}}
+		}
+		pathErr = nil
+	}
+	for p, issues := range fileIssues {
+		issues = opts.collapseAMPIssues(issues)
+		for i := range issues {
+			issues[i].File = p
+		}
+		setSource(issues, AMPSource)
+		normalizeColumns(issues)
+		fileIssues[p] = issues
+	}
+	if opts != nil && opts.ContextLines > 0 {
+		for p, issues := range fileIssues {
+			if b, rerr := ioutil.ReadFile(p); rerr == nil {
+				opts.addContextWindows(issues, b)
+			}
+		}
+	}
+	if err == nil && pathErr != nil {
+		err = pathErr
+	}
+	return fileIssues, err
+}
+
+// unreadableFileCode is used for issues reported in place of an AMPPathError
+// when Options.SkipBadAMPPaths is true.
+const unreadableFileCode = "unreadable-file"
+
+// AMPPathError is returned by AMPFiles if some of the supplied paths couldn't be validated,
+// e.g. because they don't exist or are directories. Results are still returned for the
+// remaining paths.
+type AMPPathError struct {
+	// Errs describes the problem with each bad path.
+	Errs map[string]error
+}
+
+func (e *AMPPathError) Error() string {
+	paths := make([]string, 0, len(e.Errs))
+	for p := range e.Errs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	msgs := make([]string, len(paths))
+	for i, p := range paths {
+		msgs[i] = e.Errs[p].Error()
+	}
+	return fmt.Sprintf("can't validate %d path(s): %s", len(paths), strings.Join(msgs, "; "))
+}
+
+// AMPExecError is returned by AMP and AMPFiles if amphtml-validator failed without printing
+// any results, e.g. because Node or the validator's WebAssembly module couldn't be loaded.
+type AMPExecError struct {
+	// ExitCode is amphtml-validator's exit code.
+	ExitCode int
+	// Stderr contains the message that amphtml-validator wrote to stderr.
+	Stderr string
+}
+
+func (e *AMPExecError) Error() string {
+	return fmt.Sprintf("amphtml-validator failed with exit code %d: %s", e.ExitCode, e.Stderr)
+}
+
+// checkAMPPath returns an error if p isn't a readable regular file.
+func checkAMPPath(p string) error {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%v is a directory", p)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%v is not a regular file", p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ErrNoAMPVerdict is returned by AMP and AMPFiles if amphtml-validator reported an
+// UNKNOWN status without any errors for all files, i.e. it neither passed nor failed them.
+var ErrNoAMPVerdict = errors.New("amphtml-validator reported unknown status without errors")
+
+// AMPFormat describes the format of an AMP document.
+// The format determines which rules are applied by amphtml-validator.
+type AMPFormat string
+
+const (
+	// AMPWebsite is a standard AMP HTML page, e.g. <html ⚡>.
+	AMPWebsite AMPFormat = "AMP"
+	// AMPStory is an AMP HTML page containing an <amp-story> element.
+	// Stories are validated as AMPWebsite documents: amphtml-validator applies
+	// its story-specific rules to documents that load the amp-story extension.
+	AMPStory AMPFormat = "AMP_STORY"
+	// AMPAds is an AMPHTML ad, e.g. <html ⚡4ads>.
+	AMPAds AMPFormat = "AMP4ADS"
+	// AMPEmail is an AMP email, e.g. <html ⚡4email>.
+	AMPEmail AMPFormat = "AMP4EMAIL"
+)
+
+// validatorFormat returns the value to pass to amphtml-validator's --html_format flag for f.
+func (f AMPFormat) validatorFormat() string {
+	if f == AMPStory || f == "" {
+		return string(AMPWebsite)
+	}
+	return string(f)
+}
+
+// detectAMPFormat returns the format of the AMP document doc based on the attributes
+// of its <html> element and the presence of an <amp-story> element.
+func detectAMPFormat(doc []byte) AMPFormat {
+	for _, t := range tokenizeLines(doc) {
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		switch t.Data {
+		case "html":
+			for _, a := range t.Attr {
+				switch a.Key {
+				case "⚡4ads", "amp4ads":
+					return AMPAds
+				case "⚡4email", "amp4email":
+					return AMPEmail
+				}
+			}
+		case "amp-story":
+			return AMPStory
+		}
+	}
+	return AMPWebsite
+}
+
+// ampFormat returns o.AMPFormat if set or the format detected from doc otherwise.
+func (o *Options) ampFormat(doc []byte) AMPFormat {
+	if o != nil && o.AMPFormat != "" {
+		return o.AMPFormat
+	}
+	return detectAMPFormat(doc)
+}
+
+// fileAMPFormat is similar to ampFormat but reads the document from the file at p.
+// AMPWebsite is returned if the file can't be read (amphtml-validator will report the failure).
+func (o *Options) fileAMPFormat(p string) AMPFormat {
+	if o != nil && o.AMPFormat != "" {
+		return o.AMPFormat
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return AMPWebsite
+	}
+	return detectAMPFormat(b)
+}
+
+// runAMPByFormat is similar to runAMP but groups paths by format, running
+// amphtml-validator once for each format. The first error is returned.
+func runAMPByFormat(ctx context.Context, paths []string, opts *Options) (map[string][]Issue, error) {
+	var formats []string // validator formats in order of first appearance
+	groups := make(map[string][]string)
+	for _, p := range paths {
+		f := opts.fileAMPFormat(p).validatorFormat()
+		if _, ok := groups[f]; !ok {
+			formats = append(formats, f)
+		}
+		groups[f] = append(groups[f], p)
+	}
+	if len(formats) == 1 {
+		return runAMP(ctx, opts, AMPFormat(formats[0]), paths, nil)
+	}
+
+	all := make(map[string][]Issue)
+	var firstErr error
+	for _, f := range formats {
+		fileIssues, err := runAMP(ctx, opts, AMPFormat(f), groups[f], nil)
+		for fn, issues := range fileIssues {
+			all[fn] = issues
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return all, firstErr
+}
+
+// runAMPFailFast is similar to runAMP but validates each of paths individually,
+// stopping with ErrAborted after the first file containing an error.
+func runAMPFailFast(ctx context.Context, paths []string, opts *Options) (map[string][]Issue, error) {
+	all := make(map[string][]Issue)
+	for i, p := range paths {
+		fileIssues, err := runAMP(ctx, opts, opts.fileAMPFormat(p), []string{p}, nil)
+		for fn, issues := range fileIssues {
+			all[fn] = issues
+		}
+		if err != nil {
+			return all, err
+		}
+		if hasErrors(fileIssues[p]) && i < len(paths)-1 {
+			return all, ErrAborted
+		}
+	}
+	return all, nil
+}
+
+// runAMP runs the amphtml-validator command with the provided format, filename arguments,
+// and stdin (possibly nil) and parses the results. The returned map is keyed by filename
+// (or "-" if it was passed to tell the validator to read input from stdin).
+// opts.AMPNode and opts.AMPEnv are used to configure the command.
+func runAMP(ctx context.Context, opts *Options, format AMPFormat, fileArgs []string, stdin io.Reader) (
+	map[string][]Issue, error) {
+	exe := opts.ampValidatorPath()
+	if exe == "" {
+		var err error
+		if exe, err = lookPath("amphtml-validator"); err != nil {
+			return nil, err
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"--format=json", "--html_format=" + format.validatorFormat()}, fileArgs...)
+	if opts != nil && opts.AMPNode != "" {
+		// amphtml-validator is a Node script, so pass it to the requested runtime.
+		args = append([]string{exe}, args...)
+		exe = opts.AMPNode
+	}
+	cmd := exec.CommandContext(ctx, exe, args...)
+	if opts != nil && len(opts.AMPEnv) > 0 {
+		cmd.Env = append(os.Environ(), opts.AMPEnv...)
 	}
-	var stdout bytes.Buffer
-	cmd := exec.CommandContext(ctx, exe, append([]string{"--format=json"}, fileArgs...)...)
 	cmd.Stdin = stdin
 	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
 
 	// amphtml-validator appears to exit with 1 if it identifies errors (but not just warnings).
 	// Only report other errors here.
 	runErr := cmd.Run()
+	if err := ctx.Err(); err != nil {
+		return nil, err // the process was killed
+	}
 	if runErr != nil {
-		if _, ok := runErr.(*exec.ExitError); !ok {
+		exitErr, ok := runErr.(*exec.ExitError)
+		if !ok {
 			return nil, runErr
 		}
+		// Report startup failures directly rather than failing to parse empty output.
+		if msg := strings.TrimSpace(stderr.String()); len(bytes.TrimSpace(stdout.Bytes())) == 0 && msg != "" {
+			return nil, &AMPExecError{ExitCode: exitErr.ExitCode(), Stderr: msg}
+		}
 	}
 
 	// amphtml-validator prints a JSON object that maps from the filenames that were passed
@@ -96,6 +394,7 @@ func runAMP(ctx context.Context, fileArgs []string, stdin io.Reader) (map[string
 	}
 
 	allPassed := true
+	allUnknown := len(out) > 0 // true if no verdict was given for any file
 	var allIssues []Issue
 	fileIssues := make(map[string][]Issue)
 	for fn, res := range out {
@@ -124,12 +423,19 @@ func runAMP(ctx context.Context, fileArgs []string, stdin io.Reader) (map[string
 
 			issues = append(issues, is)
 		}
-		fileIssues[fn] = issues
+		fileIssues[matchAMPPath(fileArgs, fn)] = issues
 		allIssues = append(allIssues, issues...)
 
 		if res.Status != "PASS" {
 			allPassed = false
 		}
+		if res.Status != "UNKNOWN" || len(issues) > 0 {
+			allUnknown = false
+		}
+	}
+
+	if allUnknown {
+		return fileIssues, ErrNoAMPVerdict
 	}
 
 	if allPassed && runErr != nil {
@@ -137,3 +443,69 @@ func runAMP(ctx context.Context, fileArgs []string, stdin io.Reader) (map[string
 	}
 	return fileIssues, checkResponse(allPassed, allIssues)
 }
+
+// matchAMPPath returns the entry from fileArgs corresponding to key, a filename reported
+// by amphtml-validator. On Windows, the Node.js-based validator may report paths with
+// different separators or case than the ones that were passed to it, so paths are compared
+// exactly, then after cleaning them and converting backslashes to slashes, and finally
+// case-insensitively. key is returned if no match is found.
+func matchAMPPath(fileArgs []string, key string) string {
+	for _, a := range fileArgs {
+		if a == key {
+			return a
+		}
+	}
+	nk := normAMPPath(key)
+	for _, a := range fileArgs {
+		if normAMPPath(a) == nk {
+			return a
+		}
+	}
+	for _, a := range fileArgs {
+		if strings.EqualFold(normAMPPath(a), nk) {
+			return a
+		}
+	}
+	return key
+}
+
+// normAMPPath returns a cleaned, slash-separated version of p.
+func normAMPPath(p string) string {
+	return path.Clean(strings.ReplaceAll(p, `\`, "/"))
+}
+
+// Code and URL used for the issue created by collapseAMPIssues.
+const (
+	ampBoilerplateCode = "AMP_BOILERPLATE"
+	ampBoilerplateURL  = "https://amp.dev/documentation/guides-and-tutorials/learn/spec/amp-boilerplate/"
+)
+
+// collapseAMPIssues replaces the multiple issues reported by amphtml-validator when the
+// mandatory AMP boilerplate code is missing or malformed with a single issue, unless
+// o.RawAMPIssues is true.
+func (o *Options) collapseAMPIssues(issues []Issue) []Issue {
+	if o != nil && o.RawAMPIssues {
+		return issues
+	}
+	var out []Issue
+	collapsed := false
+	for _, is := range issues {
+		if (is.Code == "MANDATORY_TAG_MISSING" || is.Code == "1") &&
+			strings.Contains(is.Message, "boilerplate") {
+			if !collapsed {
+				out = append(out, Issue{
+					Severity: Error,
+					Line:     is.Line,
+					Col:      is.Col,
+					Message:  "The mandatory AMP boilerplate code is missing or incorrect.",
+					Code:     ampBoilerplateCode,
+					URL:      ampBoilerplateURL,
+				})
+				collapsed = true
+			}
+			continue
+		}
+		out = append(out, is)
+	}
+	return out
+}
@@ -10,8 +10,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAMP_Valid(t *testing.T) {
@@ -165,3 +167,281 @@ const minimalAMP = `<!doctype html>
   </body>
 </html>
 `
+
+func TestAMPFilesWithOptions_FailFast(t *testing.T) {
+	stub := stubAMPValidator(t)
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+
+	var paths []string
+	for i, data := range []string{minimalAMP, "BAD", minimalAMP, "BAD"} {
+		p := filepath.Join(dir, fmt.Sprintf("%d.html", i))
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed writing %v: %v", p, err)
+		}
+		paths = append(paths, p)
+	}
+
+	fileIssues, err := AMPFilesWithOptions(context.Background(), paths, &Options{FailFast: true})
+	if err != ErrAborted {
+		t.Errorf("AMPFilesWithOptions returned error %v; want %v", err, ErrAborted)
+	}
+	if len(fileIssues) != 2 || len(fileIssues[paths[0]]) != 0 || len(fileIssues[paths[1]]) != 1 {
+		t.Errorf("AMPFilesWithOptions returned %v; want results for first two files", fileIssues)
+	}
+	if calls := stubAMPCalls(t, stub); len(calls) != 2 {
+		t.Errorf("amphtml-validator was called %v time(s) (%q); want 2", len(calls), calls)
+	}
+}
+
+// stubAMPValidator installs a fake amphtml-validator executable that records its arguments.
+// For each file (or "-" for stdin), the stub reports an UNKNOWN status if the file contains
+// "UNKNOWN", the JSON array of errors following "ERRORS=" in the rest of the line, or a single
+// error if the file contains "BAD".
+// The returned directory should be passed to stubAMPCalls.
+func stubAMPValidator(t *testing.T) string {
+	return stubCommand(t, "amphtml-validator", `
+dir=$(dirname "$0")
+echo "$@" >> "$dir/calls"
+printf '{'
+sep=
+for f in "$@"; do
+  case "$f" in --*) continue ;; esac
+  if [ "$f" = - ]; then data=$(cat); else data=$(cat "$f"); fi
+  case "$data" in
+    *UNKNOWN*) st=UNKNOWN; errs='[]' ;;
+    *ERRORS=*) st=FAIL; fail=1; errs=$(printf '%s\n' "$data" | sed -n 's/.*ERRORS=//p') ;;
+    *BAD*) st=FAIL; fail=1; errs='[{"severity":"ERROR","line":1,"col":0,"message":"Bad","code":"BAD"}]' ;;
+    *) st=PASS; errs='[]' ;;
+  esac
+  printf '%s"%s":{"status":"%s","errors":%s}' "$sep" "$f" "$st" "$errs"
+  sep=,
+done
+printf '}\n'
+[ -z "$fail" ]
+`)
+}
+
+// stubAMPCalls returns the arguments passed to each invocation of the
+// executable installed by stubAMPValidator.
+func stubAMPCalls(t *testing.T, dir string) []string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal("Failed reading calls: ", err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestAMP_CollapseBoilerplate(t *testing.T) {
+	stubAMPValidator(t)
+
+	// These are the errors reported by the real validator when the boilerplate is missing.
+	errs := []string{
+		`{"severity":"ERROR","line":3,"col":2,"code":"MANDATORY_TAG_MISSING",` +
+			`"message":"The mandatory tag 'head > style[amp-boilerplate]' is missing or incorrect."}`,
+		`{"severity":"ERROR","line":3,"col":2,"code":"MANDATORY_TAG_MISSING",` +
+			`"message":"The mandatory tag 'noscript > style[amp-boilerplate]' is missing or incorrect."}`,
+		`{"severity":"ERROR","line":3,"col":2,"code":"MANDATORY_TAG_MISSING",` +
+			`"message":"The mandatory tag 'noscript enclosure for boilerplate' is missing or incorrect."}`,
+		`{"severity":"ERROR","line":9,"col":4,"code":"DISALLOWED_TAG","message":"The tag 'bogus' is disallowed."}`,
+	}
+	doc := "<!doctype html>\nERRORS=[" + strings.Join(errs, ",") + "]\n"
+
+	issues, err := AMP(context.Background(), strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("AMP failed: ", err)
+	}
+	var codes []string
+	for _, is := range issues {
+		codes = append(codes, fmt.Sprintf("%d:%d %s", is.Line, is.Col, is.Code))
+	}
+	if want := []string{"3:3 " + ampBoilerplateCode, "9:5 DISALLOWED_TAG"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("AMP returned %q; want %q", codes, want)
+	}
+
+	raw, err := AMPWithOptions(context.Background(), strings.NewReader(doc), &Options{RawAMPIssues: true})
+	if err != nil {
+		t.Fatal("AMPWithOptions failed: ", err)
+	}
+	if len(raw) != len(errs) {
+		t.Errorf("AMPWithOptions with RawAMPIssues returned %v issues; want %v", len(raw), len(errs))
+	}
+}
+
+func TestAMP_UnknownStatus(t *testing.T) {
+	stubAMPValidator(t)
+	if _, err := AMP(context.Background(), strings.NewReader("UNKNOWN")); err != ErrNoAMPVerdict {
+		t.Errorf("AMP returned error %v; want %v", err, ErrNoAMPVerdict)
+	}
+}
+
+func TestAMP_Format(t *testing.T) {
+	const story = `<!doctype html>
+<html ⚡>
+  <head>
+    <meta charset="utf-8">
+    <script async src="https://cdn.ampproject.org/v0.js"></script>
+    <script async custom-element="amp-story" src="https://cdn.ampproject.org/v0/amp-story-1.0.js"></script>
+    <link rel="canonical" href="story.html">
+  </head>
+  <body>
+    <amp-story standalone title="Story" publisher="Me" publisher-logo-src="logo.png" poster-portrait-src="poster.jpg">
+      <amp-story-page id="cover">
+        <amp-story-grid-layer template="fill"><h1>Hello</h1></amp-story-grid-layer>
+      </amp-story-page>
+    </amp-story>
+  </body>
+</html>
+`
+	const email = "<!doctype html>\n<html ⚡4email>\n<body>Hi</body>\n</html>\n"
+
+	if got := detectAMPFormat([]byte(story)); got != AMPStory {
+		t.Errorf("detectAMPFormat(story) = %q; want %q", got, AMPStory)
+	}
+
+	for _, tc := range []struct {
+		doc    string
+		format AMPFormat
+		want   string // validator args
+	}{
+		{story, "", "--format=json --html_format=AMP -"},
+		{email, "", "--format=json --html_format=AMP4EMAIL -"},
+		{story, AMPAds, "--format=json --html_format=AMP4ADS -"},
+	} {
+		stub := stubAMPValidator(t)
+		opts := &Options{AMPFormat: tc.format}
+		if issues, err := AMPWithOptions(context.Background(), strings.NewReader(tc.doc), opts); err != nil {
+			t.Errorf("AMPWithOptions with format %q failed: %v", tc.format, err)
+		} else if len(issues) != 0 {
+			t.Errorf("AMPWithOptions with format %q returned %v", tc.format, issues)
+		}
+		if calls := stubAMPCalls(t, stub); !reflect.DeepEqual(calls, []string{tc.want}) {
+			t.Errorf("AMPWithOptions with format %q ran validator with %q; want %q", tc.format, calls, tc.want)
+		}
+	}
+
+	// AMPFiles should run the validator once per format.
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	storyPath := filepath.Join(dir, "story.html")
+	emailPath := filepath.Join(dir, "email.html")
+	for p, doc := range map[string]string{storyPath: story, emailPath: email} {
+		if err := ioutil.WriteFile(p, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stub := stubAMPValidator(t)
+	if _, err := AMPFiles(context.Background(), []string{storyPath, emailPath}); err != nil {
+		t.Error("AMPFiles failed: ", err)
+	}
+	want := []string{
+		"--format=json --html_format=AMP " + storyPath,
+		"--format=json --html_format=AMP4EMAIL " + emailPath,
+	}
+	if calls := stubAMPCalls(t, stub); !reflect.DeepEqual(calls, want) {
+		t.Errorf("AMPFiles ran validator with %q; want %q", calls, want)
+	}
+}
+
+func TestMatchAMPPath(t *testing.T) {
+	args := []string{`C:\Site\Index.html`, `C:\Site\sub\..\other.html`, "docs/a.html", "docs/A.html", "-"}
+	for _, tc := range []struct{ key, want string }{
+		{`C:\Site\Index.html`, `C:\Site\Index.html`},
+		{"C:/Site/Index.html", `C:\Site\Index.html`},
+		{`c:\site\index.html`, `C:\Site\Index.html`},
+		{"C:/Site/other.html", `C:\Site\sub\..\other.html`},
+		{"docs/A.html", "docs/A.html"}, // exact matches are preferred
+		{`docs\a.html`, "docs/a.html"},
+		{"-", "-"},
+		{"unknown.html", "unknown.html"},
+	} {
+		if got := matchAMPPath(args, tc.key); got != tc.want {
+			t.Errorf("matchAMPPath(%q) = %q; want %q", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestAMPFiles_BadPaths(t *testing.T) {
+	stub := stubAMPValidator(t)
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	good := filepath.Join(dir, "good.html")
+	if err := ioutil.WriteFile(good, []byte(minimalAMP), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.html")
+	paths := []string{good, sub, missing}
+
+	fileIssues, err := AMPFiles(context.Background(), paths)
+	if perr, ok := err.(*AMPPathError); !ok {
+		t.Errorf("AMPFiles returned error %v; want *AMPPathError", err)
+	} else if len(perr.Errs) != 2 || perr.Errs[sub] == nil || perr.Errs[missing] == nil {
+		t.Errorf("AMPFiles returned path errors %v; want errors for %v and %v", perr.Errs, sub, missing)
+	}
+	if issues, ok := fileIssues[good]; !ok || len(issues) != 0 || len(fileIssues) != 1 {
+		t.Errorf("AMPFiles returned %v; want only empty results for %v", fileIssues, good)
+	}
+	if calls := stubAMPCalls(t, stub); len(calls) != 1 || !strings.HasSuffix(calls[0], " "+good) {
+		t.Errorf("amphtml-validator was called with %q; want only %v", calls, good)
+	}
+
+	fileIssues, err = AMPFilesWithOptions(context.Background(), paths, &Options{SkipBadAMPPaths: true})
+	if err != nil {
+		t.Error("AMPFilesWithOptions with SkipBadAMPPaths failed: ", err)
+	}
+	for _, p := range []string{sub, missing} {
+		if issues := fileIssues[p]; len(issues) != 1 || issues[0].Severity != Warning ||
+			issues[0].Code != unreadableFileCode {
+			t.Errorf("AMPFilesWithOptions returned %+v for %v; want one %q warning", issues, p, unreadableFileCode)
+		}
+	}
+}
+
+func TestAMPWithOptions_AMPTimeout(t *testing.T) {
+	stubCommand(t, "amphtml-validator", "exec sleep 60\n")
+	_, err := AMPWithOptions(context.Background(), strings.NewReader(minimalAMP),
+		&Options{AMPTimeout: 10 * time.Millisecond})
+	if err != context.DeadlineExceeded {
+		t.Errorf("AMPWithOptions returned %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAMPWithOptions_NodeAndEnv(t *testing.T) {
+	// Install a fake amphtml-validator that reports $MSG as an error and a fake Node
+	// runtime that appends a suffix to $MSG before running the script.
+	stubCommand(t, "amphtml-validator", `printf '{"-":{"status":"FAIL","errors":[{"severity":"ERROR",`+
+		`"line":1,"col":0,"message":"%s","code":"MSG"}]}}\n' "$MSG"`+"\n")
+	node := filepath.Join(stubCommand(t, "fake-node", `MSG="$MSG via node" exec "$@"`+"\n"), "fake-node")
+
+	for _, tc := range []struct {
+		opts *Options
+		want string
+	}{
+		{&Options{AMPEnv: []string{"MSG=hello"}}, "hello"},
+		{&Options{AMPEnv: []string{"MSG=hello"}, AMPNode: node}, "hello via node"},
+	} {
+		issues, err := AMPWithOptions(context.Background(), strings.NewReader(minimalAMP), tc.opts)
+		if err != nil {
+			t.Errorf("AMPWithOptions(%+v) failed: %v", tc.opts, err)
+		} else if len(issues) != 1 || issues[0].Message != tc.want {
+			t.Errorf("AMPWithOptions(%+v) returned %q; want single %q issue", tc.opts, issues, tc.want)
+		}
+	}
+}
+
+func TestAMP_ExecError(t *testing.T) {
+	stubCommand(t, "amphtml-validator", "cat >/dev/null\necho 'Error: Cannot find module validator.wasm' >&2\nexit 1\n")
+	_, err := AMP(context.Background(), strings.NewReader(minimalAMP))
+	want := &AMPExecError{ExitCode: 1, Stderr: "Error: Cannot find module validator.wasm"}
+	if eerr, ok := err.(*AMPExecError); !ok {
+		t.Errorf("AMP returned error %v; want %v", err, want)
+	} else if !reflect.DeepEqual(eerr, want) {
+		t.Errorf("AMP returned %+v; want %+v", eerr, want)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ampCheck is a local check performed by AMPQuickCheck.
+// toks contains the document's tokens.
+type ampCheck func(toks []lineToken) []Issue
+
+// ampChecks lists the checks performed by AMPQuickCheck.
+var ampChecks = []ampCheck{
+	checkAMPLayout,
+	viewportIssues,
+	checkAMPCustomCSS,
+	checkAMPExtensions,
+}
+
+// AMPQuickCheck reads an AMP HTML document from r and checks it locally for common mistakes.
+// It is much faster than AMP and doesn't require amphtml-validator, making it suitable for
+// instant feedback (e.g. in editors), but it only detects a small subset of the problems
+// reported by AMP. Issues use the same codes as amphtml-validator where possible and are
+// returned in order of increasing line number.
+func AMPQuickCheck(r io.Reader) ([]Issue, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks := tokenizeLines(b)
+	var issues []Issue
+	for _, c := range ampChecks {
+		issues = append(issues, c(toks)...)
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues, nil
+}
+
+// ampLayoutElements lists AMP components whose dimensions are checked by checkAMPLayout.
+var ampLayoutElements = stringSet([]string{"amp-img", "amp-video", "amp-iframe"}, nil)
+
+// checkAMPLayout reports elements from ampLayoutElements that lack the attributes
+// required by their layouts.
+func checkAMPLayout(toks []lineToken) []Issue {
+	var issues []Issue
+	add := func(t *lineToken, code, msg string) {
+		issues = append(issues, Issue{
+			Severity: Error,
+			Line:     t.line,
+			Message:  msg,
+			Code:     code,
+			URL:      "https://amp.dev/documentation/guides-and-tutorials/learn/amp-html-layout/",
+		})
+	}
+	for i := range toks {
+		t := &toks[i]
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		if _, ok := ampLayoutElements[t.Data]; !ok {
+			continue
+		}
+		layout, _ := tokenAttr(&t.Token, "layout")
+		width, hasWidth := tokenAttr(&t.Token, "width")
+		_, hasHeight := tokenAttr(&t.Token, "height")
+		if width == "auto" {
+			hasWidth = false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(layout)) {
+		case "fill", "container", "flex-item", "nodisplay":
+			// No dimensions are needed.
+		case "fixed-height":
+			if !hasHeight {
+				add(t, "ATTR_VALUE_REQUIRED_BY_LAYOUT",
+					fmt.Sprintf("The attribute 'height' is required for tag '%s' with layout 'fixed-height'.", t.Data))
+			}
+		case "fixed", "responsive", "intrinsic":
+			if !hasWidth || !hasHeight {
+				add(t, "ATTR_VALUE_REQUIRED_BY_LAYOUT",
+					fmt.Sprintf("The attributes 'width' and 'height' are required for tag '%s' with layout '%s'.",
+						t.Data, layout))
+			}
+		case "":
+			if !hasHeight {
+				add(t, "IMPLIED_LAYOUT_INVALID",
+					fmt.Sprintf("The implied layout of tag '%s' is unsupported; add 'width' and 'height' "+
+						"or a 'layout' attribute.", t.Data))
+			}
+		default:
+			add(t, "INVALID_ATTR_VALUE",
+				fmt.Sprintf("The attribute 'layout' in tag '%s' is set to the invalid value '%s'.", t.Data, layout))
+		}
+	}
+	return issues
+}
+
+// maxAMPCustomCSS is the maximum total size in bytes of <style amp-custom> content.
+const maxAMPCustomCSS = 75000
+
+// ampAtRules contains the CSS at-rules permitted in <style amp-custom>.
+var ampAtRules = stringSet([]string{"font-face", "keyframes", "media", "page", "supports"}, nil)
+
+var (
+	cssComment   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssImportant = regexp.MustCompile(`(?i)!\s*important`)
+	cssAtRule    = regexp.MustCompile(`(?:^|[\s;{}])(@)(-[a-z]+-)?([a-zA-Z-]+)`)
+)
+
+// checkAMPCustomCSS reports <style amp-custom> content that exceeds maxAMPCustomCSS bytes
+// in total or that uses !important or disallowed at-rules.
+func checkAMPCustomCSS(toks []lineToken) []Issue {
+	var issues []Issue
+	size, firstLine := 0, 0
+	inCustom := false
+	for _, t := range toks {
+		switch t.Type {
+		case html.StartTagToken:
+			_, custom := tokenAttr(&t.Token, "amp-custom")
+			inCustom = t.Data == "style" && custom
+			if inCustom && firstLine == 0 {
+				firstLine = t.line
+			}
+			continue
+		case html.TextToken:
+			if !inCustom {
+				continue
+			}
+		default:
+			inCustom = false
+			continue
+		}
+
+		size += len(t.Data)
+		// Blank comments so their contents are ignored without changing offsets.
+		css := cssComment.ReplaceAllFunc([]byte(t.Data), func(b []byte) []byte {
+			return blankBytes(append([]byte(nil), b...))
+		})
+		lineAt := func(off int) int { return t.line + bytes.Count(css[:off], []byte{'\n'}) }
+		for _, loc := range cssImportant.FindAllIndex(css, -1) {
+			issues = append(issues, Issue{
+				Severity: Error,
+				Line:     lineAt(loc[0]),
+				Message:  "CSS syntax error in tag 'style amp-custom' - the property value has '!important'.",
+				Code:     "CSS_SYNTAX_DISALLOWED_IMPORTANT",
+				URL:      ampErrorsURL,
+			})
+		}
+		for _, m := range cssAtRule.FindAllSubmatchIndex(css, -1) {
+			name := strings.ToLower(string(css[m[6]:m[7]]))
+			if _, ok := ampAtRules[name]; ok {
+				continue
+			}
+			issues = append(issues, Issue{
+				Severity: Error,
+				Line:     lineAt(m[2]),
+				Message:  fmt.Sprintf("CSS syntax error in tag 'style amp-custom' - saw invalid at rule '@%s'.", name),
+				Code:     "CSS_SYNTAX_INVALID_AT_RULE",
+				URL:      ampErrorsURL,
+			})
+		}
+	}
+	if size > maxAMPCustomCSS {
+		issues = append(issues, Issue{
+			Severity: Error,
+			Line:     firstLine,
+			Message: fmt.Sprintf("The author stylesheet specified in tag 'style amp-custom' is too long - "+
+				"document contains %d bytes whereas the limit is %d bytes.", size, maxAMPCustomCSS),
+			Code: "STYLESHEET_TOO_LONG",
+			URL:  ampErrorsURL,
+		})
+	}
+	return issues
+}
+
+// ampExtensions maps from common AMP components' element names to the names of the
+// extensions (i.e. the custom-element attributes of the <script> elements) that they require.
+// Built-in components like amp-img and amp-layout don't need extensions.
+var ampExtensions = map[string]string{
+	"amp-accordion":         "amp-accordion",
+	"amp-ad":                "amp-ad",
+	"amp-analytics":         "amp-analytics",
+	"amp-anim":              "amp-anim",
+	"amp-audio":             "amp-audio",
+	"amp-autocomplete":      "amp-autocomplete",
+	"amp-base-carousel":     "amp-base-carousel",
+	"amp-bind-macro":        "amp-bind",
+	"amp-carousel":          "amp-carousel",
+	"amp-consent":           "amp-consent",
+	"amp-date-picker":       "amp-date-picker",
+	"amp-embed":             "amp-ad",
+	"amp-facebook":          "amp-facebook",
+	"amp-fit-text":          "amp-fit-text",
+	"amp-geo":               "amp-geo",
+	"amp-iframe":            "amp-iframe",
+	"amp-image-lightbox":    "amp-image-lightbox",
+	"amp-instagram":         "amp-instagram",
+	"amp-lightbox":          "amp-lightbox",
+	"amp-list":              "amp-list",
+	"amp-live-list":         "amp-live-list",
+	"amp-mathml":            "amp-mathml",
+	"amp-script":            "amp-script",
+	"amp-selector":          "amp-selector",
+	"amp-sidebar":           "amp-sidebar",
+	"amp-social-share":      "amp-social-share",
+	"amp-state":             "amp-bind",
+	"amp-story":             "amp-story",
+	"amp-story-grid-layer":  "amp-story",
+	"amp-story-page":        "amp-story",
+	"amp-timeago":           "amp-timeago",
+	"amp-twitter":           "amp-twitter",
+	"amp-user-notification": "amp-user-notification",
+	"amp-video":             "amp-video",
+	"amp-vimeo":             "amp-vimeo",
+	"amp-web-push":          "amp-web-push",
+	"amp-youtube":           "amp-youtube",
+}
+
+// checkAMPExtensions reports components from ampExtensions (and <template type="amp-mustache">
+// elements) that are used without loading the required extension scripts. Each missing
+// extension is reported once, at the first line where it's needed.
+func checkAMPExtensions(toks []lineToken) []Issue {
+	loaded := make(map[string]struct{})
+	type use struct {
+		tag, ext string
+		line     int
+	}
+	var uses []use
+	for _, t := range toks {
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		switch t.Data {
+		case "script":
+			for _, attr := range []string{"custom-element", "custom-template"} {
+				if ext, ok := tokenAttr(&t.Token, attr); ok {
+					loaded[strings.ToLower(strings.TrimSpace(ext))] = struct{}{}
+				}
+			}
+		case "template":
+			if typ, _ := tokenAttr(&t.Token, "type"); typ == "amp-mustache" {
+				uses = append(uses, use{"template", "amp-mustache", t.line})
+			}
+		default:
+			if ext, ok := ampExtensions[t.Data]; ok {
+				uses = append(uses, use{t.Data, ext, t.line})
+			}
+		}
+	}
+
+	var issues []Issue
+	for _, u := range uses {
+		if _, ok := loaded[u.ext]; ok {
+			continue
+		}
+		loaded[u.ext] = struct{}{} // only report once
+		issues = append(issues, Issue{
+			Severity: Error,
+			Line:     u.line,
+			Message:  fmt.Sprintf("The tag '%s' requires including the '%s' extension JavaScript.", u.tag, u.ext),
+			Code:     "MISSING_REQUIRED_EXTENSION",
+			URL:      ampErrorsURL,
+		})
+	}
+	return issues
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ampQuickIssues runs AMPQuickCheck on doc and returns "line code" strings for the issues.
+func ampQuickIssues(t *testing.T, doc string) []string {
+	issues, err := AMPQuickCheck(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("AMPQuickCheck(%q) failed: %v", doc, err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, fmt.Sprintf("%d %s", is.Line, is.Code))
+	}
+	return got
+}
+
+func TestAMPQuickCheck_Layout(t *testing.T) {
+	const doc = `<!doctype html>
+<html ⚡>
+  <head>
+    <script async custom-element="amp-video" src="https://cdn.ampproject.org/v0/amp-video-0.1.js"></script>
+    <script async custom-element="amp-iframe" src="https://cdn.ampproject.org/v0/amp-iframe-0.1.js"></script>
+  </head>
+  <body>
+    <amp-img src="a.jpg" width="100" height="50" alt="OK"></amp-img>
+    <amp-img src="b.jpg" alt="No dimensions"></amp-img>
+    <amp-img src="c.jpg" layout="fill" alt="Fill"></amp-img>
+    <amp-video src="d.mp4" layout="responsive" width="16"></amp-video>
+    <amp-iframe src="e.html" height="100" width="auto" layout="fixed-height"></amp-iframe>
+    <amp-img src="f.jpg" layout="stretchy" width="1" height="1" alt="Bad layout"></amp-img>
+  </body>
+</html>
+`
+	want := []string{
+		"9 IMPLIED_LAYOUT_INVALID",
+		"11 ATTR_VALUE_REQUIRED_BY_LAYOUT",
+		"13 INVALID_ATTR_VALUE",
+	}
+	if got := ampQuickIssues(t, doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("AMPQuickCheck returned %q; want %q", got, want)
+	}
+}
+
+func TestAMPQuickCheck_CustomCSS(t *testing.T) {
+	const doc = `<!doctype html>
+<html ⚡>
+  <head>
+    <style amp-custom>
+      /* Don't use !important or @import here. */
+      @media (min-width: 600px) { p { color: red; } }
+      @-webkit-keyframes fade { from { opacity: 0 } }
+      @import url("other.css");
+      b { color: blue !important; background: url("a@b.png"); }
+    </style>
+  </head>
+</html>
+`
+	want := []string{"8 CSS_SYNTAX_INVALID_AT_RULE", "9 CSS_SYNTAX_DISALLOWED_IMPORTANT"}
+	if got := ampQuickIssues(t, doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("AMPQuickCheck returned %q; want %q", got, want)
+	}
+
+	big := "<!doctype html>\n<html ⚡>\n<head>\n<style amp-custom>\n" +
+		strings.Repeat("p { color: red; }\n", 5000) + "</style>\n</head>\n</html>\n"
+	if got, want := ampQuickIssues(t, big), []string{"4 STYLESHEET_TOO_LONG"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AMPQuickCheck returned %q for oversized stylesheet; want %q", got, want)
+	}
+}
+
+func TestAMPQuickCheck_Extensions(t *testing.T) {
+	const doc = `<!doctype html>
+<html ⚡>
+  <head>
+    <script async custom-element="amp-sidebar" src="https://cdn.ampproject.org/v0/amp-sidebar-0.1.js"></script>
+  </head>
+  <body>
+    <amp-sidebar id="nav" layout="nodisplay"></amp-sidebar>
+    <amp-carousel width="400" height="300" layout="responsive" type="slides">
+      <amp-img src="a.jpg" width="400" height="300" alt="A"></amp-img>
+    </amp-carousel>
+    <amp-carousel width="400" height="300" layout="responsive"></amp-carousel>
+    <amp-state id="items"></amp-state>
+    <template type="amp-mustache">{{name}}</template>
+  </body>
+</html>
+`
+	want := []string{
+		"8 MISSING_REQUIRED_EXTENSION",
+		"12 MISSING_REQUIRED_EXTENSION",
+		"13 MISSING_REQUIRED_EXTENSION",
+	}
+	if got := ampQuickIssues(t, doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("AMPQuickCheck returned %q; want %q", got, want)
+	}
+	issues, err := AMPQuickCheck(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("AMPQuickCheck failed: ", err)
+	}
+	const msg = "The tag 'amp-carousel' requires including the 'amp-carousel' extension JavaScript."
+	if len(issues) == 0 || issues[0].Message != msg {
+		t.Errorf("AMPQuickCheck returned %q; want first message %q", issues, msg)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import "strings"
+
+// ampErrorsURL documents the errors reported by amphtml-validator.
+const ampErrorsURL = "https://amp.dev/documentation/guides-and-tutorials/learn/validation-workflow/validation_errors/"
+
+// ampExplanations contains remediation text for common amphtml-validator error codes.
+var ampExplanations = map[string]string{
+	ampBoilerplateCode: "The AMP boilerplate <style> and <noscript> elements must appear in <head> " +
+		"exactly as documented.",
+	"MANDATORY_TAG_MISSING": "A tag that every AMP document must contain (e.g. <meta charset>, " +
+		"<link rel=canonical>, or the AMP runtime <script>) is missing.",
+	"MANDATORY_ATTR_MISSING": "An element is missing an attribute that it requires. " +
+		"Add the attribute named in the message.",
+	"MANDATORY_ONEOF_ATTR_MISSING": "An element must have exactly one of the attributes listed in the message.",
+	"DISALLOWED_TAG": "The element isn't allowed in AMP documents. Replace it with the corresponding " +
+		"AMP component (e.g. <amp-img> instead of <img>) or remove it.",
+	"DISALLOWED_ATTR": "The attribute isn't allowed on this element in AMP documents. Remove it.",
+	"INVALID_ATTR_VALUE": "The attribute's value isn't permitted. Check the component's documentation " +
+		"for allowed values.",
+	"DUPLICATE_UNIQUE_TAG": "The tag may only appear once in the document. Remove the duplicate.",
+	"WRONG_PARENT_TAG":     "The element must be a child of a specific parent element. Move it.",
+	"MISSING_REQUIRED_EXTENSION": "The document uses an AMP component without loading its script. " +
+		"Add the component's <script custom-element> tag to <head>.",
+	"EXTENSION_UNUSED": "A component's script is loaded but the component isn't used. Remove the script.",
+	"TAG_REQUIRED_BY_MISSING": "The document uses a feature that requires another tag (usually an " +
+		"extension script) that is missing.",
+	"ATTR_VALUE_REQUIRED_BY_LAYOUT": "The element's layout requires an attribute (typically width or " +
+		"height) that is missing or invalid.",
+	"IMPLIED_LAYOUT_INVALID": "The element's layout can't be determined from its attributes. " +
+		"Add width and height attributes or an explicit layout attribute.",
+	"CSS_SYNTAX_INVALID_AT_RULE": "The CSS uses an at-rule that isn't allowed in AMP. Remove it.",
+	"CSS_SYNTAX_DISALLOWED_IMPORTANT": "The CSS uses !important, which isn't allowed in AMP. " +
+		"Increase the rule's specificity instead.",
+	"STYLESHEET_TOO_LONG": "The document's <style amp-custom> element exceeds AMP's size limit. " +
+		"Remove unused CSS.",
+	"INLINE_STYLE_TOO_LONG": "The document's style attributes exceed AMP's size limit. " +
+		"Move styles to <style amp-custom>.",
+}
+
+// ampNumericCodes maps numeric codes reported by recent versions of amphtml-validator
+// (see the ValidationError.Code enum in validator.proto) to their names.
+var ampNumericCodes = map[string]string{
+	"1": "MANDATORY_TAG_MISSING",
+	"2": "DISALLOWED_TAG",
+}
+
+// ExplainAMPCode returns human-friendly remediation text and a documentation link for
+// code, an Issue.Code value reported by AMP or AMPFiles. false is returned if no
+// explanation is available for the code. See also ExplainAMPIssue.
+func ExplainAMPCode(code string) (string, bool) {
+	if name, ok := ampNumericCodes[code]; ok {
+		code = name
+	}
+	text, ok := ampExplanations[code]
+	if !ok {
+		return "", false
+	}
+	url := ampErrorsURL
+	if code == ampBoilerplateCode {
+		url = ampBoilerplateURL
+	}
+	return text + " See " + url, true
+}
+
+// ExplainAMPIssue is similar to ExplainAMPCode but falls back to the issue's own
+// message and URL if its code isn't recognized.
+func ExplainAMPIssue(is Issue) string {
+	if text, ok := ExplainAMPCode(is.Code); ok {
+		return text
+	}
+	if is.URL != "" {
+		return strings.TrimSuffix(is.Message, ".") + ". See " + is.URL
+	}
+	return is.Message
+}
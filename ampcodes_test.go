@@ -0,0 +1,27 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainAMPCode(t *testing.T) {
+	for _, code := range []string{"MANDATORY_ATTR_MISSING", "DISALLOWED_TAG", "2", ampBoilerplateCode} {
+		if text, ok := ExplainAMPCode(code); !ok || text == "" {
+			t.Errorf("ExplainAMPCode(%q) = %q, %v; want non-empty explanation", code, text, ok)
+		} else if !strings.Contains(text, "https://") {
+			t.Errorf("ExplainAMPCode(%q) = %q; want link", code, text)
+		}
+	}
+	if text, ok := ExplainAMPCode("NOT_A_REAL_CODE"); ok {
+		t.Errorf("ExplainAMPCode(unknown) = %q, %v; want false", text, ok)
+	}
+
+	is := Issue{Message: "Something odd", Code: "NOT_A_REAL_CODE", URL: "https://example.org/odd"}
+	if got, want := ExplainAMPIssue(is), "Something odd. See https://example.org/odd"; got != want {
+		t.Errorf("ExplainAMPIssue(%+v) = %q; want %q", is, got, want)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultArchiveConcurrency is the default maximum number of archive entries validated
+// concurrently by ValidateArchive.
+const DefaultArchiveConcurrency = 4
+
+// ValidateArchive validates the supported entries in the zip archive zr and returns their
+// issues keyed by in-archive path. Each issue's File field is set to the entry's path.
+//
+// Entries are dispatched by name: ".amp" and ".amp.html" files are validated by AMP,
+// ".html" and ".htm" files by HTML (or by AMP if their <html> element has an AMP attribute),
+// ".xhtml" files by XHTML, and ".css" files by CSS. Other entries are skipped.
+//
+// At most Options.ArchiveConcurrency entries are validated at once, and requests to online
+// validation services are separated by at least Options.RequestInterval. If some entries
+// couldn't be validated, the successful results are returned along with an error describing
+// the failures. opts may be nil.
+func ValidateArchive(ctx context.Context, zr *zip.Reader, opts *Options) (map[string][]Issue, error) {
+	type entry struct {
+		f  *zip.File
+		ft FileType
+	}
+	var entries []entry
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if ft := archiveFileType(f.Name); ft != "" {
+			entries = append(entries, entry{f, ft})
+		}
+	}
+
+	concurrency := DefaultArchiveConcurrency
+	if opts != nil && opts.ArchiveConcurrency > 0 {
+		concurrency = opts.ArchiveConcurrency
+	}
+	limiter := opts.requestLimiter()
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.f.Name
+	}
+	return validateConcurrently(names, concurrency, func(i int) ([]Issue, error) {
+		issues, err := validateArchiveFile(ctx, entries[i].f, entries[i].ft, limiter, opts)
+		for j := range issues {
+			issues[j].File = names[i]
+		}
+		return issues, err
+	})
+}
+
+// validateConcurrently calls fn with the index of each of names using at most concurrency
+// goroutines and returns the issues keyed by name. If some calls fail, the successful
+// results are returned along with an error describing the failures.
+func validateConcurrently(names []string, concurrency int,
+	fn func(i int) ([]Issue, error)) (map[string][]Issue, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make(map[string][]Issue, len(names))
+	var failures []string
+	var mu sync.Mutex // protects results and failures
+
+	ch := make(chan int, len(names))
+	for i := range names {
+		ch <- i
+	}
+	close(ch)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				issues, err := fn(i)
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("%v: %v", names[i], err))
+				} else {
+					results[names[i]] = issues
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return results, fmt.Errorf("failed validating %d file(s): %v",
+			len(failures), strings.Join(failures, "; "))
+	}
+	return results, nil
+}
+
+// archiveFileType returns the type used to validate the archive entry named name,
+// or an empty string if the entry should be skipped. AMPDoc is only returned for
+// names with AMP-specific extensions; see validateArchiveFile.
+func archiveFileType(name string) FileType {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".amp") || strings.HasSuffix(name, ".amp.html"):
+		return AMPDoc
+	case strings.HasSuffix(name, ".html") || strings.HasSuffix(name, ".htm"):
+		return HTMLDoc
+	case strings.HasSuffix(name, ".xhtml"):
+		return XHTMLDoc
+	case strings.HasSuffix(name, ".css"):
+		return Stylesheet
+	default:
+		return ""
+	}
+}
+
+// validateArchiveFile reads f and validates it as ft (or as AMPDoc if ft is HTMLDoc and
+// the document is an AMP document), using limiter to rate-limit requests to online services.
+func validateArchiveFile(ctx context.Context, f *zip.File, ft FileType,
+	limiter *RateLimiter, opts *Options) ([]Issue, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	if ft == HTMLDoc && isAMPDoc(b) {
+		ft = AMPDoc
+	}
+	if ft != AMPDoc {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	issues, _, err := ValidateWithOptions(ctx, bytes.NewReader(b), ft, opts)
+	return issues, err
+}
+
+// isAMPDoc returns true if doc's <html> element has an attribute identifying it as an
+// AMP document, e.g. <html ⚡> or <html amp4email>.
+func isAMPDoc(doc []byte) bool {
+	for _, t := range tokenizeLines(doc) {
+		if (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) || t.Data != "html" {
+			continue
+		}
+		for _, a := range t.Attr {
+			switch a.Key {
+			case "⚡", "amp", "⚡4ads", "amp4ads", "⚡4email", "amp4email":
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
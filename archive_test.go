@@ -0,0 +1,80 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateArchive(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		if b, _ := ioutil.ReadAll(r.Body); strings.Contains(string(b), "<bogus>") {
+			io.WriteString(w, nuPage(nuError(1, 22, "Element bogus not allowed")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage())
+	})
+	stubAMPValidator(t)
+
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, f := range []struct{ name, data string }{
+		{"index.html", "<!DOCTYPE html><bogus>"},
+		{"css/style.css", "body { color: red }"},
+		{"amp/page.html", strings.Replace(minimalAMP, "</body>", "BAD</body>", 1)},
+		{"README.md", "# Not validated"},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ValidateArchive(context.Background(), zr, &Options{ArchiveConcurrency: 2})
+	if err != nil {
+		t.Fatal("ValidateArchive failed: ", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("ValidateArchive returned results for %d file(s); want 3", len(results))
+	}
+	for _, tc := range []struct {
+		name string
+		want []Issue
+	}{
+		{"index.html", []Issue{{Severity: Error, Line: 1, Col: 22, Message: "Element bogus not allowed",
+			File: "index.html", Source: HTMLSource}}},
+		{"css/style.css", nil},
+		{"amp/page.html", []Issue{{Severity: Error, Line: 1, Col: 1, Message: "Bad", Code: "BAD",
+			File: "amp/page.html", Source: AMPSource}}},
+	} {
+		got, ok := results[tc.name]
+		if !ok {
+			t.Errorf("ValidateArchive didn't return results for %v", tc.name)
+			continue
+		}
+		if missing, extra := CompareIssues(got, tc.want, "URL", "Anchor"); len(missing) > 0 || len(extra) > 0 {
+			t.Errorf("ValidateArchive returned %v issues %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
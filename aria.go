@@ -0,0 +1,216 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ariaRoles contains the non-abstract roles defined by WAI-ARIA 1.2.
+var ariaRoles = stringSet([]string{
+	"alert", "alertdialog", "application", "article", "banner", "blockquote", "button",
+	"caption", "cell", "checkbox", "code", "columnheader", "combobox", "complementary",
+	"contentinfo", "definition", "deletion", "dialog", "directory", "document", "emphasis",
+	"feed", "figure", "form", "generic", "grid", "gridcell", "group", "heading", "img",
+	"insertion", "link", "list", "listbox", "listitem", "log", "main", "marquee", "math",
+	"menu", "menubar", "menuitem", "menuitemcheckbox", "menuitemradio", "meter", "navigation",
+	"none", "note", "option", "paragraph", "presentation", "progressbar", "radio", "radiogroup",
+	"region", "row", "rowgroup", "rowheader", "scrollbar", "search", "searchbox", "separator",
+	"slider", "spinbutton", "status", "strong", "subscript", "superscript", "switch", "tab",
+	"table", "tablist", "tabpanel", "term", "textbox", "time", "timer", "toolbar", "tooltip",
+	"tree", "treegrid", "treeitem",
+}, nil)
+
+// Prefixes of roles defined by the DPUB-ARIA and Graphics ARIA modules, which aren't checked.
+var ariaRolePrefixes = []string{"doc-", "graphics-"}
+
+// ariaType describes the type of an aria-* attribute's value.
+type ariaType int
+
+const (
+	ariaString             ariaType = iota // arbitrary string
+	ariaBoolean                            // "true" or "false"
+	ariaTristate                           // "true", "false", or "mixed"
+	ariaTrueFalseUndefined                 // "true", "false", or "undefined"
+	ariaToken                              // one of the attribute's tokens
+	ariaTokenList                          // space-separated list of the attribute's tokens
+	ariaIDRef                              // ID of an element in the document
+	ariaIDRefList                          // space-separated list of element IDs
+	ariaInteger                            // integer
+	ariaNumber                             // real number
+)
+
+// ariaAttr describes an aria-* attribute.
+type ariaAttr struct {
+	typ    ariaType
+	tokens []string // permitted values for ariaToken and ariaTokenList
+}
+
+// ariaAttrs contains the aria-* attributes defined by WAI-ARIA 1.2, keyed by name.
+var ariaAttrs = map[string]ariaAttr{
+	"aria-activedescendant":       {typ: ariaIDRef},
+	"aria-atomic":                 {typ: ariaBoolean},
+	"aria-autocomplete":           {ariaToken, []string{"inline", "list", "both", "none"}},
+	"aria-braillelabel":           {typ: ariaString},
+	"aria-brailleroledescription": {typ: ariaString},
+	"aria-busy":                   {typ: ariaBoolean},
+	"aria-checked":                {typ: ariaTristate},
+	"aria-colcount":               {typ: ariaInteger},
+	"aria-colindex":               {typ: ariaInteger},
+	"aria-colspan":                {typ: ariaInteger},
+	"aria-controls":               {typ: ariaIDRefList},
+	"aria-current":                {ariaToken, []string{"page", "step", "location", "date", "time", "true", "false"}},
+	"aria-describedby":            {typ: ariaIDRefList},
+	"aria-description":            {typ: ariaString},
+	"aria-details":                {typ: ariaIDRef},
+	"aria-disabled":               {typ: ariaBoolean},
+	"aria-dropeffect":             {ariaTokenList, []string{"copy", "execute", "link", "move", "none", "popup"}},
+	"aria-errormessage":           {typ: ariaIDRef},
+	"aria-expanded":               {typ: ariaTrueFalseUndefined},
+	"aria-flowto":                 {typ: ariaIDRefList},
+	"aria-grabbed":                {typ: ariaTrueFalseUndefined},
+	"aria-haspopup":               {ariaToken, []string{"false", "true", "menu", "listbox", "tree", "grid", "dialog"}},
+	"aria-hidden":                 {typ: ariaTrueFalseUndefined},
+	"aria-invalid":                {ariaToken, []string{"grammar", "false", "spelling", "true"}},
+	"aria-keyshortcuts":           {typ: ariaString},
+	"aria-label":                  {typ: ariaString},
+	"aria-labelledby":             {typ: ariaIDRefList},
+	"aria-level":                  {typ: ariaInteger},
+	"aria-live":                   {ariaToken, []string{"assertive", "off", "polite"}},
+	"aria-modal":                  {typ: ariaBoolean},
+	"aria-multiline":              {typ: ariaBoolean},
+	"aria-multiselectable":        {typ: ariaBoolean},
+	"aria-orientation":            {ariaToken, []string{"horizontal", "undefined", "vertical"}},
+	"aria-owns":                   {typ: ariaIDRefList},
+	"aria-placeholder":            {typ: ariaString},
+	"aria-posinset":               {typ: ariaInteger},
+	"aria-pressed":                {typ: ariaTristate},
+	"aria-readonly":               {typ: ariaBoolean},
+	"aria-relevant":               {ariaTokenList, []string{"additions", "all", "removals", "text"}},
+	"aria-required":               {typ: ariaBoolean},
+	"aria-roledescription":        {typ: ariaString},
+	"aria-rowcount":               {typ: ariaInteger},
+	"aria-rowindex":               {typ: ariaInteger},
+	"aria-rowspan":                {typ: ariaInteger},
+	"aria-selected":               {typ: ariaTrueFalseUndefined},
+	"aria-setsize":                {typ: ariaInteger},
+	"aria-sort":                   {ariaToken, []string{"ascending", "descending", "none", "other"}},
+	"aria-valuemax":               {typ: ariaNumber},
+	"aria-valuemin":               {typ: ariaNumber},
+	"aria-valuenow":               {typ: ariaNumber},
+	"aria-valuetext":              {typ: ariaString},
+}
+
+// checkARIA reports unknown roles and aria-* attributes, and aria-* attributes with invalid values.
+func checkARIA(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipARIA {
+		return nil
+	}
+
+	ids := make(map[string]struct{})
+	for _, t := range toks {
+		if t.Type == html.StartTagToken || t.Type == html.SelfClosingTagToken {
+			if id, ok := tokenAttr(&t.Token, "id"); ok {
+				ids[id] = struct{}{}
+			}
+		}
+	}
+
+	var issues []Issue
+	add := func(line int, code, msg string) {
+		issues = append(issues, Issue{Severity: Warning, Line: line, Message: msg, Code: code})
+	}
+	for _, t := range toks {
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		for _, a := range t.Attr {
+			switch {
+			case a.Key == "role":
+				for _, r := range strings.Fields(strings.ToLower(a.Val)) {
+					if !knownRole(r) {
+						add(t.line, "unknown-role", fmt.Sprintf("Unknown role %q", r))
+					}
+				}
+			case strings.HasPrefix(a.Key, "aria-"):
+				attr, ok := ariaAttrs[a.Key]
+				if !ok {
+					add(t.line, "unknown-aria-attribute", fmt.Sprintf("Unknown attribute %q", a.Key))
+				} else if msg := attr.check(a.Val, ids); msg != "" {
+					add(t.line, "invalid-aria-value",
+						fmt.Sprintf("Bad value %q for attribute %q: %s", a.Val, a.Key, msg))
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// knownRole returns true if r is a recognized role.
+func knownRole(r string) bool {
+	if _, ok := ariaRoles[r]; ok {
+		return true
+	}
+	for _, p := range ariaRolePrefixes {
+		if strings.HasPrefix(r, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// check checks val, a value for a's attribute, and returns a description of the
+// problem if it is invalid. ids contains the IDs of elements in the document.
+func (a ariaAttr) check(val string, ids map[string]struct{}) string {
+	oneOf := func(v string, allowed ...string) string {
+		for _, s := range allowed {
+			if v == s {
+				return ""
+			}
+		}
+		return "expected one of " + strings.Join(allowed, ", ")
+	}
+	val = strings.TrimSpace(val)
+	switch a.typ {
+	case ariaBoolean:
+		return oneOf(val, "true", "false")
+	case ariaTristate:
+		return oneOf(val, "true", "false", "mixed")
+	case ariaTrueFalseUndefined:
+		return oneOf(val, "true", "false", "undefined")
+	case ariaToken:
+		return oneOf(strings.ToLower(val), a.tokens...)
+	case ariaTokenList:
+		for _, v := range strings.Fields(strings.ToLower(val)) {
+			if msg := oneOf(v, a.tokens...); msg != "" {
+				return msg
+			}
+		}
+	case ariaIDRef, ariaIDRefList:
+		refs := strings.Fields(val)
+		if len(refs) == 0 {
+			return "expected element ID"
+		} else if a.typ == ariaIDRef && len(refs) > 1 {
+			return "expected single element ID"
+		}
+		for _, id := range refs {
+			if _, ok := ids[id]; !ok {
+				return fmt.Sprintf("no element with ID %q", id)
+			}
+		}
+	case ariaInteger:
+		if _, err := strconv.Atoi(val); err != nil {
+			return "expected integer"
+		}
+	case ariaNumber:
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			return "expected number"
+		}
+	}
+	return ""
+}
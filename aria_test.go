@@ -0,0 +1,39 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHTMLChecks_ARIA(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <body>
+    <nav role="navigation" aria-label="Main">Nav</nav>
+    <div role="buton">Typo</div>
+    <button aria-expanded="yes" aria-controls="menu">Menu</button>
+    <ul id="menu" role="menu" aria-orientation="vertical">
+      <li role="menuitem doc-noteref" aria-posinset="1">Item</li>
+    </ul>
+    <p aria-labeledby="menu" aria-describedby="missing">Bad</p>
+    <input aria-live="loud" aria-required="true" aria-valuenow="1.5">
+  </body>
+</html>
+`
+	want := []string{
+		"5 unknown-role",
+		"6 invalid-aria-value",
+		"10 unknown-aria-attribute",
+		"10 invalid-aria-value",
+		"11 invalid-aria-value",
+	}
+	if got := checkIssues(t, doc, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+	if got := checkIssues(t, doc, &CheckOptions{SkipARIA: true}); len(got) != 0 {
+		t.Errorf("HTMLChecks with SkipARIA returned %q", got)
+	}
+}
@@ -5,21 +5,88 @@ package validate
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/net/html"
 )
 
+// ValidateAndShow reads a document of type ft from r, validates it using HTML (for HTMLDoc),
+// XHTML (for XHTMLDoc), or CSS (for Stylesheet), and displays the results page in a browser using LaunchBrowser.
+// The parsed issues are returned.
+func ValidateAndShow(ctx context.Context, r io.Reader, ft FileType) ([]Issue, error) {
+	var issues []Issue
+	var page []byte
+	var err error
+	switch ft {
+	case HTMLDoc:
+		issues, page, err = HTML(ctx, r)
+	case XHTMLDoc:
+		issues, page, err = XHTML(ctx, r)
+	case Stylesheet:
+		issues, page, err = CSS(ctx, r, ft)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q", ft)
+	}
+	if err != nil {
+		return issues, err
+	}
+	if len(page) == 0 {
+		if page, err = RenderResultsPage(issues); err != nil {
+			return issues, err
+		}
+	}
+	return issues, LaunchBrowserContext(ctx, page)
+}
+
 // LaunchBrowser launches a web browser with the supplied HTML page.
 // It can be used to display results pages returned by the CSS and HTML functions.
+// If BrowserCommand is set, it's used to open the page. Otherwise, the page is opened using
+// "open" on macOS and the default browser on Windows. On other systems, xdg-open is used if
+// X or Wayland is running, and a text-mode browser otherwise.
 func LaunchBrowser(page []byte) error {
-	// If X isn't running, just pipe the results into w3m.
-	if os.Getenv("DISPLAY") == "" {
-		cmd := exec.Command("w3m", "-T", "text/html")
-		cmd.Stdin = bytes.NewReader(page)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
+	return LaunchBrowserContext(context.Background(), page)
+}
+
+// LaunchBrowserContext is similar to LaunchBrowser, but kills the browser process
+// if ctx is cancelled before it exits.
+func LaunchBrowserContext(ctx context.Context, page []byte) error {
+	return launchBrowser(ctx, page, "")
+}
+
+// LaunchBrowserAt is similar to LaunchBrowser, but additionally scrolls to the element
+// with the supplied ID (e.g. Issue.Anchor) if anchor is non-empty.
+// The anchor is ignored when the page is displayed by a text-mode browser.
+func LaunchBrowserAt(page []byte, anchor string) error {
+	return launchBrowser(context.Background(), page, anchor)
+}
+
+// BrowserCommand optionally contains a command (e.g. []string{"firefox", "-P", "dev"}) used
+// by LaunchBrowser and related functions to display results pages. The path of a temporary
+// file containing the page (or a file:// URL, if an anchor was supplied) is appended to it.
+// If BrowserCommand is non-empty, it's always used: the operating-system-specific logic
+// (including the $DISPLAY check that selects a text-mode browser on Linux) is skipped.
+var BrowserCommand []string
+
+// Operating system on which the program is running. Overridden by tests.
+var goos = runtime.GOOS
+
+// launchBrowser implements LaunchBrowserContext and LaunchBrowserAt.
+func launchBrowser(ctx context.Context, page []byte, anchor string) error {
+	// On Linux and other Unix-like systems, use a text-mode browser if no GUI is running
+	// (unless the user supplied their own command).
+	gui := len(BrowserCommand) > 0 || goos == "darwin" || goos == "windows" ||
+		os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	if !gui {
+		return launchTextBrowser(ctx, page)
 	}
 
 	// Otherwise, write the results to a temporary file and open it in the user's preferred browser.
@@ -27,12 +94,89 @@ func LaunchBrowser(page []byte) error {
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command("xdg-open", p)
+	if anchor != "" {
+		p = fileURL(p, anchor)
+	}
+	var cmd *exec.Cmd
+	switch {
+	case len(BrowserCommand) > 0:
+		args := append(append([]string(nil), BrowserCommand[1:]...), p)
+		cmd = exec.CommandContext(ctx, BrowserCommand[0], args...)
+	case goos == "darwin":
+		cmd = exec.CommandContext(ctx, "open", p)
+	case goos == "windows":
+		cmd = exec.CommandContext(ctx, "rundll32", "url.dll,FileProtocolHandler", p)
+	default:
+		cmd = exec.CommandContext(ctx, "xdg-open", p)
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// fileURL returns a file:// URL for the absolute path p with the supplied fragment.
+// Windows paths like `C:\dir\file.html` are converted to "file:///C:/dir/file.html".
+func fileURL(p, fragment string) string {
+	p = strings.ReplaceAll(p, `\`, "/")
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p, Fragment: fragment}).String()
+}
+
+// textBrowsers lists text-mode browsers that are tried by launchTextBrowser, in order.
+var textBrowsers = []string{"w3m", "lynx", "links"}
+
+// BrowserError is returned by LaunchBrowser if no browser was available to display the page.
+type BrowserError struct {
+	// Tried contains the names of the browsers that were tried.
+	Tried []string
+	// Path contains the path to which the page was written, or is empty if it couldn't be written.
+	Path string
+}
+
+func (e *BrowserError) Error() string {
+	s := "no browser found (tried " + strings.Join(e.Tried, ", ") + ")"
+	if e.Path != "" {
+		s += "; page written to " + e.Path
+	}
+	return s
+}
+
+// launchTextBrowser displays page using the first available browser from textBrowsers.
+// If none are available, page is written to a temporary file whose path is printed to stderr,
+// and a *BrowserError is returned. The browser is killed if ctx is cancelled.
+func launchTextBrowser(ctx context.Context, page []byte) error {
+	for _, name := range textBrowsers {
+		if _, err := exec.LookPath(name); err != nil {
+			continue
+		}
+		var cmd *exec.Cmd
+		if name == "w3m" {
+			// Just pipe the results into w3m.
+			cmd = exec.CommandContext(ctx, name, "-T", "text/html")
+			cmd.Stdin = bytes.NewReader(page)
+		} else {
+			p, err := writeResults(page)
+			if err != nil {
+				return err
+			}
+			cmd = exec.CommandContext(ctx, name, p)
+			cmd.Stdin = os.Stdin
+		}
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	berr := &BrowserError{Tried: textBrowsers}
+	if p, err := writeResults(page); err == nil {
+		berr.Path = p
+		fmt.Fprintln(os.Stderr, "Results written to", p)
+	}
+	return berr
+}
+
 // writeResults writes page to a new temporary file and returns its path.
 func writeResults(page []byte) (string, error) {
 	f, err := ioutil.TempFile("", "validate.*.html")
@@ -46,3 +190,47 @@ func writeResults(page []byte) (string, error) {
 	}
 	return f.Name(), nil
 }
+
+// resultsTmpl is used by RenderResultsPage.
+var resultsTmpl = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <title>Validation results</title>
+  </head>
+  <body>
+{{- if not .}}
+    No issues found.
+{{- else -}}
+{{range .}}
+    {{.Line}}:{{.Col}} {{.Severity}} {{.Message}} {{if .URL}}<a href="{{.URL}}">{{end}}{{.Code}}{{if .URL}}</a>{{end}}<br>
+{{- end}}
+{{- end}}
+  </body>
+</html>
+`))
+
+// RenderResultsPage generates a minimal HTML page listing the supplied issues.
+// It can be used to display issues from validators like AMP that don't produce their own
+// results pages.
+func RenderResultsPage(issues []Issue) ([]byte, error) {
+	var b bytes.Buffer
+	if err := resultsTmpl.Execute(&b, issues); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// StableResultsPage parses issues from out, a raw results page returned by HTML or CSS,
+// and passes them to RenderResultsPage. Unlike the raw page, which can contain volatile
+// details like timestamps, the returned page only depends on the parsed issues, making it
+// suitable for comparison against golden files in tests.
+func StableResultsPage(out []byte) ([]byte, error) {
+	node, err := html.Parse(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	// The two services use different markup, so it's safe to look for both types of issues.
+	issues := append(extractHTMLIssues(node, false), extractCSSIssues(node)...)
+	return RenderResultsPage(issues)
+}
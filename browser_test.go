@@ -0,0 +1,202 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAndShow(t *testing.T) {
+	const page = "<!-- fake results page -->"
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(3, 5, "Bad element"))+page)
+	})
+
+	// Install a fake xdg-open that copies the page that it was asked to open.
+	dir := stubCommand(t, "xdg-open", `cp "$1" "$(dirname "$0")/opened.html"`+"\n")
+	setEnv(t, "DISPLAY", ":0")
+
+	issues, err := ValidateAndShow(context.Background(), strings.NewReader("<!DOCTYPE html>"), HTMLDoc)
+	if err != nil {
+		t.Error("ValidateAndShow failed: ", err)
+	}
+	if len(issues) != 1 || issues[0].Message != "Bad element" {
+		t.Errorf("ValidateAndShow returned %q; want single \"Bad element\" issue", issues)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "opened.html")); err != nil {
+		t.Error("Browser didn't receive page: ", err)
+	} else if !strings.Contains(string(b), page) {
+		t.Errorf("Browser received %q; want page containing %q", b, page)
+	}
+}
+
+func TestLaunchBrowserAt_OS(t *testing.T) {
+	setEnv(t, "DISPLAY", "")
+	setEnv(t, "WAYLAND_DISPLAY", "")
+	for _, tc := range []struct {
+		goos string
+		cmd  string
+		args string // expected args before the URL
+	}{
+		{"darwin", "open", ""},
+		{"windows", "rundll32", "url.dll,FileProtocolHandler "},
+	} {
+		// Install a fake command that records its arguments.
+		dir := stubCommand(t, tc.cmd, `echo "$@" > "$(dirname "$0")/args"`+"\n")
+		origGOOS := goos
+		goos = tc.goos
+		err := LaunchBrowserAt([]byte("<html>results</html>"), "cl1c2")
+		goos = origGOOS
+		if err != nil {
+			t.Errorf("LaunchBrowserAt on %v failed: %v", tc.goos, err)
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+		if err != nil {
+			t.Errorf("%v wasn't run on %v: %v", tc.cmd, tc.goos, err)
+			continue
+		}
+		args := strings.TrimSpace(string(b))
+		if !strings.HasPrefix(args, tc.args+"file:///") || !strings.HasSuffix(args, ".html#cl1c2") {
+			t.Errorf("%v on %v was run with %q; want %q followed by file URL", tc.cmd, tc.goos, args, tc.args)
+		}
+		if p := strings.TrimSuffix(strings.TrimPrefix(args, tc.args+"file://"), "#cl1c2"); p != "" {
+			os.Remove(p)
+		}
+	}
+}
+
+func TestLaunchBrowser_BrowserCommand(t *testing.T) {
+	// The command should be used even if no GUI appears to be running.
+	setEnv(t, "DISPLAY", "")
+	setEnv(t, "WAYLAND_DISPLAY", "")
+	dir := stubCommand(t, "mybrowser", `echo "$1" > "$(dirname "$0")/flag"; cp "$2" "$(dirname "$0")/opened.html"`+"\n")
+	origCmd := BrowserCommand
+	BrowserCommand = []string{"mybrowser", "-P"}
+	defer func() { BrowserCommand = origCmd }()
+
+	const page = "<html>results</html>"
+	if err := LaunchBrowser([]byte(page)); err != nil {
+		t.Fatal("LaunchBrowser failed: ", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "flag")); err != nil {
+		t.Error("Browser wasn't run: ", err)
+	} else if got := strings.TrimSpace(string(b)); got != "-P" {
+		t.Errorf("Browser got first arg %q; want %q", got, "-P")
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "opened.html")); err != nil {
+		t.Error("Browser didn't receive page: ", err)
+	} else if string(b) != page {
+		t.Errorf("Browser received %q; want %q", b, page)
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	for _, tc := range []struct{ p, frag, want string }{
+		{"/tmp/validate.1.html", "l2c3", "file:///tmp/validate.1.html#l2c3"},
+		{"/tmp/a b.html", "", "file:///tmp/a%20b.html"},
+		{`C:\Temp\validate.1.html`, "x", "file:///C:/Temp/validate.1.html#x"},
+	} {
+		if got := fileURL(tc.p, tc.frag); got != tc.want {
+			t.Errorf("fileURL(%q, %q) = %q; want %q", tc.p, tc.frag, got, tc.want)
+		}
+	}
+}
+
+func TestRenderResultsPage(t *testing.T) {
+	b, err := RenderResultsPage([]Issue{{Line: 2, Col: 3, Message: "Something <bad>", Code: "BAD"}})
+	if err != nil {
+		t.Fatal("RenderResultsPage failed: ", err)
+	}
+	if want := "2:3 Error Something &lt;bad&gt; BAD"; !strings.Contains(string(b), want) {
+		t.Errorf("RenderResultsPage returned %q; want page containing %q", b, want)
+	}
+}
+
+func TestLaunchBrowser_NoTextBrowser(t *testing.T) {
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	setEnv(t, "PATH", dir)
+	setEnv(t, "DISPLAY", "")
+	setEnv(t, "WAYLAND_DISPLAY", "")
+
+	const page = "<html>results</html>"
+	err := LaunchBrowser([]byte(page))
+	berr, ok := err.(*BrowserError)
+	if !ok {
+		t.Fatalf("LaunchBrowser returned %v; want *BrowserError", err)
+	}
+	if !reflect.DeepEqual(berr.Tried, textBrowsers) {
+		t.Errorf("BrowserError reports %q tried; want %q", berr.Tried, textBrowsers)
+	}
+	if berr.Path == "" {
+		t.Fatal("BrowserError doesn't contain path")
+	}
+	defer os.Remove(berr.Path)
+	if b, err := ioutil.ReadFile(berr.Path); err != nil {
+		t.Error("Failed reading page: ", err)
+	} else if string(b) != page {
+		t.Errorf("%v contains %q; want %q", berr.Path, b, page)
+	}
+}
+
+func TestLaunchBrowserContext_Cancel(t *testing.T) {
+	// Install a fake w3m that runs until it's killed.
+	stubCommand(t, "w3m", "exec sleep 60\n")
+	setEnv(t, "DISPLAY", "")
+	setEnv(t, "WAYLAND_DISPLAY", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- LaunchBrowserContext(ctx, []byte("<html>results</html>")) }()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("LaunchBrowserContext unexpectedly succeeded after cancellation")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("LaunchBrowserContext didn't return after cancellation")
+	}
+}
+
+func TestStableResultsPage(t *testing.T) {
+	// Simulate two validation runs that returned pages with different timestamps.
+	var pages [][]byte
+	for _, ts := range []string{"2020-01-02T03:04:05Z", "2020-01-02T03:04:17Z"} {
+		page := strings.Replace(nuPage(nuError(8, 11, "Bad element"), nuError(9, 1, "Another")),
+			"</body>", "<p>Generated at "+ts+"</p></body>", 1)
+		pages = append(pages, []byte(page))
+	}
+	if bytes.Equal(pages[0], pages[1]) {
+		t.Fatal("Raw pages are unexpectedly identical")
+	}
+
+	var stable [][]byte
+	for _, p := range pages {
+		b, err := StableResultsPage(p)
+		if err != nil {
+			t.Fatal("StableResultsPage failed: ", err)
+		}
+		stable = append(stable, b)
+	}
+	if !bytes.Equal(stable[0], stable[1]) {
+		t.Errorf("StableResultsPage returned different pages:\n%s\n%s", stable[0], stable[1])
+	}
+	if want := "8:11 Error Bad element"; !bytes.Contains(stable[0], []byte(want)) {
+		t.Errorf("StableResultsPage returned %q; want page containing %q", stable[0], want)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cassette is an http.RoundTripper that records responses to files in a directory and
+// replays them for subsequent identical requests. It can be used via Options.Client to
+// make tests that call HTML or CSS hermetic after the responses have been recorded once:
+//
+//   opts := &validate.Options{Client: &http.Client{Transport: &validate.Cassette{Dir: "testdata"}}}
+//
+// Requests are identified by their method, URL, and a hash of their body. Multipart
+// boundaries, which are randomly generated, are ignored.
+type Cassette struct {
+	// Dir is the directory where responses are stored. It is created if needed.
+	Dir string
+	// Transport is used to send requests that don't have recorded responses.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// ReplayOnly causes requests that don't have recorded responses to fail
+	// rather than being sent.
+	ReplayOnly bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	p := filepath.Join(c.Dir, cassetteKey(req, body)+".http")
+
+	if b, err := ioutil.ReadFile(p); err == nil {
+		return http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), req)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if c.ReplayOnly {
+		return nil, fmt.Errorf("no recorded response for %v %v", req.Method, req.URL)
+	}
+
+	tr := c.Transport
+	if tr == nil {
+		tr = http.DefaultTransport
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b, err := httputil.DumpResponse(resp, true) // also replaces resp.Body
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if err := ioutil.WriteFile(p, b, 0644); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cassetteKey returns a hex-encoded hash identifying req, whose body is supplied separately.
+func cassetteKey(req *http.Request, body []byte) string {
+	if mtype, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil &&
+		strings.HasPrefix(mtype, "multipart/") && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("boundary"))
+	}
+	bh := sha256.Sum256(body)
+	h := sha256.Sum256([]byte(req.Method + " " + req.URL.String() + " " + hex.EncodeToString(bh[:])))
+	return hex.EncodeToString(h[:])
+}
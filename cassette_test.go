@@ -0,0 +1,60 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	calls := 0
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, jigsawPage(jigsawRow("error", 1, "body", "Value Error : color zzz is not a color value")))
+	})
+
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	const doc = "body { color: zzz }"
+	check := func(replayOnly bool) []Issue {
+		cas := &Cassette{Dir: dir, ReplayOnly: replayOnly}
+		opts := &Options{Client: &http.Client{Transport: cas}}
+		issues, _, err := CSSWithOptions(context.Background(), strings.NewReader(doc), Stylesheet, opts)
+		if err != nil {
+			t.Fatal("CSSWithOptions failed: ", err)
+		}
+		return issues
+	}
+
+	recorded := check(false)
+	if calls != 1 {
+		t.Fatalf("Service got %d request(s) while recording; want 1", calls)
+	}
+	if fis, err := ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 1 {
+		t.Errorf("Cassette wrote %d file(s); want 1", len(fis))
+	}
+
+	replayed := check(true)
+	if calls != 1 {
+		t.Errorf("Service got %d request(s) after replaying; want 1", calls)
+	}
+	if missing, extra := CompareIssues(replayed, recorded); len(missing) > 0 || len(extra) > 0 {
+		t.Errorf("Replayed issues differ: missing %q, extra %q", missing, extra)
+	}
+
+	// A different request shouldn't be replayed.
+	cas := &Cassette{Dir: dir, ReplayOnly: true}
+	opts := &Options{Client: &http.Client{Transport: cas}}
+	if _, _, err := CSSWithOptions(context.Background(), strings.NewReader("p {}"), Stylesheet, opts); err == nil {
+		t.Error("CSSWithOptions unexpectedly succeeded for unrecorded request")
+	}
+}
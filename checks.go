@@ -0,0 +1,351 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultDeprecatedElements lists HTML4 elements reported by HTMLChecks
+// if CheckOptions.DeprecatedElements is nil.
+var DefaultDeprecatedElements = []string{
+	"acronym", "applet", "basefont", "big", "blink", "center", "dir", "font", "frame",
+	"frameset", "isindex", "marquee", "noframes", "strike", "tt",
+}
+
+// DefaultDeprecatedAttributes lists HTML4 presentational attributes reported by HTMLChecks
+// if CheckOptions.DeprecatedAttributes is nil.
+var DefaultDeprecatedAttributes = []string{
+	"align", "alink", "background", "bgcolor", "clear", "compact", "hspace", "link",
+	"nowrap", "text", "valign", "vlink", "vspace",
+}
+
+// CheckOptions configures the local checks performed by HTMLChecks.
+// A nil *CheckOptions is equivalent to the zero value, i.e. the defaults are used.
+type CheckOptions struct {
+	// SkipDeprecated disables reporting of deprecated elements and attributes.
+	SkipDeprecated bool
+	// DeprecatedElements lists element names that are reported as deprecated.
+	// If nil, DefaultDeprecatedElements is used.
+	DeprecatedElements []string
+	// DeprecatedAttributes lists attribute names that are reported as deprecated on any element.
+	// If nil, DefaultDeprecatedAttributes is used.
+	DeprecatedAttributes []string
+	// SkipResourceHints disables checking of <link> elements' rel and as attributes.
+	SkipResourceHints bool
+	// BaseURL is the URL from which the document is served. If non-nil, relative references in
+	// attributes like href and src are resolved against it (or against the document's <base>
+	// element) and reported if they're malformed. It isn't sent to validation services, which
+	// don't support base URLs for uploaded documents.
+	BaseURL *url.URL
+	// SkipARIA disables checking of role and aria-* attributes.
+	SkipARIA bool
+	// SkipCharset disables checking of <meta> character encoding declarations.
+	SkipCharset bool
+	// SkipViewport disables checking of <meta name="viewport"> content attributes.
+	SkipViewport bool
+	// SkipSocial disables checking of Open Graph and Twitter Card <meta> elements.
+	SkipSocial bool
+	// RequiredOpenGraph lists Open Graph properties (e.g. "og:image") that are reported as
+	// missing if a document contains other Open Graph properties.
+	// If nil, DefaultRequiredOpenGraph is used.
+	RequiredOpenGraph []string
+	// SkipCSP disables checking of <meta http-equiv="Content-Security-Policy"> policies.
+	SkipCSP bool
+	// SkipRenderBlocking disables reporting of external scripts in <head> that lack
+	// async and defer attributes.
+	SkipRenderBlocking bool
+	// SkipInlineImages disables reporting of large base64-encoded <img> data: URIs.
+	SkipInlineImages bool
+	// MaxInlineImageSize is the maximum decoded size in bytes of an inline image.
+	// If zero, DefaultMaxInlineImageSize is used.
+	MaxInlineImageSize int
+	// SkipLazyLoading disables reporting of <img> elements without loading attributes.
+	SkipLazyLoading bool
+	// EagerImages is the number of <img> elements at the start of the document that are
+	// assumed to be above the fold and aren't reported as missing loading="lazy".
+	// If zero, DefaultEagerImages is used. If negative, all images are checked.
+	EagerImages int
+	// Region requests that only issues located within the supplied region of the document
+	// be returned.
+	Region Region
+}
+
+// htmlCheck is a local check performed by HTMLChecks.
+// toks contains the document's tokens and opts is non-nil.
+type htmlCheck func(toks []lineToken, opts *CheckOptions) []Issue
+
+// htmlChecks lists the checks performed by HTMLChecks.
+var htmlChecks = []htmlCheck{
+	checkDeprecated,
+	checkResourceHints,
+	checkURLs,
+	checkARIA,
+	checkCharset,
+	checkViewport,
+	checkCSP,
+	checkSocial,
+	checkPerformance,
+}
+
+// HTMLChecks reads an HTML document from r and checks it locally for problems that aren't
+// reported by HTML. Issues are returned in order of increasing line number.
+// opts may be nil.
+func HTMLChecks(r io.Reader, opts *CheckOptions) ([]Issue, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &CheckOptions{}
+	}
+	toks := tokenizeLines(b)
+	var issues []Issue
+	for _, c := range htmlChecks {
+		issues = append(issues, c(toks, opts)...)
+	}
+	issues = filterRegion(issues, toks, opts.Region)
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues, nil
+}
+
+// stringSet returns a set containing the lowercase versions of vals,
+// or of defs if vals is nil.
+func stringSet(vals, defs []string) map[string]struct{} {
+	if vals == nil {
+		vals = defs
+	}
+	m := make(map[string]struct{}, len(vals))
+	for _, v := range vals {
+		m[strings.ToLower(v)] = struct{}{}
+	}
+	return m
+}
+
+// checkDeprecated reports deprecated elements and attributes.
+func checkDeprecated(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipDeprecated {
+		return nil
+	}
+	elems := stringSet(opts.DeprecatedElements, DefaultDeprecatedElements)
+	attrs := stringSet(opts.DeprecatedAttributes, DefaultDeprecatedAttributes)
+
+	var issues []Issue
+	for _, t := range toks {
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		if _, ok := elems[t.Data]; ok {
+			issues = append(issues, Issue{
+				Severity: Warning,
+				Line:     t.line,
+				Message:  fmt.Sprintf("Element %q is deprecated", t.Data),
+				Code:     "deprecated-element",
+			})
+		}
+		for _, a := range t.Attr {
+			if _, ok := attrs[a.Key]; ok {
+				issues = append(issues, Issue{
+					Severity: Warning,
+					Line:     t.line,
+					Message:  fmt.Sprintf("Attribute %q on element %q is deprecated", a.Key, t.Data),
+					Code:     "deprecated-attribute",
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// linkRels contains recognized values for <link> elements' rel attributes.
+var linkRels = stringSet([]string{
+	"alternate", "apple-touch-icon", "apple-touch-icon-precomposed", "author", "canonical",
+	"dns-prefetch", "expect", "help", "icon", "license", "manifest", "mask-icon", "me",
+	"modulepreload", "next", "pingback", "preconnect", "prefetch", "preload", "prerender",
+	"prev", "privacy-policy", "search", "shortcut", "stylesheet", "terms-of-service",
+}, nil)
+
+// preloadAs contains valid values for <link rel="preload"> elements' as attributes.
+var preloadAs = stringSet([]string{
+	"audio", "document", "embed", "fetch", "font", "image", "object", "script", "style",
+	"track", "video", "worker",
+}, nil)
+
+// checkResourceHints reports problems with <link> elements' rel, as, and crossorigin attributes.
+func checkResourceHints(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipResourceHints {
+		return nil
+	}
+	var issues []Issue
+	add := func(sev Severity, line int, code, msg string) {
+		issues = append(issues, Issue{Severity: sev, Line: line, Message: msg, Code: code})
+	}
+	for _, t := range toks {
+		if (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) || t.Data != "link" {
+			continue
+		}
+		rel, _ := tokenAttr(&t.Token, "rel")
+		rels := strings.Fields(strings.ToLower(rel))
+		preload := false
+		for _, r := range rels {
+			if _, ok := linkRels[r]; !ok {
+				add(Warning, t.line, "unknown-link-rel", fmt.Sprintf("Unknown link type %q", r))
+			}
+			if r == "preload" {
+				preload = true
+			}
+		}
+		if !preload {
+			continue
+		}
+		as, ok := tokenAttr(&t.Token, "as")
+		as = strings.ToLower(strings.TrimSpace(as))
+		if !ok || as == "" {
+			add(Error, t.line, "preload-missing-as", `Preload link missing "as" attribute`)
+			continue
+		}
+		if _, ok := preloadAs[as]; !ok {
+			add(Error, t.line, "preload-invalid-as", fmt.Sprintf("Preload link has invalid \"as\" value %q", as))
+		}
+		if _, ok := tokenAttr(&t.Token, "crossorigin"); as == "font" && !ok {
+			add(Error, t.line, "font-missing-crossorigin",
+				`Font preload link missing "crossorigin" attribute`)
+		}
+	}
+	return issues
+}
+
+// urlAttrs maps from element names to the names of their attributes that contain URLs.
+var urlAttrs = map[string]string{
+	"a": "href", "area": "href", "audio": "src", "embed": "src", "form": "action",
+	"iframe": "src", "img": "src", "link": "href", "script": "src", "source": "src",
+	"track": "src", "video": "src",
+}
+
+// checkURLs resolves URLs in the attributes listed in urlAttrs against opts.BaseURL
+// and reports ones that are unparseable or that resolve to malformed HTTP(S) URLs.
+func checkURLs(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.BaseURL == nil {
+		return nil
+	}
+	base := opts.BaseURL
+	for _, t := range toks {
+		if (t.Type == html.StartTagToken || t.Type == html.SelfClosingTagToken) && t.Data == "base" {
+			if href, ok := tokenAttr(&t.Token, "href"); ok {
+				if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+					base = base.ResolveReference(u)
+				}
+			}
+			break
+		}
+	}
+
+	var issues []Issue
+	for _, t := range toks {
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		name, ok := urlAttrs[t.Data]
+		if !ok {
+			continue
+		}
+		val, ok := tokenAttr(&t.Token, name)
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		ref, err := url.Parse(val)
+		if err != nil {
+			issues = append(issues, Issue{
+				Severity: Error,
+				Line:     t.line,
+				Message:  fmt.Sprintf("Attribute %q on element %q has unparseable URL %q", name, t.Data, val),
+				Code:     "invalid-url",
+			})
+			continue
+		}
+		u := base.ResolveReference(ref)
+		if (u.Scheme == "http" || u.Scheme == "https") && (u.Opaque != "" || u.Host == "") {
+			issues = append(issues, Issue{
+				Severity: Error,
+				Line:     t.line,
+				Message: fmt.Sprintf("Attribute %q on element %q has URL %q that resolves to malformed %q",
+					name, t.Data, val, u.String()),
+				Code: "invalid-url",
+			})
+		}
+	}
+	return issues
+}
+
+// maxCharsetOffset is the number of bytes at the start of a document within which
+// its character encoding declaration must appear.
+const maxCharsetOffset = 1024
+
+// checkCharset reports <meta> character encoding declarations that are repeated,
+// that conflict with each other, or that appear too late in the document.
+func checkCharset(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipCharset {
+		return nil
+	}
+	var issues []Issue
+	var first string // first declared charset
+	for _, t := range toks {
+		if (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) || t.Data != "meta" {
+			continue
+		}
+		cs, ok := tokenAttr(&t.Token, "charset")
+		if !ok {
+			equiv, _ := tokenAttr(&t.Token, "http-equiv")
+			content, _ := tokenAttr(&t.Token, "content")
+			if !strings.EqualFold(equiv, "content-type") {
+				continue
+			}
+			if _, params, err := mime.ParseMediaType(content); err == nil {
+				cs, ok = params["charset"], params["charset"] != ""
+			}
+			if !ok {
+				continue
+			}
+		}
+		cs = strings.ToLower(strings.TrimSpace(cs))
+
+		switch {
+		case first == "":
+			first = cs
+			if t.end > maxCharsetOffset {
+				issues = append(issues, Issue{
+					Severity: Warning,
+					Line:     t.line,
+					Message: fmt.Sprintf("Character encoding declaration not within first %d bytes",
+						maxCharsetOffset),
+					Code: "late-charset",
+				})
+			}
+		case cs != first:
+			issues = append(issues, Issue{
+				Severity: Error,
+				Line:     t.line,
+				Message:  fmt.Sprintf("Character encoding %q conflicts with earlier %q", cs, first),
+				Code:     "conflicting-charset",
+			})
+		default:
+			issues = append(issues, Issue{
+				Severity: Error,
+				Line:     t.line,
+				Message:  "Duplicate character encoding declaration",
+				Code:     "duplicate-charset",
+			})
+		}
+	}
+	return issues
+}
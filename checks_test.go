@@ -0,0 +1,121 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkIssues runs HTMLChecks on doc and returns the issues' codes and lines
+// formatted as "line code".
+func checkIssues(t *testing.T, doc string, opts *CheckOptions) []string {
+	issues, err := HTMLChecks(strings.NewReader(doc), opts)
+	if err != nil {
+		t.Fatalf("HTMLChecks(%q) failed: %v", doc, err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, fmt.Sprintf("%d %s", is.Line, is.Code))
+	}
+	return got
+}
+
+func TestHTMLChecks_Deprecated(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <body>
+    <center>Old-school</center>
+    <p align="right">Right-aligned</p>
+    <p class="fine">Fine</p>
+  </body>
+</html>
+`
+	if got, want := checkIssues(t, doc, nil),
+		[]string{"4 deprecated-element", "5 deprecated-attribute"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+	opts := &CheckOptions{DeprecatedElements: []string{}, DeprecatedAttributes: []string{"class"}}
+	if got, want := checkIssues(t, doc, opts),
+		[]string{"6 deprecated-attribute"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks with custom lists returned %q; want %q", got, want)
+	}
+	if got := checkIssues(t, doc, &CheckOptions{SkipDeprecated: true}); len(got) != 0 {
+		t.Errorf("HTMLChecks with SkipDeprecated returned %q", got)
+	}
+}
+
+func TestHTMLChecks_ResourceHints(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <link rel="stylesheet" href="style.css">
+    <link rel="preload" href="hero.jpg">
+    <link rel="preload" href="font.woff2" as="font" type="font/woff2">
+    <link rel="preload" href="font2.woff2" as="font" crossorigin>
+    <link rel="preload" href="data.bin" as="binary">
+    <link rel="stylsheet" href="typo.css">
+  </head>
+</html>
+`
+	want := []string{
+		"5 preload-missing-as",
+		"6 font-missing-crossorigin",
+		"8 preload-invalid-as",
+		"9 unknown-link-rel",
+	}
+	if got := checkIssues(t, doc, &CheckOptions{SkipDeprecated: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+}
+
+func TestHTMLChecks_Charset(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		head string
+		want []string
+	}{
+		{"ok", `<meta charset="utf-8">`, nil},
+		{"duplicate", "<meta charset=\"utf-8\">\n<meta charset=\"UTF-8\">", []string{"5 duplicate-charset"}},
+		{"conflict", "<meta charset=\"utf-8\">\n" +
+			`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">`,
+			[]string{"5 conflicting-charset"}},
+		{"late", "<title>" + strings.Repeat("x", 1024) + "</title>\n<meta charset=\"utf-8\">",
+			[]string{"5 late-charset"}},
+	} {
+		doc := "<!DOCTYPE html>\n<html>\n<head>\n" + tc.head + "\n</head>\n</html>\n"
+		if got := checkIssues(t, doc, nil); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: HTMLChecks returned %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHTMLChecks_URLs(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <link rel="stylesheet" href="../css/style.css">
+  </head>
+  <body>
+    <a href="http:page.html">Missing slashes</a>
+    <img src="images/%zz.png" alt="Bad escape">
+    <a href="mailto:me@example.org">Mail</a>
+  </body>
+</html>
+`
+	if got := checkIssues(t, doc, nil); len(got) != 0 {
+		t.Errorf("HTMLChecks without base URL returned %q", got)
+	}
+	base, err := url.Parse("https://example.org/dir/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"7 invalid-url", "8 invalid-url"}
+	if got := checkIssues(t, doc, &CheckOptions{BaseURL: base}); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteCheckstyle writes results (keyed by file path, e.g. as returned by AMPFiles) to w
+// as Checkstyle XML, which is understood by many editors and CI systems. Files are written
+// in lexical order. Each issue's Code is reported as the error's source.
+func WriteCheckstyle(w io.Writer, results map[string][]Issue) error {
+	type errorElem struct {
+		Line     int    `xml:"line,attr"`
+		Column   int    `xml:"column,attr,omitempty"`
+		Severity string `xml:"severity,attr"`
+		Message  string `xml:"message,attr"`
+		Source   string `xml:"source,attr,omitempty"`
+	}
+	type fileElem struct {
+		Name   string      `xml:"name,attr"`
+		Errors []errorElem `xml:"error"`
+	}
+	type checkstyleElem struct {
+		XMLName xml.Name   `xml:"checkstyle"`
+		Version string     `xml:"version,attr"`
+		Files   []fileElem `xml:"file"`
+	}
+
+	paths := make([]string, 0, len(results))
+	for p := range results {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	root := checkstyleElem{Version: "4.3"}
+	for _, p := range paths {
+		f := fileElem{Name: p}
+		for _, is := range results[p] {
+			f.Errors = append(f.Errors, errorElem{
+				Line:     is.Line,
+				Column:   is.Col,
+				Severity: strings.ToLower(is.Severity.String()),
+				Message:  is.Message,
+				Source:   is.Code,
+			})
+		}
+		root.Files = append(root.Files, f)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
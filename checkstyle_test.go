@@ -0,0 +1,39 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCheckstyle(t *testing.T) {
+	results := map[string][]Issue{
+		"style.css": {{Severity: Warning, Line: 3, Message: "Unknown vendor extension"}},
+		"index.html": {
+			{Severity: Error, Line: 1, Col: 5, Message: `Bad "value" & more`, Code: "bad-value"},
+			{Severity: Info, Line: 7, Message: "Note", Code: "mixed-line-endings"},
+		},
+		"empty.html": nil,
+	}
+	const want = `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="empty.html"></file>
+  <file name="index.html">
+    <error line="1" column="5" severity="error" message="Bad &#34;value&#34; &amp; more" source="bad-value"></error>
+    <error line="7" severity="info" message="Note" source="mixed-line-endings"></error>
+  </file>
+  <file name="style.css">
+    <error line="3" severity="warning" message="Unknown vendor extension"></error>
+  </file>
+</checkstyle>
+`
+	var b bytes.Buffer
+	if err := WriteCheckstyle(&b, results); err != nil {
+		t.Fatal("WriteCheckstyle failed: ", err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("WriteCheckstyle wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
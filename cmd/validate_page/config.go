@@ -0,0 +1,118 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/derat/validate"
+)
+
+// defaultConfig is the config file that is loaded from the current directory if -config isn't passed.
+const defaultConfig = ".validaterc.json"
+
+// config holds settings loaded from a JSON config file.
+// Values are overridden by the corresponding command-line flags.
+type config struct {
+	// Ignore lists issue codes that should be omitted from results.
+	Ignore []string `json:"ignore"`
+	// MinSeverity contains the minimum severity of reported issues (see parseSeverity).
+	MinSeverity string `json:"minSeverity"`
+
+	// The remaining fields correspond to fields in validate.Options.
+	MaxResponseSize        int64 `json:"maxResponseSize"`
+	JSON                   bool  `json:"json"`
+	RetryAlternateFormat   bool  `json:"retryAlternateFormat"`
+	ContextLines           int   `json:"contextLines"`
+	NormalizeLineEndings   bool  `json:"normalizeLineEndings"`
+	ReportMixedLineEndings bool  `json:"reportMixedLineEndings"`
+	FailFast               bool  `json:"failFast"`
+	RawAMPIssues           bool  `json:"rawAMPIssues"`
+}
+
+// loadConfig reads the JSON config file at p. If p is empty, defaultConfig is read
+// if it exists, and an empty config is returned otherwise.
+func loadConfig(p string) (*config, error) {
+	var cfg config
+	if p == "" {
+		if _, err := os.Stat(defaultConfig); os.IsNotExist(err) {
+			return &cfg, nil
+		}
+		p = defaultConfig
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%v: %v", p, err)
+	}
+	return &cfg, nil
+}
+
+// options returns library options corresponding to cfg.
+func (cfg *config) options() *validate.Options {
+	return &validate.Options{
+		MaxResponseSize:        cfg.MaxResponseSize,
+		JSON:                   cfg.JSON,
+		RetryAlternateFormat:   cfg.RetryAlternateFormat,
+		ContextLines:           cfg.ContextLines,
+		NormalizeLineEndings:   cfg.NormalizeLineEndings,
+		ReportMixedLineEndings: cfg.ReportMixedLineEndings,
+		FailFast:               cfg.FailFast,
+		RawAMPIssues:           cfg.RawAMPIssues,
+	}
+}
+
+// filter returns the issues that don't have ignored codes and are at least as severe as minSev.
+func (cfg *config) filter(issues []validate.Issue, minSev validate.Severity) []validate.Issue {
+	ignored := make(map[string]struct{}, len(cfg.Ignore))
+	for _, code := range cfg.Ignore {
+		ignored[code] = struct{}{}
+	}
+	var out []validate.Issue
+	for _, is := range issues {
+		if _, ok := ignored[is.Code]; ok && is.Code != "" {
+			continue
+		}
+		if is.Severity > minSev {
+			continue
+		}
+		out = append(out, is)
+	}
+	return out
+}
+
+// parseSeverity parses a severity as passed to the -min-severity flag.
+// An empty string is equivalent to "info".
+func parseSeverity(s string) (validate.Severity, error) {
+	switch strings.ToLower(s) {
+	case "error":
+		return validate.Error, nil
+	case "warning":
+		return validate.Warning, nil
+	case "info", "":
+		return validate.Info, nil
+	default:
+		return 0, fmt.Errorf("bad severity %q", s)
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, it := range strings.Split(s, ",") {
+		if it = strings.TrimSpace(it); it != "" {
+			items = append(items, it)
+		}
+	}
+	return items
+}
@@ -7,119 +7,650 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"html/template"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/derat/validate"
 )
 
+// exitValidationErrors is the default value of the -error-code flag. It's a bit that
+// doesn't overlap the codes used when validation couldn't be performed (1) or when the
+// program is misused (2), so it can be combined with -warning-code.
+const exitValidationErrors = 4
+
+// exitValidationWarnings is the default value of the -warning-code flag if -min-severity is set.
+const exitValidationWarnings = 8
+
+// Validation function. Overridden by tests.
+var validateDoc = validate.ValidateWithOptions
+
 func main() {
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTION] <FILE>\n"+
-			"Validate an HTML or CSS document.\n"+
-			"If <FILE> isn't supplied, reads from stdin.\n\n", os.Args[0])
-		flag.PrintDefaults()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the program with the supplied command-line arguments (excluding the program name)
+// and returns the process's exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s [OPTION] [FILE]...\n"+
+			"Validate HTML or CSS documents.\n"+
+			"If <FILE> isn't supplied, reads from stdin.\n"+
+			"Exits with 1 if validation couldn't be performed or 2 for bad usage. Otherwise, the exit code\n"+
+			"contains the bits from -error-code and -warning-code if errors or warnings are found.\n\n",
+			os.Args[0])
+		fs.PrintDefaults()
 	}
-	browser := flag.Bool("browser", false,
+	browser := fs.Bool("browser", false,
 		"Display validation issues in browser (printed to stdout otherwise)")
-	fileType := flag.String("type", "",
-		`File type: "amp", "css", "html", "htmlcss" (validate CSS in HTML); inferred if empty`)
-	flag.Parse()
-
-	var r io.Reader
-	var p string // file path; empty for stdin
-	switch len(flag.Args()) {
-	case 0:
-		r = os.Stdin
-	case 1:
-		p = flag.Arg(0)
-		if *fileType == "" {
-			if strings.HasSuffix(p, ".amp") || strings.HasSuffix(p, ".amp.html") {
-				*fileType = "amp"
-			} else if strings.HasSuffix(p, ".css") {
-				*fileType = "css"
-			} else if strings.HasSuffix(p, ".html") || strings.HasSuffix(p, ".htm") {
-				*fileType = "html"
+	cfgPath := fs.String("config", "",
+		"JSON config file supplying default options (default "+defaultConfig+" if present)")
+	concurrency := fs.Int("concurrency", 4,
+		"Maximum number of files to validate concurrently with -dir or multiple files")
+	dir := fs.String("dir", "",
+		"Validate all supported files within the supplied directory")
+	errorCode := fs.Int("error-code", exitValidationErrors,
+		"Bits to set in exit code if errors are found")
+	failFast := fs.Bool("fail-fast", false,
+		"Stop validating files after the first error with -dir")
+	fileType := fs.String("type", "",
+		`File type: "amp", "css", "html", "htmlcss" (validate CSS in HTML), "robots", "sitemap", "xhtml"; `+
+			`inferred if empty`)
+	format := fs.String("format", "text",
+		`Output format: "text", "json", "checkstyle", "junit", or "tap"`)
+	ignore := fs.String("ignore", "",
+		"Comma-separated issue codes to omit from results")
+	manifest := fs.Bool("manifest", false,
+		`Validate documents listed in a JSON manifest read from stdin, e.g. `+
+			`[{"id":"a","type":"html","content":"<!DOCTYPE html>..."},{"id":"b","path":"style.css"}]`)
+	minSeverity := fs.String("min-severity", "",
+		`Minimum severity of reported issues: "error", "warning", or "info" (default); `+
+			`if set, reported warnings also affect the exit code (see -warning-code)`)
+	saveResults := fs.String("save-results", "",
+		"Write the validator's raw results page for a single document to the supplied path")
+	serveMode := fs.Bool("serve", false,
+		`Read newline-delimited JSON requests like {"type":"html","content":"..."} from stdin `+
+			`and write a JSON response like {"issues":[...]} to stdout for each`)
+	stream := fs.Bool("stream", false,
+		"Print each file's results as soon as they're available with -dir and -format=text")
+	split := fs.String("split", "",
+		`Validate multiple documents from stdin separated by lines containing the supplied marker `+
+			`(or by NUL bytes if "`+nulSeparator+`")`)
+	rate := fs.Duration("rate", time.Second,
+		"Minimum interval between requests to network validators with -dir or multiple files")
+	summary := fs.Bool("summary", false,
+		`Print a final "SUMMARY errors=N warnings=N files=N" line`)
+	warningCode := fs.Int("warning-code", 0,
+		fmt.Sprintf("Bits to set in exit code if warnings are found (%d if -min-severity is set)",
+			exitValidationWarnings))
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	switch *format {
+	case "text", "json", "checkstyle", "junit", "tap":
+	default:
+		fmt.Fprintf(stderr, "Bad -format value %q\n", *format)
+		return 2
+	}
+
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "Failed to load config:", err)
+		return 2
+	}
+	// Flags that were explicitly passed override the config file.
+	warningCodeSet := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "warning-code":
+			warningCodeSet = true
+		case "ignore":
+			cfg.Ignore = splitList(*ignore)
+		case "min-severity":
+			cfg.MinSeverity = *minSeverity
+		case "fail-fast":
+			cfg.FailFast = *failFast
+		}
+	})
+	minSev, err := parseSeverity(cfg.MinSeverity)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	// Gate on warnings too if the user asked for them to be reported.
+	if cfg.MinSeverity != "" && !warningCodeSet {
+		*warningCode = exitValidationWarnings
+	}
+	opts := cfg.options()
+	opts.RequestInterval = *rate
+
+	ctx := context.Background()
+	var results map[string][]validate.Issue // keyed by path or document number; only used with -dir or -split
+	var issues []validate.Issue             // all issues
+	nfiles := 1
+
+	if *saveResults != "" && (*dir != "" || *split != "" || *manifest || len(fs.Args()) > 1) {
+		fs.Usage()
+		return 2
+	}
+
+	// -dir, -split, and -manifest select mutually-exclusive modes that don't accept file arguments.
+	nmodes := 0
+	for _, set := range []bool{*dir != "", *split != "", *manifest, len(fs.Args()) > 0} {
+		if set {
+			nmodes++
+		}
+	}
+	if nmodes > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if *serveMode {
+		if len(fs.Args()) > 0 || *dir != "" || *split != "" || *manifest || *browser || *stream ||
+			*saveResults != "" || *summary {
+			fs.Usage()
+			return 2
+		}
+		filter := func(issues []validate.Issue) []validate.Issue { return cfg.filter(issues, minSev) }
+		if err := serve(ctx, stdin, stdout, opts, filter); err != nil {
+			fmt.Fprintln(stderr, "Serving failed:", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *dir != "" {
+		if *browser || *fileType != "" || (*stream && *format != "text") {
+			fs.Usage()
+			return 2
+		}
+		var emit func(string, []validate.Issue)
+		var emitErr error
+		if *stream {
+			emit = func(p string, issues []validate.Issue) {
+				if err := writeFileIssues(stdout, p, cfg.filter(issues, minSev)); err != nil && emitErr == nil {
+					emitErr = err
+				}
 			}
 		}
-		f, err := os.Open(p)
+		var scanErr error
+		results, scanErr = scanDir(ctx, *dir, *concurrency, opts, emit)
+		if scanErr == validate.ErrAborted {
+			fmt.Fprintln(stderr, "Stopped after first error")
+			scanErr = nil
+		} else if scanErr != nil && results == nil {
+			fmt.Fprintln(stderr, "Validation failed:", scanErr)
+			return 1
+		}
+		for p, fi := range results {
+			results[p] = cfg.filter(fi, minSev)
+		}
+		var err error
+		if *stream {
+			err = emitErr
+			if err == nil {
+				err = writeTotal(stdout, results)
+			}
+		} else {
+			err = writeResults(stdout, results, nil, *format)
+		}
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to open input file:", err)
-			os.Exit(1)
+			fmt.Fprintln(stderr, "Failed writing results:", err)
+			return 1
 		}
-		defer f.Close()
-		r = f
-	default:
-		flag.Usage()
-		os.Exit(2)
-	}
-
-	if *fileType == "" {
-		br := bufio.NewReader(r)
-		r = br
-		b, err := br.Peek(512)
-		if err != nil && err != io.EOF {
-			fmt.Fprintln(os.Stderr, "Failed to read file to infer type:", err)
-			os.Exit(1)
-		}
-		ctype := http.DetectContentType(b)
-		switch {
-		case strings.HasPrefix(ctype, "text/html"):
-			lower := strings.ToLower(string(b))
-			if strings.Contains(lower, "<html amp>") || strings.Contains(lower, "<html ⚡>") {
-				*fileType = "amp"
-			} else {
-				*fileType = "html"
-			}
-		case strings.HasPrefix(ctype, "text/plain"): // all we get for stylesheets :-/
-			*fileType = "css"
+		// Report files that couldn't be validated after the results from the others.
+		if scanErr != nil {
+			fmt.Fprintln(stderr, "Validation failed:", scanErr)
+			return 1
+		}
+		for _, fi := range results {
+			issues = append(issues, fi...)
+		}
+		nfiles = len(results)
+	} else if *split != "" {
+		if *browser || *stream {
+			fs.Usage()
+			return 2
+		}
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Failed to read stdin:", err)
+			return 1
+		}
+		docs := splitDocs(b, *split)
+		results = make(map[string][]validate.Issue, len(docs))
+		keys := make([]string, len(docs))
+		for i, doc := range docs {
+			ft := *fileType
+			if ft == "" {
+				var ctype string
+				if ft, ctype = inferType(doc); ft == "" {
+					fmt.Fprintf(stderr, "Inferred unsupported file type %q for document %d; pass -type\n", ctype, i+1)
+					return 1
+				}
+			}
+			di, _, err := validateFile(ctx, bytes.NewReader(doc), ft, opts)
+			if err == errBadType {
+				fmt.Fprintf(stderr, "Bad -type value %q\n", ft)
+				return 2
+			} else if err != nil {
+				fmt.Fprintf(stderr, "Validation request for document %d failed: %v\n", i+1, err)
+				return 1
+			}
+			di = cfg.filter(di, minSev)
+			keys[i] = docKey(i)
+			results[keys[i]] = di
+			issues = append(issues, di...)
+		}
+		if err := writeResults(stdout, results, keys, *format); err != nil {
+			fmt.Fprintln(stderr, "Failed writing results:", err)
+			return 1
+		}
+		nfiles = len(docs)
+	} else if *manifest {
+		if *browser || *stream {
+			fs.Usage()
+			return 2
+		}
+		docs, err := readManifest(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Failed to read manifest:", err)
+			return 1
+		}
+		results = make(map[string][]validate.Issue, len(docs))
+		keys := make([]string, len(docs))
+		for i, d := range docs {
+			var b []byte
+			if d.Content != nil {
+				b = []byte(*d.Content)
+			} else if b, err = ioutil.ReadFile(d.Path); err != nil {
+				fmt.Fprintf(stderr, "Failed to read %q: %v\n", d.ID, err)
+				return 1
+			}
+			ft := d.Type
+			if ft == "" && d.Path != "" {
+				ft = typeFromPath(d.Path)
+			}
+			if ft == "" {
+				var ctype string
+				if ft, ctype = inferType(b); ft == "" {
+					fmt.Fprintf(stderr, "Inferred unsupported file type %q for %q; set type\n", ctype, d.ID)
+					return 1
+				}
+			}
+			di, _, err := validateFile(ctx, bytes.NewReader(b), ft, opts)
+			if err == errBadType {
+				fmt.Fprintf(stderr, "Bad type %q for %q\n", ft, d.ID)
+				return 2
+			} else if err != nil {
+				fmt.Fprintf(stderr, "Validation request for %q failed: %v\n", d.ID, err)
+				return 1
+			}
+			di = cfg.filter(di, minSev)
+			keys[i] = d.ID
+			results[d.ID] = di
+			issues = append(issues, di...)
+		}
+		if err := writeResults(stdout, results, keys, *format); err != nil {
+			fmt.Fprintln(stderr, "Failed writing results:", err)
+			return 1
+		}
+		nfiles = len(docs)
+	} else if len(fs.Args()) > 1 {
+		if *browser || *stream {
+			fs.Usage()
+			return 2
+		}
+		var valErr error
+		results, valErr = validateFiles(ctx, fs.Args(), *fileType, *concurrency, opts)
+		var paths []string // successfully-validated paths in the order they were supplied
+		for _, p := range fs.Args() {
+			if fi, ok := results[p]; ok {
+				results[p] = cfg.filter(fi, minSev)
+				issues = append(issues, results[p]...)
+				paths = append(paths, p)
+			}
+		}
+		if err := writeResults(stdout, results, paths, *format); err != nil {
+			fmt.Fprintln(stderr, "Failed writing results:", err)
+			return 1
+		}
+		// Report files that couldn't be validated after the results from the others.
+		if valErr != nil {
+			fmt.Fprintln(stderr, "Validation failed:", valErr)
+			return 1
+		}
+		nfiles = len(paths)
+	} else {
+		var r io.Reader
+		var p string // file path; empty for stdin
+		switch len(fs.Args()) {
+		case 0:
+			r = stdin
+		case 1:
+			p = fs.Arg(0)
+			if strings.HasPrefix(p, "data:") {
+				mtype, data, err := validate.ParseDataURI(p)
+				if err != nil {
+					fmt.Fprintln(stderr, "Failed to parse data: URI:", err)
+					return 1
+				}
+				if *fileType == "" {
+					*fileType = typeFromMediaType(mtype)
+				}
+				r = bytes.NewReader(data)
+				break
+			}
+			if *fileType == "" {
+				*fileType = typeFromPath(p)
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				fmt.Fprintln(stderr, "Failed to open input file:", err)
+				return 1
+			}
+			defer f.Close()
+			r = f
 		default:
-			fmt.Fprintf(os.Stderr, "Inferred unsupported file type %q; pass -type\n", ctype)
-			os.Exit(1)
+			fs.Usage()
+			return 2
 		}
-	}
 
-	var issues []validate.Issue
-	var out []byte
-	var err error
+		if *fileType == "" {
+			br := bufio.NewReader(r)
+			r = br
+			b, err := br.Peek(512)
+			if err != nil && err != io.EOF {
+				fmt.Fprintln(stderr, "Failed to read file to infer type:", err)
+				return 1
+			}
+			var ctype string
+			if *fileType, ctype = inferType(b); *fileType == "" {
+				fmt.Fprintf(stderr, "Inferred unsupported file type %q; pass -type\n", ctype)
+				return 1
+			}
+		}
+
+		var out []byte
+		var err error
+		if issues, out, err = validateFile(ctx, r, *fileType, opts); err == errBadType {
+			fmt.Fprintf(stderr, "Bad -type value %q\n", *fileType)
+			return 2
+		} else if err != nil {
+			fmt.Fprintln(stderr, "Validation request failed:", err)
+			return 1
+		}
+		issues = cfg.filter(issues, minSev)
 
-	switch *fileType {
-	case "amp":
-		// amphtml-validator doesn't generate a results page, so make our own.
-		issues, err = validate.AMP(context.Background(), r)
-		if err == nil && *browser {
-			out, err = makeAMPResultsPage(issues)
+		// Some validators don't generate results pages, so make our own.
+		if out == nil && (*browser || *saveResults != "") {
+			if out, err = validate.RenderResultsPage(issues); err != nil {
+				fmt.Fprintln(stderr, "Failed to render results:", err)
+				return 1
+			}
+		}
+		if *saveResults != "" {
+			if err := writeFile(*saveResults, out); err != nil {
+				fmt.Fprintln(stderr, "Failed to save results:", err)
+				return 1
+			}
 		}
-	case "css":
-		issues, out, err = validate.CSS(context.Background(), r, validate.Stylesheet)
-	case "html":
-		issues, out, err = validate.HTML(context.Background(), r)
-	case "htmlcss":
-		issues, out, err = validate.CSS(context.Background(), r, validate.HTMLDoc)
+
+		if *browser {
+			if err := validate.LaunchBrowser(out); err != nil {
+				fmt.Fprintln(stderr, "Failed to display results in browser:", err)
+				return 1
+			}
+		} else if err := writeIssues(stdout, p, issues, *format); err != nil {
+			fmt.Fprintln(stderr, "Failed writing results:", err)
+			return 1
+		}
+	}
+
+	var nerrors, nwarnings int
+	for _, is := range issues {
+		switch is.Severity {
+		case validate.Error:
+			nerrors++
+		case validate.Warning:
+			nwarnings++
+		}
+	}
+	if *summary {
+		fmt.Fprintf(stdout, "SUMMARY errors=%d warnings=%d files=%d\n", nerrors, nwarnings, nfiles)
+	}
+
+	code := 0
+	if nerrors > 0 {
+		code |= *errorCode
+	}
+	if nwarnings > 0 {
+		code |= *warningCode
+	}
+	return code
+}
+
+// typeFromPath returns the file type (as passed to the -type flag) of the file at p
+// based on its name, or an empty string if the type can't be inferred.
+func typeFromPath(p string) string {
+	switch base := filepath.Base(p); {
+	case strings.HasSuffix(p, ".amp") || strings.HasSuffix(p, ".amp.html"):
+		return "amp"
+	case strings.HasSuffix(p, ".css"):
+		return "css"
+	case strings.HasSuffix(p, ".html") || strings.HasSuffix(p, ".htm"):
+		return "html"
+	case base == "robots.txt":
+		return "robots"
+	case base == "sitemap.xml":
+		return "sitemap"
+	case strings.HasSuffix(p, ".xhtml"):
+		return "xhtml"
 	default:
-		fmt.Fprintf(os.Stderr, "Bad -type value %q\n", *fileType)
-		os.Exit(2)
+		return ""
 	}
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Validation request failed:", err)
-		os.Exit(1)
+}
+
+// typeFromMediaType returns the file type (as passed to the -type flag) corresponding
+// to the supplied media type, or an empty string if the type is unsupported.
+func typeFromMediaType(mtype string) string {
+	switch mtype {
+	case string(validate.HTMLDoc):
+		return "html"
+	case string(validate.Stylesheet):
+		return "css"
+	case string(validate.XHTMLDoc):
+		return "xhtml"
+	default:
+		return ""
 	}
+}
 
-	if *browser {
-		if err := validate.LaunchBrowser(out); err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to display results in browser:", err)
-			os.Exit(1)
+// inferType infers the file type (as passed to the -type flag) of a document
+// starting with b. If the type is unsupported, an empty string is returned along
+// with the detected MIME type.
+func inferType(b []byte) (fileType, ctype string) {
+	ft, err := validate.DetectType(b)
+	if ute, ok := err.(*validate.UnsupportedTypeError); ok {
+		return "", ute.MediaType
+	}
+	for name, dt := range docTypes {
+		if dt == ft {
+			return name, string(ft)
 		}
-	} else {
+	}
+	return "", string(ft)
+}
+
+// nulSeparator is the -split value used to split documents on NUL bytes.
+const nulSeparator = `\0`
+
+// splitDocs splits b into documents separated by lines consisting of sep, or by
+// NUL bytes if sep is nulSeparator. Empty documents are omitted.
+func splitDocs(b []byte, sep string) [][]byte {
+	var docs [][]byte
+	add := func(d []byte) {
+		if len(bytes.TrimSpace(d)) > 0 {
+			docs = append(docs, d)
+		}
+	}
+	if sep == nulSeparator {
+		for _, d := range bytes.Split(b, []byte{0}) {
+			add(d)
+		}
+		return docs
+	}
+	var cur []byte
+	for _, ln := range bytes.SplitAfter(b, []byte("\n")) {
+		if string(bytes.TrimRight(ln, "\r\n")) == sep {
+			add(cur)
+			cur = nil
+		} else {
+			cur = append(cur, ln...)
+		}
+	}
+	add(cur)
+	return docs
+}
+
+// docKey returns the key used to report results for the i'th (0-indexed) document with -split.
+func docKey(i int) string { return fmt.Sprintf("#%d", i+1) }
+
+// errBadType is returned by validateFile if an unsupported file type is supplied.
+var errBadType = fmt.Errorf("unsupported file type")
+
+// validateFile validates the file of type fileType (as passed to the -type flag) in r.
+// The returned results page is nil if the validator doesn't generate one.
+func validateFile(ctx context.Context, r io.Reader, fileType string, opts *validate.Options) (
+	[]validate.Issue, []byte, error) {
+	switch fileType {
+	case "robots":
+		issues, err := validate.Robots(r)
+		return issues, nil, err
+	case "sitemap":
+		issues, err := validate.Sitemap(r)
+		return issues, nil, err
+	}
+	ft, ok := docTypes[fileType]
+	if !ok {
+		return nil, nil, errBadType
+	}
+	return validateDoc(ctx, r, ft, opts)
+}
+
+// docTypes maps from -type flag values to the corresponding types accepted by validateDoc.
+var docTypes = map[string]validate.FileType{
+	"amp":     validate.AMPDoc,
+	"css":     validate.Stylesheet,
+	"html":    validate.HTMLDoc,
+	"htmlcss": validate.HTMLCSS,
+	"xhtml":   validate.XHTMLDoc,
+}
+
+// writeIssues writes issues from a single file at p (empty for stdin) to w in the supplied format.
+func writeIssues(w io.Writer, p string, issues []validate.Issue, format string) error {
+	if format == "checkstyle" || format == "junit" || format == "tap" {
+		if p == "" {
+			p = "-"
+		}
+		return writeResults(w, map[string][]validate.Issue{p: issues}, nil, format)
+	}
+	if format == "json" {
+		if issues == nil {
+			issues = []validate.Issue{}
+		}
+		return writeJSON(w, issues)
+	}
+	for _, is := range issues {
+		if _, err := fmt.Fprintln(w, is); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeResults writes issues from multiple files (keyed by path) to w in the supplied format.
+// keys lists the keys of results in the order in which text output should be written;
+// if nil, the keys are sorted.
+func writeResults(w io.Writer, results map[string][]validate.Issue, keys []string, format string) error {
+	if format == "checkstyle" {
+		return validate.WriteCheckstyle(w, results)
+	}
+	if format == "junit" {
+		return validate.WriteJUnit(w, results)
+	}
+	if format == "tap" {
+		return validate.WriteTAP(w, results)
+	}
+	if format == "json" {
+		return writeJSON(w, results)
+	}
+	if keys == nil {
+		keys = make([]string, 0, len(results))
+		for p := range results {
+			keys = append(keys, p)
+		}
+		sort.Strings(keys)
+	}
+	for _, p := range keys {
+		if err := writeFileIssues(w, p, results[p]); err != nil {
+			return err
+		}
+	}
+	return writeTotal(w, results)
+}
+
+// writeFileIssues writes a text line to w for each of issues from the file at p.
+func writeFileIssues(w io.Writer, p string, issues []validate.Issue) error {
+	for _, is := range issues {
+		if _, err := fmt.Fprintf(w, "%s:%v\n", p, is); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTotal writes a text line to w summarizing the number of issues in results.
+func writeTotal(w io.Writer, results map[string][]validate.Issue) error {
+	var nerrors, nwarnings int
+	for _, issues := range results {
 		for _, is := range issues {
-			fmt.Println(is)
+			switch is.Severity {
+			case validate.Error:
+				nerrors++
+			case validate.Warning:
+				nwarnings++
+			}
 		}
 	}
+	_, err := fmt.Fprintf(w, "%d error(s) and %d warning(s) in %d file(s)\n", nerrors, nwarnings, len(results))
+	return err
+}
+
+// writeFile writes b to the file at p, creating its parent directories if needed.
+func writeFile(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, b, 0644)
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
 }
 
 // guessType attempts to infer the MIME type of the data in r,
@@ -131,29 +662,3 @@ func guessType(r bufio.Reader) (string, error) {
 	}
 	return http.DetectContentType(b), nil
 }
-
-// makeAMPResultsPage generates a minimal HTML page listing the supplied issues.
-func makeAMPResultsPage(issues []validate.Issue) ([]byte, error) {
-	tmpl := template.Must(template.New("").Parse(`<DOCTYPE html>
-<html lang="en">
-  <head>
-    <meta charset="utf-8">
-    <title>AMP validation results</title>
-  </head>
-  <body>
-{{- if not .}}
-    No issues found.
-{{- else -}}
-{{range .}}
-    {{.Line}}:{{.Col}} {{.Severity}} {{.Message}} {{if .URL}}<a href="{{.URL}}">{{end}}{{.Code}}{{if .URL}}</a>{{end}}<br>
-{{- end}}
-{{- end}}
-  </body>
-</html>
-`))
-	var b bytes.Buffer
-	if err := tmpl.Execute(&b, issues); err != nil {
-		return nil, err
-	}
-	return b.Bytes(), nil
-}
@@ -0,0 +1,404 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/derat/validate"
+)
+
+// fakeValidators replaces the validation function with a fake that passes the -type value
+// ("amp", "css", "htmlcss", "html", or "xhtml") and document to f and returns its issues.
+// The original function is restored when the test completes.
+func fakeValidators(t *testing.T, f func(kind string, doc []byte) []validate.Issue) {
+	orig := validateDoc
+	validateDoc = func(ctx context.Context, r io.Reader, ft validate.FileType,
+		opts *validate.Options) ([]validate.Issue, []byte, error) {
+		b, err := ioutil.ReadAll(r)
+		for kind, dt := range docTypes {
+			if dt == ft {
+				var out []byte
+				if ft != validate.AMPDoc {
+					out = []byte("<html></html>")
+				}
+				return f(kind, b), out, err
+			}
+		}
+		return nil, nil, fmt.Errorf("unexpected file type %q", ft)
+	}
+	t.Cleanup(func() { validateDoc = orig })
+}
+
+// fakeHTML replaces the HTML validator with a function that returns issues.
+func fakeHTML(t *testing.T, issues []validate.Issue) {
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		if kind == "html" {
+			return issues
+		}
+		return nil
+	})
+}
+
+// runForTest calls run with the supplied args and stdin and returns its exit code and stdout.
+func runForTest(t *testing.T, args []string, stdin string) (int, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	if stderr.Len() > 0 {
+		t.Logf("stderr from %q: %s", args, stderr.String())
+	}
+	return code, stdout.String()
+}
+
+func TestRun_Summary(t *testing.T) {
+	fakeHTML(t, []validate.Issue{
+		{Severity: validate.Error, Line: 1, Col: 2, Message: "First error"},
+		{Severity: validate.Warning, Line: 3, Col: 4, Message: "A warning"},
+		{Severity: validate.Error, Line: 5, Col: 6, Message: "Second error"},
+	})
+	args := []string{"-type=html", "-summary", "-error-code=4", "-warning-code=8"}
+	code, out := runForTest(t, args, "<!DOCTYPE html>")
+	if want := 4 | 8; code != want {
+		t.Errorf("run(%q) returned %v; want %v", args, code, want)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if got, want := lines[len(lines)-1], "SUMMARY errors=2 warnings=1 files=1"; got != want {
+		t.Errorf("run(%q) printed final line %q; want %q", args, got, want)
+	}
+
+	// Without exit-code flags, errors should produce a distinct exit code.
+	if code, out := runForTest(t, []string{"-type=html"}, "<!DOCTYPE html>"); code != exitValidationErrors {
+		t.Errorf("run without exit-code flags returned %v; want %v", code, exitValidationErrors)
+	} else if strings.Contains(out, "SUMMARY") {
+		t.Errorf("run without -summary printed summary: %q", out)
+	}
+	// The default error code shouldn't overlap the codes used for operational failures.
+	args = []string{"-type=html", "-warning-code=8"}
+	if code, _ := runForTest(t, args, "<!DOCTYPE html>"); code != exitValidationErrors|8 || code&(1|2) != 0 {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors|8)
+	}
+	args = []string{"-type=html", "-error-code=0"}
+	if code, _ := runForTest(t, args, "<!DOCTYPE html>"); code != 0 {
+		t.Errorf("run(%q) returned %v; want 0", args, code)
+	}
+
+	// Warnings alone shouldn't affect the exit code by default.
+	fakeHTML(t, []validate.Issue{{Severity: validate.Warning, Line: 3, Col: 4, Message: "A warning"}})
+	if code, _ := runForTest(t, []string{"-type=html"}, "<!DOCTYPE html>"); code != 0 {
+		t.Errorf("run with only warnings returned %v; want 0", code)
+	}
+}
+
+func TestRun_MinSeverityExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		sev    validate.Severity // severity of the reported issue
+		args   []string
+		want   int
+		report bool // whether the issue should be printed
+	}{
+		{validate.Warning, nil, 0, true},
+		{validate.Warning, []string{"-min-severity=info"}, exitValidationWarnings, true},
+		{validate.Warning, []string{"-min-severity=warning"}, exitValidationWarnings, true},
+		{validate.Warning, []string{"-min-severity=error"}, 0, false},
+		{validate.Warning, []string{"-min-severity=warning", "-warning-code=0"}, 0, true},
+		{validate.Error, []string{"-min-severity=warning"}, exitValidationErrors, true},
+		{validate.Error, []string{"-min-severity=error"}, exitValidationErrors, true},
+		{validate.Info, []string{"-min-severity=info"}, 0, true},
+	} {
+		fakeHTML(t, []validate.Issue{{Severity: tc.sev, Line: 1, Message: "An issue"}})
+		args := append([]string{"-type=html"}, tc.args...)
+		code, out := runForTest(t, args, "<!DOCTYPE html>")
+		if code != tc.want {
+			t.Errorf("run(%q) with %v returned %v; want %v", args, tc.sev, code, tc.want)
+		}
+		if got := strings.Contains(out, "An issue"); got != tc.report {
+			t.Errorf("run(%q) with %v printed %q", args, tc.sev, out)
+		}
+	}
+}
+
+func TestRun_DataURI(t *testing.T) {
+	var got []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		got = append(got, kind+" "+string(doc))
+		return nil
+	})
+	for _, arg := range []string{
+		"data:text/html;base64,PCFET0NUWVBFIGh0bWw+PGJvZ3VzPg==",
+		"data:text/css,body%7Bcolor:zzz%7D",
+	} {
+		if code, _ := runForTest(t, []string{arg}, ""); code != 0 {
+			t.Errorf("run(%q) returned %v; want 0", arg, code)
+		}
+	}
+	want := []string{"html <!DOCTYPE html><bogus>", "css body{color:zzz}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validators got %q; want %q", got, want)
+	}
+}
+
+func TestRun_Config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{"ignore": ["noisy"], "minSeverity": "error"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeHTML(t, []validate.Issue{
+		{Severity: validate.Error, Line: 1, Message: "Ignored error", Code: "noisy"},
+		{Severity: validate.Error, Line: 2, Message: "Real error"},
+		{Severity: validate.Warning, Line: 3, Message: "A warning"},
+		{Severity: validate.Info, Line: 4, Message: "A note"},
+	})
+	for _, tc := range []struct {
+		args []string
+		want []string // messages
+		code int
+	}{
+		{[]string{"-config=" + cfg}, []string{"Real error"}, exitValidationErrors},
+		{[]string{"-config=" + cfg, "-min-severity=warning"}, []string{"Real error", "A warning"},
+			exitValidationErrors | exitValidationWarnings},
+		{[]string{"-config=" + cfg, "-ignore="}, []string{"Ignored error", "Real error"}, exitValidationErrors},
+	} {
+		args := append(tc.args, "-type=html", "-format=json")
+		code, out := runForTest(t, args, "<!DOCTYPE html>")
+		if code != tc.code {
+			t.Errorf("run(%q) returned %v; want %v", args, code, tc.code)
+			continue
+		}
+		var issues []validate.Issue
+		if err := json.Unmarshal([]byte(out), &issues); err != nil {
+			t.Errorf("run(%q) printed bad JSON %q: %v", args, out, err)
+			continue
+		}
+		got := make([]string, len(issues))
+		for i, is := range issues {
+			got[i] = is.Message
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("run(%q) reported %q; want %q", args, got, tc.want)
+		}
+	}
+
+	args := []string{"-config=" + filepath.Join(dir, "missing.json"), "-type=html"}
+	if code, _ := runForTest(t, args, "<!DOCTYPE html>"); code != 2 {
+		t.Errorf("run(%q) returned %v; want 2", args, code)
+	}
+}
+
+func TestRun_InferXHTML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "page.xhtml")
+	if err := ioutil.WriteFile(p, []byte(`<html xmlns="http://www.w3.org/1999/xhtml"></html>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		kinds = append(kinds, kind)
+		return nil
+	})
+	if code, _ := runForTest(t, []string{p}, ""); code != 0 {
+		t.Errorf("run(%q) returned %v; want 0", p, code)
+	}
+	if want := []string{"xhtml"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("run(%q) used validators %q; want %q", p, kinds, want)
+	}
+}
+
+func TestRun_Checkstyle(t *testing.T) {
+	fakeHTML(t, []validate.Issue{{Severity: validate.Error, Line: 2, Col: 3, Message: "Bad", Code: "bad"}})
+	args := []string{"-type=html", "-format=checkstyle"}
+	code, out := runForTest(t, args, "<!DOCTYPE html>")
+	if code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if want := `<file name="-">` + "\n" +
+		`    <error line="2" column="3" severity="error" message="Bad" source="bad"></error>`; !strings.Contains(out, want) {
+		t.Errorf("run(%q) printed %q; want it to contain %q", args, out, want)
+	}
+}
+
+func TestRun_TAP(t *testing.T) {
+	fakeHTML(t, []validate.Issue{{Severity: validate.Error, Line: 2, Col: 3, Message: "Bad", Code: "bad"}})
+	args := []string{"-type=html", "-format=tap"}
+	code, out := runForTest(t, args, "<!DOCTYPE html>")
+	if code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if want := "not ok 1 - -\n"; !strings.Contains(out, want) {
+		t.Errorf("run(%q) printed %q; want it to contain %q", args, out, want)
+	}
+	if want := "1..1\n"; !strings.HasSuffix(out, want) {
+		t.Errorf("run(%q) printed %q; want it to end with %q", args, out, want)
+	}
+}
+
+func TestRun_JUnit(t *testing.T) {
+	fakeHTML(t, []validate.Issue{{Severity: validate.Error, Line: 2, Col: 3, Message: "Bad", Code: "bad"}})
+	args := []string{"-type=html", "-format=junit"}
+	code, out := runForTest(t, args, "<!DOCTYPE html>")
+	if code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if want := `<testcase name="-" classname="validate">` + "\n" +
+		`      <failure message="Bad" type="bad">-:2:3: Bad</failure>`; !strings.Contains(out, want) {
+		t.Errorf("run(%q) printed %q; want it to contain %q", args, out, want)
+	}
+}
+
+func TestRun_Split(t *testing.T) {
+	var got []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		got = append(got, kind+" "+string(doc))
+		if strings.Contains(string(doc), "<bogus>") {
+			return []validate.Issue{{Severity: validate.Error, Line: 2, Col: 1, Message: "Bad"}}
+		}
+		return nil
+	})
+
+	for _, tc := range []struct{ sep, in string }{
+		{"---", "<!DOCTYPE html>\n<p>\n---\n<!DOCTYPE html>\n<bogus>\n"},
+		{`\0`, "<!DOCTYPE html>\n<p>\n\x00<!DOCTYPE html>\n<bogus>\n"},
+	} {
+		got = nil
+		args := []string{"-type=html", "-split=" + tc.sep}
+		code, out := runForTest(t, args, tc.in)
+		if code != exitValidationErrors {
+			t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+		}
+		if want := []string{"html <!DOCTYPE html>\n<p>\n", "html <!DOCTYPE html>\n<bogus>\n"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("run(%q) validated %q; want %q", args, got, want)
+		}
+		want := "#2:" + validate.Issue{Severity: validate.Error, Line: 2, Col: 1, Message: "Bad"}.String() + "\n" +
+			"1 error(s) and 0 warning(s) in 2 file(s)\n"
+		if out != want {
+			t.Errorf("run(%q) printed %q; want %q", args, out, want)
+		}
+	}
+}
+
+func TestRun_Manifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cssPath := filepath.Join(dir, "style.css")
+	if err := ioutil.WriteFile(cssPath, []byte("p{bogus:0}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		got = append(got, kind+" "+string(doc))
+		return []validate.Issue{{Severity: validate.Error, Line: 1, Message: kind + " error"}}
+	})
+	manifest, err := json.Marshal([]map[string]string{
+		{"id": "page", "type": "html", "content": "<!DOCTYPE html><bogus>"},
+		{"id": "sheet", "path": cssPath},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-manifest", "-format=json"}
+	code, out := runForTest(t, args, string(manifest))
+	if code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if want := []string{"html <!DOCTYPE html><bogus>", "css p{bogus:0}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("run(%q) validated %q; want %q", args, got, want)
+	}
+	var results map[string][]validate.Issue
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("Failed to unmarshal output %q: %v", out, err)
+	}
+	want := map[string][]validate.Issue{
+		"page":  {{Severity: validate.Error, Line: 1, Message: "html error"}},
+		"sheet": {{Severity: validate.Error, Line: 1, Message: "css error"}},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("run(%q) printed %v; want %v", args, results, want)
+	}
+
+	// Documents must have exactly one of content and path.
+	args = []string{"-manifest"}
+	if code, _ := runForTest(t, args, `[{"id":"a"}]`); code != 1 {
+		t.Errorf("run(%q) with bad manifest returned %v; want 1", args, code)
+	}
+}
+
+func TestRun_ConflictingModes(t *testing.T) {
+	fakeValidators(t, func(string, []byte) []validate.Issue {
+		t.Error("Unexpected validation")
+		return nil
+	})
+	for _, args := range [][]string{
+		{"-dir=.", "-split=---"},
+		{"-dir=.", "-manifest"},
+		{"-split=---", "-manifest"},
+		{"-split=---", "a.html"},
+		{"-manifest", "a.html", "b.html"},
+		{"-dir=.", "a.html"},
+	} {
+		if code, _ := runForTest(t, args, ""); code != 2 {
+			t.Errorf("run(%q) returned %v; want 2", args, code)
+		}
+	}
+}
+
+func TestRun_SaveResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		return []validate.Issue{{Severity: validate.Error, Line: 1, Message: kind + " error"}}
+	})
+
+	// The service's page should be saved as-is, and parent directories should be created.
+	p := filepath.Join(dir, "sub/dir/results.html")
+	args := []string{"-type=html", "-save-results=" + p}
+	if code, _ := runForTest(t, args, "<!DOCTYPE html>"); code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if b, err := ioutil.ReadFile(p); err != nil {
+		t.Errorf("Failed reading results saved by run(%q): %v", args, err)
+	} else if got, want := string(b), "<html></html>"; got != want {
+		t.Errorf("run(%q) saved %q; want %q", args, got, want)
+	}
+
+	// amphtml-validator doesn't generate a page, so a rendered page should be saved instead.
+	p = filepath.Join(dir, "amp.html")
+	args = []string{"-type=amp", "-save-results=" + p}
+	if code, _ := runForTest(t, args, "<!DOCTYPE html><html amp>"); code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if b, err := ioutil.ReadFile(p); err != nil {
+		t.Errorf("Failed reading results saved by run(%q): %v", args, err)
+	} else if !strings.Contains(string(b), "amp error") {
+		t.Errorf("run(%q) saved %q without issue", args, b)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// manifestDoc describes a document listed in a JSON manifest passed via -manifest.
+type manifestDoc struct {
+	// ID identifies the document in results.
+	ID string `json:"id"`
+	// Type contains the document's type as passed to the -type flag.
+	// If empty, it's inferred from Path or the document's content.
+	Type string `json:"type"`
+	// Content contains the document's content. Exactly one of Content and Path must be set.
+	Content *string `json:"content"`
+	// Path contains the path of a file containing the document.
+	Path string `json:"path"`
+}
+
+// readManifest reads a JSON array of manifestDoc objects from r.
+func readManifest(r io.Reader) ([]manifestDoc, error) {
+	var docs []manifestDoc
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&docs); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(docs))
+	for i, d := range docs {
+		if d.ID == "" {
+			return nil, fmt.Errorf("document %d has no ID", i+1)
+		}
+		if _, ok := seen[d.ID]; ok {
+			return nil, fmt.Errorf("duplicate ID %q", d.ID)
+		}
+		seen[d.ID] = struct{}{}
+		if (d.Content == nil) == (d.Path == "") {
+			return nil, fmt.Errorf("%q must have exactly one of content and path", d.ID)
+		}
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no documents")
+	}
+	return docs, nil
+}
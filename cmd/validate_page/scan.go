@@ -0,0 +1,259 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/derat/validate"
+)
+
+// scanDir validates all supported files within dir (see typeFromPath) and returns their
+// issues keyed by slash-separated paths relative to dir. HTML files are validated both by
+// the HTML validator and by the CSS validator.
+//
+// At most concurrency files are validated at once, and requests to network validators are
+// separated by at least opts.RequestInterval. If some files couldn't be validated, the
+// successful results are returned along with an error describing the failures. If
+// opts.FailFast is true, no more files are validated after an error-severity issue is found,
+// and validate.ErrAborted is returned along with the results collected so far. opts may be nil.
+//
+// If emit is non-nil, it is called with each successfully-validated file's path and issues
+// as soon as the file and all files preceding it in lexical order have been processed.
+func scanDir(ctx context.Context, dir string, concurrency int, opts *validate.Options,
+	emit func(rel string, issues []validate.Issue)) (
+	map[string][]validate.Issue, error) {
+	var paths []string
+	if err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() && typeFromPath(p) != "" {
+			paths = append(paths, p)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	failFast := opts != nil && opts.FailFast
+	limiter := newLimiter(opts)
+	results := make(map[string][]validate.Issue, len(paths))
+	var failures []string
+	aborted := false
+	done := make([]bool, len(paths)) // true after paths[i] has been processed
+	next := 0                        // index of next path to pass to emit
+	var mu sync.Mutex                // protects results, failures, aborted, done, and next
+
+	// finish marks paths[i] as processed and passes it and any following
+	// already-processed files to emit. mu must be held.
+	finish := func(i int) {
+		done[i] = true
+		for ; next < len(paths) && done[next]; next++ {
+			if emit == nil {
+				continue
+			}
+			r := relPath(dir, paths[next])
+			if issues, ok := results[r]; ok {
+				emit(r, issues)
+			}
+		}
+	}
+
+	// Start workers that validate the files in order.
+	type job struct {
+		i int
+		p string
+	}
+	ch := make(chan job, len(paths))
+	for i, p := range paths {
+		ch <- job{i, p}
+	}
+	close(ch)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				p := j.p
+				rel := relPath(dir, p)
+				if ctx.Err() != nil {
+					mu.Lock()
+					finish(j.i)
+					mu.Unlock()
+					continue
+				}
+
+				issues, err := scanFile(ctx, p, limiter, opts)
+				mu.Lock()
+				switch {
+				case aborted:
+					// Drop results that may have been interrupted by cancelation.
+				case err != nil:
+					failures = append(failures, fmt.Sprintf("%v: %v", rel, err))
+				default:
+					results[rel] = issues
+					if failFast && hasErrors(issues) {
+						aborted = true
+						cancel()
+					}
+				}
+				finish(j.i)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return results, fmt.Errorf("failed validating %d file(s): %v",
+			len(failures), strings.Join(failures, "; "))
+	}
+	if aborted && len(results) < len(paths) {
+		return results, validate.ErrAborted
+	}
+	return results, nil
+}
+
+// validateFiles validates the files at paths, each as fileType (as passed to the -type flag)
+// or as the type inferred from its name if fileType is empty. At most concurrency files are
+// validated at once, and requests to network validators are separated by at least
+// opts.RequestInterval.
+//
+// The returned map is keyed by path. If some files couldn't be validated, the successful
+// results are returned along with an error describing the failures.
+func validateFiles(ctx context.Context, paths []string, fileType string, concurrency int,
+	opts *validate.Options) (map[string][]validate.Issue, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limiter := newLimiter(opts)
+	results := make([][]validate.Issue, len(paths))
+	errs := make([]error, len(paths))
+
+	ch := make(chan int, len(paths))
+	for i := range paths {
+		ch <- i
+	}
+	close(ch)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				ft := fileType
+				if ft == "" {
+					if ft = typeFromPath(paths[j]); ft == "" {
+						errs[j] = errors.New("can't infer file type; pass -type")
+						continue
+					}
+				}
+				if ft == "css" || ft == "html" || ft == "htmlcss" || ft == "xhtml" {
+					if errs[j] = limiter.Wait(ctx); errs[j] != nil {
+						continue
+					}
+				}
+				results[j], errs[j] = validatePath(ctx, paths[j], ft, opts)
+			}
+		}()
+	}
+	wg.Wait()
+
+	all := make(map[string][]validate.Issue, len(paths))
+	var failures []string
+	for i, p := range paths {
+		if errs[i] != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", p, errs[i]))
+		} else {
+			all[p] = results[i]
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return all, fmt.Errorf("failed validating %d file(s): %v",
+			len(failures), strings.Join(failures, "; "))
+	}
+	return all, nil
+}
+
+// relPath returns the slash-separated path of p relative to dir.
+func relPath(dir, p string) string {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		rel = p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// hasErrors returns true if issues contains any issues with Error severity.
+func hasErrors(issues []validate.Issue) bool {
+	for _, is := range issues {
+		if is.Severity == validate.Error {
+			return true
+		}
+	}
+	return false
+}
+
+// scanFile validates the file at p, using limiter to rate-limit requests to network validators.
+func scanFile(ctx context.Context, p string, limiter *validate.RateLimiter, opts *validate.Options) (
+	[]validate.Issue, error) {
+	ft := typeFromPath(p)
+	types := []string{ft}
+	if ft == "html" {
+		types = append(types, "htmlcss")
+	}
+
+	var issues []validate.Issue
+	for _, t := range types {
+		if t == "css" || t == "html" || t == "htmlcss" || t == "xhtml" {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		fi, err := validatePath(ctx, p, t, opts)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, fi...)
+	}
+	return issues, nil
+}
+
+// validatePath validates the file at p as fileType (as passed to the -type flag).
+func validatePath(ctx context.Context, p, fileType string, opts *validate.Options) ([]validate.Issue, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	issues, _, err := validateFile(ctx, f, fileType, opts)
+	return issues, err
+}
+
+// newLimiter returns a limiter that enforces opts.RequestInterval. opts may be nil.
+func newLimiter(opts *validate.Options) *validate.RateLimiter {
+	var interval time.Duration
+	if opts != nil {
+		interval = opts.RequestInterval
+	}
+	return validate.NewRateLimiter(interval)
+}
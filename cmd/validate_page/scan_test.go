@@ -0,0 +1,319 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/derat/validate"
+)
+
+// writeFiles writes files (keyed by slash-separated relative path) within dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for rel, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRun_Dir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"index.html":         "<!DOCTYPE html>BAD",
+		"style.css":          "body{}",
+		"page.amp.html":      "<html amp>BAD",
+		"robots.txt":         "User-agent: *\nDisallow: /private\n",
+		"sitemap.xml":        `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url></url></urlset>`,
+		"notes.txt":          "Not validated",
+		"sub/other/doc.html": "<!DOCTYPE html>",
+	})
+
+	var mu sync.Mutex
+	var calls []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		mu.Lock()
+		calls = append(calls, kind+" "+string(doc))
+		mu.Unlock()
+		if strings.Contains(string(doc), "BAD") {
+			return []validate.Issue{{Severity: validate.Error, Line: 1, Message: kind + " error"}}
+		}
+		return nil
+	})
+
+	args := []string{"-dir", dir, "-format=json", "-rate=0", "-summary"}
+	code, out := runForTest(t, args, "")
+	if code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	i := strings.LastIndex(out, "SUMMARY")
+	if i < 0 {
+		t.Fatalf("run(%q) didn't print summary: %q", args, out)
+	}
+	if got, want := strings.TrimSpace(out[i:]), "SUMMARY errors=4 warnings=0 files=6"; got != want {
+		t.Errorf("run(%q) printed %q; want %q", args, got, want)
+	}
+
+	var results map[string][]struct{ Message string }
+	if err := json.Unmarshal([]byte(out[:i]), &results); err != nil {
+		t.Fatalf("Failed unmarshaling %q: %v", out[:i], err)
+	}
+	got := make(map[string][]string)
+	for p, issues := range results {
+		var msgs []string
+		for _, is := range issues {
+			msgs = append(msgs, is.Message)
+		}
+		got[p] = msgs
+	}
+	want := map[string][]string{
+		"index.html":         {"html error", "htmlcss error"},
+		"style.css":          nil,
+		"page.amp.html":      {"amp error"},
+		"robots.txt":         nil,
+		"sitemap.xml":        {"<url> element missing <loc>"},
+		"sub/other/doc.html": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("run(%q) returned %q; want %q", args, got, want)
+	}
+
+	sort.Strings(calls)
+	wantCalls := []string{
+		"amp <html amp>BAD",
+		"css body{}",
+		"html <!DOCTYPE html>",
+		"html <!DOCTYPE html>BAD",
+		"htmlcss <!DOCTYPE html>",
+		"htmlcss <!DOCTYPE html>BAD",
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("run(%q) made calls %q; want %q", args, calls, wantCalls)
+	}
+}
+
+func TestRun_DirFailFast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.css": "a",
+		"b.css": "b BAD",
+		"c.css": "c",
+		"d.css": "d",
+	})
+
+	var validated []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		validated = append(validated, string(doc))
+		if strings.Contains(string(doc), "BAD") {
+			return []validate.Issue{{Severity: validate.Error, Line: 1, Message: "Bad"}}
+		}
+		return nil
+	})
+
+	args := []string{"-dir", dir, "-rate=0", "-concurrency=1", "-fail-fast"}
+	code, out := runForTest(t, args, "")
+	if code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v", args, code, exitValidationErrors)
+	}
+	if want := []string{"a", "b BAD"}; !reflect.DeepEqual(validated, want) {
+		t.Errorf("run(%q) validated %q; want %q", args, validated, want)
+	}
+	if want := "b.css:1:0 Error: Bad\n"; !strings.Contains(out, want) {
+		t.Errorf("run(%q) printed %q; want output containing %q", args, out, want)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.String()
+}
+
+func TestRun_DirPartialFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"good.css": "BAD", "broken.css": "FAIL"})
+
+	orig := validateDoc
+	validateDoc = func(ctx context.Context, r io.Reader, ft validate.FileType,
+		opts *validate.Options) ([]validate.Issue, []byte, error) {
+		b, _ := ioutil.ReadAll(r)
+		if string(b) == "FAIL" {
+			return nil, nil, errors.New("service unavailable")
+		}
+		return []validate.Issue{{Severity: validate.Error, Line: 1, Message: "Bad CSS"}}, nil, nil
+	}
+	defer func() { validateDoc = orig }()
+
+	args := []string{"-dir", dir, "-rate=0"}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, strings.NewReader(""), &stdout, &stderr); code != 1 {
+		t.Errorf("run(%q) returned %v; want 1", args, code)
+	}
+	want := "good.css:" + validate.Issue{Severity: validate.Error, Line: 1, Message: "Bad CSS"}.String() + "\n" +
+		"1 error(s) and 0 warning(s) in 1 file(s)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("run(%q) printed %q; want %q", args, got, want)
+	}
+	if got := stderr.String(); !strings.Contains(got, "broken.css: service unavailable") {
+		t.Errorf("run(%q) printed %q to stderr; want failure for broken.css", args, got)
+	}
+}
+
+func TestRun_DirStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.css": "a", "b.css": "b", "c.css": "c"})
+
+	// a.css won't finish until b.css has been validated, and c.css won't finish until the
+	// output from both of the other files has been printed (or a timeout is reached).
+	var stdout, stderr syncBuffer
+	bDone := make(chan struct{})
+	var outBeforeC string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		switch string(doc) {
+		case "a":
+			<-bDone
+		case "b":
+			close(bDone)
+		case "c":
+			for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+				if outBeforeC = stdout.String(); strings.Count(outBeforeC, "\n") >= 2 {
+					break
+				}
+			}
+		}
+		return []validate.Issue{{Severity: validate.Error, Line: 1, Message: string(doc)}}
+	})
+
+	args := []string{"-dir", dir, "-rate=0", "-concurrency=2", "-stream"}
+	if code := run(args, strings.NewReader(""), &stdout, &stderr); code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v (stderr %q)", args, code, exitValidationErrors, stderr.String())
+	}
+	aLine := "a.css:" + validate.Issue{Severity: validate.Error, Line: 1, Message: "a"}.String()
+	bLine := "b.css:" + validate.Issue{Severity: validate.Error, Line: 1, Message: "b"}.String()
+	cLine := "c.css:" + validate.Issue{Severity: validate.Error, Line: 1, Message: "c"}.String()
+	if want := aLine + "\n" + bLine + "\n"; outBeforeC != want {
+		t.Errorf("run(%q) printed %q before validating c.css; want %q", args, outBeforeC, want)
+	}
+	want := strings.Join([]string{aLine, bLine, cLine, "3 error(s) and 0 warning(s) in 3 file(s)"}, "\n") + "\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("run(%q) printed %q; want %q", args, got, want)
+	}
+}
+
+func TestRun_MultipleFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.css": "a", "b.css": "b", "c.css": "c"})
+
+	// c.css won't finish until a.css has been validated, and a.css won't finish until
+	// b.css has been validated, so files complete in the reverse of argument order.
+	aDone, bDone := make(chan struct{}), make(chan struct{})
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		switch string(doc) {
+		case "a":
+			<-bDone
+			close(aDone)
+		case "b":
+			close(bDone)
+		case "c":
+			<-aDone
+		}
+		return []validate.Issue{{Severity: validate.Error, Line: 1, Message: string(doc)}}
+	})
+
+	paths := []string{filepath.Join(dir, "c.css"), filepath.Join(dir, "a.css"), filepath.Join(dir, "b.css")}
+	args := append([]string{"-rate=0", "-concurrency=3"}, paths...)
+	var stdout, stderr syncBuffer
+	if code := run(args, strings.NewReader(""), &stdout, &stderr); code != exitValidationErrors {
+		t.Errorf("run(%q) returned %v; want %v (stderr %q)", args, code, exitValidationErrors, stderr.String())
+	}
+	var want string
+	for _, p := range paths {
+		msg := strings.TrimSuffix(filepath.Base(p), ".css")
+		want += p + ":" + validate.Issue{Severity: validate.Error, Line: 1, Message: msg}.String() + "\n"
+	}
+	want += "3 error(s) and 0 warning(s) in 3 file(s)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("run(%q) printed %q; want %q", args, got, want)
+	}
+}
+
+func TestRun_MultipleFilesPartialFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.css": "a", "b.css": "b", "notes.txt": "?"})
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		return []validate.Issue{{Severity: validate.Error, Line: 1, Message: string(doc)}}
+	})
+
+	a, b := filepath.Join(dir, "a.css"), filepath.Join(dir, "b.css")
+	missing, notes := filepath.Join(dir, "missing.css"), filepath.Join(dir, "notes.txt")
+	args := []string{"-rate=0", a, missing, notes, b}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, strings.NewReader(""), &stdout, &stderr); code != 1 {
+		t.Errorf("run(%q) returned %v; want 1", args, code)
+	}
+	want := a + ":" + validate.Issue{Severity: validate.Error, Line: 1, Message: "a"}.String() + "\n" +
+		b + ":" + validate.Issue{Severity: validate.Error, Line: 1, Message: "b"}.String() + "\n" +
+		"2 error(s) and 0 warning(s) in 2 file(s)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("run(%q) printed %q; want %q", args, got, want)
+	}
+	got := stderr.String()
+	if !strings.Contains(got, "failed validating 2 file(s)") ||
+		!strings.Contains(got, missing+": ") || !strings.Contains(got, notes+": can't infer file type") {
+		t.Errorf("run(%q) printed %q to stderr; want failures for %v and %v", args, got, missing, notes)
+	}
+}
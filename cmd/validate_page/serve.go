@@ -0,0 +1,100 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/derat/validate"
+)
+
+// serveRequest is a request read from stdin with -serve.
+type serveRequest struct {
+	// Type contains the document's type as passed to the -type flag.
+	// If empty, it's inferred from Content.
+	Type string `json:"type"`
+	// Content contains the document to validate.
+	Content string `json:"content"`
+}
+
+// serveResponse is a response written to stdout with -serve.
+type serveResponse struct {
+	// Issues contains the issues found in the document.
+	Issues []validate.Issue `json:"issues"`
+	// Error describes why the request couldn't be handled. Issues is nil if it's set.
+	Error string `json:"error,omitempty"`
+}
+
+// serve reads newline-delimited JSON serveRequest objects from r and writes a
+// serveResponse line to w for each until r is exhausted. Malformed requests and
+// validation failures are reported via serveResponse.Error. An error is only
+// returned if reading or writing fails.
+//
+// A copy of opts (sharing its HTTP client) is used for all requests. amphtml-validator
+// doesn't have a persistent mode, so it's still run once per AMP document, but its path
+// is only looked up once. opts may be nil.
+func serve(ctx context.Context, r io.Reader, w io.Writer, opts *validate.Options,
+	filter func([]validate.Issue) []validate.Issue) error {
+	var o validate.Options
+	if opts != nil {
+		o = *opts
+	}
+	opts = &o
+	if opts.AMPValidatorPath == "" {
+		if p, err := exec.LookPath("amphtml-validator"); err == nil {
+			opts.AMPValidatorPath = p
+		}
+	}
+	br := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		ln, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(ln)) > 0 {
+			if err := enc.Encode(handleServeRequest(ctx, ln, opts, filter)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// handleServeRequest validates the document in the JSON-encoded serveRequest b.
+func handleServeRequest(ctx context.Context, b []byte, opts *validate.Options,
+	filter func([]validate.Issue) []validate.Issue) serveResponse {
+	var req serveRequest
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return serveResponse{Error: fmt.Sprintf("bad request: %v", err)}
+	}
+	ft := req.Type
+	if ft == "" {
+		var ctype string
+		if ft, ctype = inferType([]byte(req.Content)); ft == "" {
+			return serveResponse{Error: fmt.Sprintf("inferred unsupported file type %q; set type", ctype)}
+		}
+	}
+	issues, _, err := validateFile(ctx, bytes.NewReader([]byte(req.Content)), ft, opts)
+	if err == errBadType {
+		return serveResponse{Error: fmt.Sprintf("bad type %q", ft)}
+	} else if err != nil {
+		return serveResponse{Error: fmt.Sprintf("validation failed: %v", err)}
+	}
+	issues = filter(issues)
+	if issues == nil {
+		issues = []validate.Issue{}
+	}
+	return serveResponse{Issues: issues}
+}
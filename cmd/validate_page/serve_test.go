@@ -0,0 +1,93 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/derat/validate"
+)
+
+func TestRun_Serve(t *testing.T) {
+	var kinds []string
+	fakeValidators(t, func(kind string, doc []byte) []validate.Issue {
+		kinds = append(kinds, kind)
+		if strings.Contains(string(doc), "bad") {
+			return []validate.Issue{{Severity: validate.Error, Line: 1, Col: 2, Message: "Bad " + kind}}
+		}
+		return nil
+	})
+
+	stdin := strings.Join([]string{
+		`{"type":"css","content":"bad { }"}`,
+		`{"bogus`,
+		``,
+		`{"content":"<!DOCTYPE html>\n<html><body>ok</body></html>"}`,
+	}, "\n")
+	code, out := runForTest(t, []string{"-serve"}, stdin)
+	if code != 0 {
+		t.Fatalf("run returned %v; want 0", code)
+	}
+	if want := []string{"css", "html"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Validated %q; want %q", kinds, want)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("run wrote %d response(s); want 3:\n%s", len(lines), out)
+	}
+	var resps []serveResponse
+	for _, ln := range lines {
+		var resp serveResponse
+		if err := json.Unmarshal([]byte(ln), &resp); err != nil {
+			t.Fatalf("Bad response %q: %v", ln, err)
+		}
+		resps = append(resps, resp)
+	}
+	want := serveResponse{Issues: []validate.Issue{
+		{Severity: validate.Error, Line: 1, Col: 2, Message: "Bad css"}}}
+	if !reflect.DeepEqual(resps[0], want) {
+		t.Errorf("First response is %+v; want %+v", resps[0], want)
+	}
+	if resps[1].Error == "" || resps[1].Issues != nil {
+		t.Errorf("Malformed request got %+v; want error", resps[1])
+	}
+	if !reflect.DeepEqual(resps[2], serveResponse{Issues: []validate.Issue{}}) {
+		t.Errorf("Last response is %+v; want no issues", resps[2])
+	}
+	if !strings.Contains(lines[2], `"issues":[]`) {
+		t.Errorf("Last response %q doesn't contain empty issues", lines[2])
+	}
+}
+
+func TestServe_DoesntModifyOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate_page_test.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "amphtml-validator")
+	if err := ioutil.WriteFile(exe, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	origPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+origPath)
+	defer os.Setenv("PATH", origPath)
+
+	opts := &validate.Options{}
+	filter := func(issues []validate.Issue) []validate.Issue { return issues }
+	if err := serve(context.Background(), strings.NewReader(""), ioutil.Discard, opts, filter); err != nil {
+		t.Fatal("serve failed: ", err)
+	}
+	if opts.AMPValidatorPath != "" {
+		t.Errorf("serve set caller's AMPValidatorPath to %q", opts.AMPValidatorPath)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Keys used in CombinedResult.Pages.
+const (
+	HTMLPageKey = "html"
+	CSSPageKey  = "css"
+)
+
+// CombinedResult contains the results of HTMLAndCSS.
+type CombinedResult struct {
+	// HTML contains the issues reported by HTML.
+	HTML []Issue
+	// CSS contains the issues reported by CSS for the document's embedded CSS.
+	CSS []Issue
+	// Pages contains the raw results pages returned by the validation services,
+	// keyed by HTMLPageKey and CSSPageKey.
+	Pages map[string][]byte
+}
+
+// HTMLAndCSS reads an HTML document from r and concurrently validates it using both HTML and CSS
+// (with HTMLDoc). r is read only once.
+//
+// If one validator fails while the other succeeds, the successful validator's results are
+// returned along with an error describing the failure.
+func HTMLAndCSS(ctx context.Context, r io.Reader) (*CombinedResult, error) {
+	return HTMLAndCSSWithOptions(ctx, r, nil)
+}
+
+// HTMLAndCSSWithOptions is similar to HTMLAndCSS but accepts additional options.
+// opts may be nil.
+func HTMLAndCSSWithOptions(ctx context.Context, r io.Reader, opts *Options) (*CombinedResult, error) {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var res CombinedResult
+	var htmlPage, cssPage []byte
+	var htmlErr, cssErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		res.HTML, htmlPage, htmlErr = HTMLWithOptions(ctx, bytes.NewReader(in), opts)
+	}()
+	go func() {
+		defer wg.Done()
+		res.CSS, cssPage, cssErr = CSSWithOptions(ctx, bytes.NewReader(in), HTMLDoc, opts)
+	}()
+	wg.Wait()
+
+	res.Pages = make(map[string][]byte, 2)
+	var msgs []string
+	if htmlErr != nil {
+		msgs = append(msgs, fmt.Sprintf("HTML validation failed: %v", htmlErr))
+	}
+	if htmlPage != nil {
+		res.Pages[HTMLPageKey] = htmlPage
+	}
+	if cssErr != nil {
+		msgs = append(msgs, fmt.Sprintf("CSS validation failed: %v", cssErr))
+	}
+	if cssPage != nil {
+		res.Pages[CSSPageKey] = cssPage
+	}
+	if len(msgs) > 0 {
+		return &res, fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+	return &res, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// countingReader wraps an io.Reader and counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func TestHTMLAndCSS(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(1, 16, "HTML error")))
+	})
+	cssFails := false
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		if cssFails {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, jigsawPage(jigsawRow("error", 1, "body", "CSS error")))
+	})
+
+	const doc = "<!DOCTYPE html><style>body{bogus:0}</style><bogus>"
+	cr := &countingReader{r: strings.NewReader(doc)}
+	res, err := HTMLAndCSS(context.Background(), cr)
+	if err != nil {
+		t.Fatal("HTMLAndCSS failed: ", err)
+	}
+	if cr.n != len(doc) {
+		t.Errorf("HTMLAndCSS read %v byte(s); want %v", cr.n, len(doc))
+	}
+	if len(res.HTML) != 1 || res.HTML[0].Message != "HTML error" {
+		t.Errorf("HTMLAndCSS returned HTML issues %q", res.HTML)
+	}
+	if len(res.CSS) != 1 || res.CSS[0].Message != "CSS error" {
+		t.Errorf("HTMLAndCSS returned CSS issues %q", res.CSS)
+	}
+	for _, key := range []string{HTMLPageKey, CSSPageKey} {
+		if len(res.Pages[key]) == 0 {
+			t.Errorf("HTMLAndCSS didn't return %q page", key)
+		}
+	}
+
+	// If the CSS validator fails, the HTML results should still be returned.
+	cssFails = true
+	res, err = HTMLAndCSS(context.Background(), strings.NewReader(doc))
+	if err == nil || !strings.Contains(err.Error(), "CSS validation failed") {
+		t.Errorf("HTMLAndCSS with failing CSS validator returned error %v", err)
+	}
+	if res == nil || len(res.HTML) != 1 {
+		t.Errorf("HTMLAndCSS with failing CSS validator returned %+v", res)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// cspURL documents Content Security Policy directives.
+const cspURL = "https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Security-Policy"
+
+// cspDirectives contains recognized Content Security Policy directives.
+var cspDirectives = stringSet([]string{
+	"base-uri", "block-all-mixed-content", "child-src", "connect-src", "default-src",
+	"fenced-frame-src", "font-src", "form-action", "frame-ancestors", "frame-src", "img-src",
+	"manifest-src", "media-src", "navigate-to", "object-src", "plugin-types", "prefetch-src",
+	"report-to", "report-uri", "require-trusted-types-for", "sandbox", "script-src",
+	"script-src-attr", "script-src-elem", "style-src", "style-src-attr", "style-src-elem",
+	"trusted-types", "upgrade-insecure-requests", "webrtc", "worker-src",
+}, nil)
+
+// cspMetaUnsupported contains directives that are ignored when a policy is delivered
+// via a <meta> element rather than an HTTP header.
+var cspMetaUnsupported = stringSet([]string{"frame-ancestors", "report-uri", "sandbox"}, nil)
+
+// cspSourceListDirectives contains directives that aren't named "*-src" but still take source lists.
+var cspSourceListDirectives = stringSet([]string{"base-uri", "form-action", "navigate-to"}, nil)
+
+// cspKeywords contains keywords that must be single-quoted when used in source lists.
+var cspKeywords = stringSet([]string{
+	"inline-speculation-rules", "none", "report-sample", "self", "strict-dynamic",
+	"unsafe-allow-redirects", "unsafe-eval", "unsafe-hashes", "unsafe-inline", "wasm-unsafe-eval",
+}, nil)
+
+var (
+	cspNonceSource  = regexp.MustCompile(`^'nonce-[A-Za-z0-9+/_=-]+'$`)
+	cspHashSource   = regexp.MustCompile(`^'sha(256|384|512)-[A-Za-z0-9+/_=-]+'$`)
+	cspSchemeSource = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:$`)
+	cspHostSource   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://)?` +
+		`(\*|(\*\.)?[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*)(:([0-9]+|\*))?(/[^;,\s]*)?$`)
+)
+
+// checkCSP reports problems with policies declared by
+// <meta http-equiv="Content-Security-Policy"> elements.
+func checkCSP(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipCSP {
+		return nil
+	}
+	var issues []Issue
+	add := func(sev Severity, line int, code, msg string) {
+		issues = append(issues, Issue{Severity: sev, Line: line, Message: msg, Code: code, URL: cspURL})
+	}
+	for _, t := range toks {
+		if (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) || t.Data != "meta" {
+			continue
+		}
+		if equiv, _ := tokenAttr(&t.Token, "http-equiv"); !strings.EqualFold(strings.TrimSpace(equiv),
+			"content-security-policy") {
+			continue
+		}
+		content, _ := tokenAttr(&t.Token, "content")
+		for _, dir := range strings.Split(content, ";") {
+			fields := strings.Fields(dir)
+			if len(fields) == 0 {
+				continue
+			}
+			name := strings.ToLower(fields[0])
+			if _, ok := cspDirectives[name]; !ok {
+				add(Warning, t.line, "unknown-csp-directive", fmt.Sprintf("Unknown CSP directive %q", name))
+				continue
+			}
+			if _, ok := cspMetaUnsupported[name]; ok {
+				add(Warning, t.line, "csp-directive-in-meta",
+					fmt.Sprintf("CSP directive %q is ignored in <meta> elements", name))
+				continue
+			}
+			if _, ok := cspSourceListDirectives[name]; !ok && !strings.HasSuffix(name, "-src") {
+				continue
+			}
+			for _, src := range fields[1:] {
+				if msg := checkCSPSource(src); msg != "" {
+					add(Error, t.line, "invalid-csp-source", fmt.Sprintf("CSP directive %q has %s", name, msg))
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// checkCSPSource returns a description of the problem with the CSP source expression src,
+// or an empty string if it's valid.
+func checkCSPSource(src string) string {
+	lower := strings.ToLower(src)
+	if strings.HasPrefix(lower, "'") && strings.HasSuffix(lower, "'") && len(lower) > 1 {
+		if _, ok := cspKeywords[strings.Trim(lower, "'")]; ok ||
+			cspNonceSource.MatchString(src) || cspHashSource.MatchString(src) {
+			return ""
+		}
+		return fmt.Sprintf("invalid keyword %q", src)
+	}
+	if _, ok := cspKeywords[lower]; ok {
+		return fmt.Sprintf("unquoted keyword %q", src)
+	}
+	if cspSchemeSource.MatchString(src) || cspHostSource.MatchString(src) {
+		return ""
+	}
+	return fmt.Sprintf("invalid source %q", src)
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHTMLChecks_CSP(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta http-equiv="Content-Security-Policy" content="default-src 'self'; img-src https: data: *.example.org; script-src 'nonce-abc123' 'strict-dynamic'; upgrade-insecure-requests">
+    <meta http-equiv="content-security-policy" content="default-src self; scirpt-src 'none'; style-src 'unsafe-inlin' https://cdn.example.org:*/css/">
+    <meta http-equiv="Content-Security-Policy" content="frame-ancestors 'none'; report-uri /csp; img-src http://bad_host">
+  </head>
+</html>
+`
+	want := []string{
+		"5 invalid-csp-source",
+		"5 unknown-csp-directive",
+		"5 invalid-csp-source",
+		"6 csp-directive-in-meta",
+		"6 csp-directive-in-meta",
+		"6 invalid-csp-source",
+	}
+	if got := checkIssues(t, doc, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+	if got := checkIssues(t, doc, &CheckOptions{SkipCSP: true}); len(got) != 0 {
+		t.Errorf("HTMLChecks with SkipCSP returned %q", got)
+	}
+}
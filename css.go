@@ -6,53 +6,312 @@ package validate
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // Text included in https://jigsaw.w3.org/css-validator/ results pages on success.
 const cssSuccess = "<!-- NO ERRORS -->"
 
+// URL of the CSS validation service. Overridden by tests.
+var cssURL = "https://jigsaw.w3.org/css-validator/validator"
+
+// WarningAction describes how a WarningRule handles a matching CSS warning.
+type WarningAction int
+
+const (
+	// KeepWarning leaves the warning unchanged.
+	KeepWarning WarningAction = iota
+	// DropWarning removes the warning.
+	DropWarning
+	// DowngradeWarning changes the warning's severity to Info.
+	DowngradeWarning
+)
+
+// WarningRule describes how CSS warnings whose messages are matched by Pattern
+// should be handled. See Options.WarningRules.
+type WarningRule struct {
+	Pattern *regexp.Regexp
+	Action  WarningAction
+}
+
 // CSS reads an HTML or CSS document from r and validates its CSS content using https://jigsaw.w3.org/css-validator/.
 // FileType describes the type of file being validated: the W3C validator seems to have the unfortunate
 // property of reporting that the data validated successfully if the wrong type is supplied.
 //
+// When ft is HTMLDoc, each issue's Location field is set to StyleElement or StyleAttribute
+// to indicate whether the issue occurred within a <style> element or a style attribute.
+//
+// Issue.Col is taken from the service's results if it reports columns. Otherwise, it is
+// approximate: it is computed by locating the property or value named in the issue's
+// message within the issue's line, and is 0 if it can't be located.
+//
 // Parsed issues and the raw HTML results page returned by the validation service are returned.
 // If the returned error is non-nil, an issue occurred in the validation process.
 func CSS(ctx context.Context, r io.Reader, ft FileType) ([]Issue, []byte, error) {
+	return CSSWithOptions(ctx, r, ft, nil)
+}
+
+// CSSWithOptions is similar to CSS but accepts additional options.
+// opts may be nil.
+func CSSWithOptions(ctx context.Context, r io.Reader, ft FileType, opts *Options) ([]Issue, []byte, error) {
+	return validateStylesheet(ctx, r, ft, opts, false)
+}
+
+// CSSFragment reads a CSS declaration block (e.g. "color: red; margin: 0") from r and
+// validates it using https://jigsaw.w3.org/css-validator/. The declarations are wrapped
+// in a synthetic rule before being sent to the service, and issues' line numbers refer to
+// the original fragment. The raw results page refers to the wrapped stylesheet.
+func CSSFragment(ctx context.Context, r io.Reader) ([]Issue, []byte, error) {
+	return CSSFragmentWithOptions(ctx, r, nil)
+}
+
+// CSSFragmentWithOptions is similar to CSSFragment but accepts additional options.
+// opts may be nil.
+func CSSFragmentWithOptions(ctx context.Context, r io.Reader, opts *Options) ([]Issue, []byte, error) {
+	return validateStylesheet(ctx, r, Stylesheet, opts, true)
+}
+
+// Text added before and after declaration blocks validated by CSSFragment.
+// Each is on its own line so issues' columns are unaffected.
+const (
+	cssFragmentPrefix = ".x {\n"
+	cssFragmentSuffix = "\n}\n"
+)
+
+// validateStylesheet implements CSSWithOptions and CSSFragmentWithOptions.
+// If fragment is true, the input is a declaration block that's wrapped in a rule.
+func validateStylesheet(ctx context.Context, r io.Reader, ft FileType, opts *Options,
+	fragment bool) ([]Issue, []byte, error) {
+	ctx, cancel := opts.serviceContext(ctx)
+	defer cancel()
+
+	// Buffer the input so it can be resent if needed and examined locally after validation.
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	in, extra, err := opts.prepareInput(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc := in
+	if fragment {
+		doc = []byte(cssFragmentPrefix + string(in) + cssFragmentSuffix)
+	}
+	issues, out, err := validateCSS(ctx, doc, ft, opts, opts.useJSON())
+	if _, ok := err.(*ResponseError); ok && opts.retryAlternateFormat() {
+		if ri, rout, rerr := validateCSS(ctx, doc, ft, opts, !opts.useJSON()); rerr == nil {
+			issues, out, err = ri, rout, nil
+		}
+	}
+	if fragment {
+		unwrapCSSFragmentLines(issues, in)
+	}
+	if ft == HTMLDoc {
+		setCSSLocations(issues, in)
+	}
+	setCSSColumns(issues, in)
+	setSource(issues, CSSSource)
+	issues = append(issues, extra...)
+	opts.addContextWindows(issues, in)
+	normalizeColumns(issues)
+	return issues, out, err
+}
+
+// unwrapCSSFragmentLines adjusts the line numbers in issues (reported for the fragment
+// in after it was wrapped by CSSFragment) to refer to in. Issues reported on the wrapper's
+// lines are moved to the fragment's first or last line.
+func unwrapCSSFragmentLines(issues []Issue, in []byte) {
+	nlines := bytes.Count(in, []byte{'\n'}) + 1
+	prefixLines := strings.Count(cssFragmentPrefix, "\n")
+	for i := range issues {
+		if issues[i].Line <= 0 {
+			continue
+		}
+		ln := issues[i].Line - prefixLines
+		if ln < 1 {
+			ln = 1
+		} else if ln > nlines {
+			ln = nlines
+		}
+		issues[i].Line = ln
+	}
+}
+
+// validateCSS uploads the document in to the validation service and parses the response.
+// If useJSON is true, the service is asked to return JSON rather than an HTML page.
+func validateCSS(ctx context.Context, in []byte, ft FileType, opts *Options, useJSON bool) ([]Issue, []byte, error) {
 	// TODO: Maybe make these form values configurable.
 	// Available values can be seen in the source of https://jigsaw.w3.org/css-validator.
-	resp, err := post(ctx, "https://jigsaw.w3.org/css-validator/validator",
-		map[string]string{
-			"profile":     "css3svg", // "none", "css1", "css2", "css21", "css3", "svg", etc.
-			"usermedium":  "all",     // "screen", "print", etc.
-			"warning":     "1",       // "no", "0" ("most important"), 1 ("normal report"), 2 ("all")
-			"vextwarning": "",        // "" ("default"), "true" ("warnings"), "false" ("errors")
-			"lang":        "en",
-		},
-		[]fileInfo{fileInfo{field: "file", name: "data", ctype: string(ft), r: r}})
+	fields := map[string]string{
+		"profile":     "css3svg", // "none", "css1", "css2", "css21", "css3", "svg", etc.
+		"usermedium":  "all",     // "screen", "print", etc.
+		"warning":     "1",       // "no", "0" ("most important"), 1 ("normal report"), 2 ("all")
+		"vextwarning": "",        // "" ("default"), "true" ("warnings"), "false" ("errors")
+		"lang":        "en",
+	}
+	if useJSON {
+		fields["output"] = "json"
+	}
+	resp, err := post(ctx, opts, opts.cssServiceURL(), fields,
+		[]fileInfo{fileInfo{field: "file", name: "data", ctype: string(ft), r: bytes.NewReader(in)}}, -1)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
-	out, err := ioutil.ReadAll(resp.Body)
+	if fn := opts.onIssue(); fn != nil && !useJSON {
+		// Parse the page as it's received rather than buffering it. The raw page isn't returned.
+		body := &maxSizeReader{r: resp.Body, max: opts.maxResponseSize()}
+		report := func(is Issue) { is.Source = CSSSource; fn(is) }
+		issues, success, err := streamIssues(body, cssSuccess, cssIssueMatcher, atom.Tbody, makeCSSIssue, report)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkResponse(success, issues); err != nil {
+			return issues, nil, &ResponseError{err, nil}
+		}
+		return opts.applyWarningRules(issues), nil, nil
+	}
+
+	out, err := readResponse(resp.Body, opts.maxResponseSize())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	node, err := html.Parse(bytes.NewReader(out))
+	var issues []Issue
+	var success bool
+	if useJSON {
+		if issues, success, err = parseCSSJSON(out); err != nil {
+			return nil, out, &ResponseError{err, out}
+		}
+	} else if issues, success, err = parseCSSPage(out); err != nil {
+		return nil, out, &ResponseError{err, out}
+	}
+	if err := checkResponse(success, issues); err != nil {
+		return issues, out, &ResponseError{err, out}
+	}
+	return opts.applyWarningRules(issues), out, nil
+}
+
+// applyWarningRules returns issues after handling Warning issues as described by
+// o.AllowedVendorPrefixes and o.WarningRules. Only the first rule matching each warning's
+// message is used.
+// Issues with other severities are left unchanged, so the result of checkResponse
+// is unaffected.
+func (o *Options) applyWarningRules(issues []Issue) []Issue {
+	if o == nil || (len(o.WarningRules) == 0 && len(o.AllowedVendorPrefixes) == 0) {
+		return issues
+	}
+	var out []Issue
+	for _, is := range issues {
+		if is.Severity == Warning {
+			if o.allowedVendorExtension(is.Message) {
+				continue
+			}
+			if act := o.warningAction(is.Message); act == DropWarning {
+				continue
+			} else if act == DowngradeWarning {
+				is.Severity = Info
+			}
+		}
+		out = append(out, is)
+	}
+	return out
+}
+
+// warningAction returns the action of the first rule in o.WarningRules matching msg.
+func (o *Options) warningAction(msg string) WarningAction {
+	for _, r := range o.WarningRules {
+		if r.Pattern != nil && r.Pattern.MatchString(msg) {
+			return r.Action
+		}
+	}
+	return KeepWarning
+}
+
+// vendorExtensionRegexp matches a CSS warning about an unknown vendor extension
+// (e.g. "-webkit-transform is an unknown vendor extension"), capturing the prefix.
+var vendorExtensionRegexp = regexp.MustCompile(`(-[a-zA-Z0-9]+-)[^\s"”]*["”]?\s+is an unknown vendor extension`)
+
+// allowedVendorExtension returns true if msg is a warning about an unknown vendor extension
+// whose prefix is listed in o.AllowedVendorPrefixes.
+func (o *Options) allowedVendorExtension(msg string) bool {
+	m := vendorExtensionRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return false
+	}
+	for _, p := range o.AllowedVendorPrefixes {
+		if strings.EqualFold(strings.Trim(p, "-"), strings.Trim(m[1], "-")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCSSJSON parses issues from a JSON response returned by https://jigsaw.w3.org/css-validator/.
+// The returned bool reports whether the service reported that the document was valid.
+func parseCSSJSON(b []byte) ([]Issue, bool, error) {
+	type message struct {
+		Line    int    `json:"line"`
+		Context string `json:"context"`
+		Message string `json:"message"`
+	}
+	var out struct {
+		Validation struct {
+			Validity bool      `json:"validity"`
+			Errors   []message `json:"errors"`
+			Warnings []message `json:"warnings"`
+		} `json:"cssvalidation"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, false, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var issues []Issue
+	add := func(msgs []message, sev Severity) {
+		for _, m := range msgs {
+			issues = append(issues, Issue{
+				Severity: sev,
+				Line:     m.Line,
+				Message:  strings.TrimSpace(m.Message),
+				Context:  strings.TrimSpace(m.Context),
+			})
+		}
+	}
+	add(out.Validation.Errors, Error)
+	add(out.Validation.Warnings, Warning)
+	return issues, out.Validation.Validity, nil
+}
+
+// ParseCSSResults parses issues from b, a results page previously returned by the
+// validation service (e.g. the raw output saved from CSS). An error is returned if
+// the page can't be parsed or doesn't look like a results page.
+func ParseCSSResults(b []byte) ([]Issue, error) {
+	issues, success, err := parseCSSPage(b)
 	if err != nil {
-		return nil, out, fmt.Errorf("failed to parse response: %v", err)
+		return nil, err
 	}
-	issues := extractCSSIssues(node)
-	err = checkResponse(strings.Contains(string(out), cssSuccess), issues)
-	return issues, out, err
+	return issues, checkResponse(success, issues)
+}
+
+// parseCSSPage parses issues from the HTML results page b returned by https://jigsaw.w3.org/css-validator/.
+// The returned bool reports whether the page reported that the document was valid.
+func parseCSSPage(b []byte) ([]Issue, bool, error) {
+	node, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return extractCSSIssues(node), strings.Contains(string(b), cssSuccess), nil
 }
 
 // extractCSSIssues recursively walks n and returns validation issues.
@@ -92,12 +351,21 @@ func extractCSSIssues(n *html.Node) []Issue {
 //     <td class="codeContext"></td>
 //     <td class="level0" title="warning level 0"><code>-webkit-transform</code> is an unknown vendor extension</td>
 //   </tr>
+//
+// Some versions of the service also report columns, either in a <td class="colnumber">
+// cell or in the codeContext cell's title attribute (e.g. title="Line 17, Column 5").
+// Col is set if a column is found and left at 0 otherwise.
 func makeCSSIssue(tr *html.Node, sev Severity) Issue {
 	is := Issue{Severity: sev}
 	for n := tr.FirstChild; n != nil; n = n.NextSibling {
 		if n.Type != html.ElementNode || n.Data != "td" {
 			continue
 		}
+		if getAttr(n, "class") == "codeContext" {
+			if m := cssColumnTitle.FindStringSubmatch(getAttr(n, "title")); m != nil {
+				is.Col, _ = strconv.Atoi(m[1])
+			}
+		}
 
 		// Squish together all of the text content inside the <td>.
 		text := strings.TrimSpace(getText(n, nil))
@@ -108,11 +376,98 @@ func makeCSSIssue(tr *html.Node, sev Severity) Issue {
 		switch getAttr(n, "class") {
 		case "linenumber":
 			is.Line, _ = strconv.Atoi(text)
+		case "colnumber":
+			is.Col, _ = strconv.Atoi(text)
 		case "codeContext":
 			is.Context = text
 		default:
 			is.Message = text
+			// The service links some messages to the relevant parts of specs.
+			is.URL = getLinkURL(n)
 		}
 	}
 	return is
 }
+
+// cssColumnTitle matches a column number in the title attribute of a results page's
+// codeContext cell.
+var cssColumnTitle = regexp.MustCompile(`(?i)\bcol(?:umn)?\s*:?\s*(\d+)`)
+
+// setCSSLocations sets the Location field of each of issues to StyleElement or StyleAttribute
+// by locating the <style> element or style attribute in the HTML document doc that contains
+// the issue's line.
+func setCSSLocations(issues []Issue, doc []byte) {
+	type span struct {
+		start, end int // 1-indexed lines
+		loc        IssueLocation
+	}
+	var spans []span
+	inStyle := false
+	for _, t := range tokenizeLines(doc) {
+		switch t.Type {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if _, ok := tokenAttr(&t.Token, "style"); ok {
+				spans = append(spans, span{t.line, t.endLine, StyleAttribute})
+			}
+			inStyle = t.Type == html.StartTagToken && t.Data == "style"
+		case html.TextToken:
+			if inStyle {
+				spans = append(spans, span{t.line, t.endLine, StyleElement})
+			}
+		case html.EndTagToken:
+			inStyle = false
+		}
+	}
+
+	for i := range issues {
+		for _, sp := range spans {
+			if issues[i].Line >= sp.start && issues[i].Line <= sp.end {
+				issues[i].Location = sp.loc
+				break
+			}
+		}
+	}
+}
+
+// cssTokenRegexps match messages from https://jigsaw.w3.org/css-validator/ that name
+// the offending property or value in their first subexpression.
+var cssTokenRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`^Property (\S+) doesn't exist`),
+	regexp.MustCompile(`^(\S+) is an unknown vendor extension`),
+	regexp.MustCompile(`^Value Error : (\S+)`),
+	regexp.MustCompile(`^Unknown pseudo-element or pseudo-class (\S+)`),
+}
+
+// setCSSColumns sets the Col field of each of issues that lacks one by locating the property
+// or value named in the issue's message within the issue's line in the document doc.
+// The resulting column is approximate, since the token may appear multiple times within
+// the line. Col is left at 0 if the token can't be located.
+func setCSSColumns(issues []Issue, doc []byte) {
+	var lines []string
+	for i := range issues {
+		is := &issues[i]
+		if is.Col != 0 || is.Line < 1 {
+			continue
+		}
+		var tok string
+		for _, re := range cssTokenRegexps {
+			if m := re.FindStringSubmatch(is.Message); m != nil {
+				tok = m[1]
+				break
+			}
+		}
+		if tok == "" {
+			continue
+		}
+		if lines == nil {
+			lines = strings.Split(string(doc), "\n")
+		}
+		if is.Line > len(lines) {
+			continue
+		}
+		ln := lines[is.Line-1]
+		if idx := strings.Index(ln, tok); idx >= 0 {
+			is.Col = utf8.RuneCountInString(ln[:idx]) + 1
+		}
+	}
+}
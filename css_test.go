@@ -5,11 +5,20 @@ package validate
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+
+	"golang.org/x/net/html"
 )
 
 func TestCSS_ValidCSS(t *testing.T) {
+	fakeJigsaw(t)
 	issues, out, err := CSS(context.Background(), strings.NewReader(`
 body {
   background-color: white;
@@ -28,6 +37,7 @@ body {
 }
 
 func TestCSS_InvalidCSS(t *testing.T) {
+	fakeJigsaw(t)
 	issues, out, err := CSS(context.Background(), strings.NewReader(`
 body {
   invalid-property: #aaa;
@@ -53,6 +63,7 @@ body {
 }
 
 func TestCSS_ValidHTML(t *testing.T) {
+	fakeJigsaw(t)
 	issues, out, err := CSS(context.Background(), strings.NewReader(`
 <html>
   <head>
@@ -76,6 +87,7 @@ func TestCSS_ValidHTML(t *testing.T) {
 }
 
 func TestCSS_InvalidHTML(t *testing.T) {
+	fakeJigsaw(t)
 	issues, out, err := CSS(context.Background(), strings.NewReader(`
 <html>
   <head>
@@ -105,3 +117,277 @@ func TestCSS_InvalidHTML(t *testing.T) {
 		t.Error("CSS returned empty output")
 	}
 }
+
+func TestCSS_HTMLSources(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(
+			jigsawRow("error", 5, "body", "Property invalid-property doesn't exist"),
+			jigsawRow("error", 10, "p", "Property bogus doesn't exist")))
+	})
+	issues, _, err := CSS(context.Background(), strings.NewReader(`<!DOCTYPE html>
+<html>
+  <head>
+    <title>The title</title>
+    <style>body{invalid-property:0}</style>
+  </head>
+  <body>
+    <p>Some text</p>
+    <p
+       style="bogus:0">
+      More text
+    </p>
+  </body>
+</html>
+`), HTMLDoc)
+	if err != nil {
+		t.Error("CSS reported error: ", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("CSS returned %v issues (%q); want 2", len(issues), issues)
+	}
+	if got, want := issues[0].Location, StyleElement; got != want {
+		t.Errorf("CSS returned location %q for issue %q; want %q", got, issues[0], want)
+	}
+	if got, want := issues[1].Location, StyleAttribute; got != want {
+		t.Errorf("CSS returned location %q for issue %q; want %q", got, issues[1], want)
+	}
+	for _, is := range issues {
+		if is.Code != "" {
+			t.Errorf("CSS set code %q for issue %q", is.Code, is)
+		}
+	}
+}
+
+func TestCSSWithOptions_WarningRules(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(
+			jigsawRow("warning", 1, "", "-webkit-transform is an unknown vendor extension"),
+			jigsawRow("warning", 2, "", "Same color for background-color and color"),
+			jigsawRow("error", 3, "p", "Property bogus doesn't exist")))
+	})
+	opts := &Options{WarningRules: []WarningRule{
+		{Pattern: regexp.MustCompile(`unknown vendor extension`), Action: DowngradeWarning},
+	}}
+	issues, _, err := CSSWithOptions(context.Background(),
+		strings.NewReader("a{-webkit-transform:none}\nb{color:red;background-color:red}\np{bogus:0}\n"),
+		Stylesheet, opts)
+	if err != nil {
+		t.Error("CSSWithOptions reported error: ", err)
+	}
+	want := []Issue{
+		{Severity: Info, Line: 1, Message: "-webkit-transform is an unknown vendor extension", Source: CSSSource},
+		{Severity: Warning, Line: 2, Message: "Same color for background-color and color", Source: CSSSource},
+		{Severity: Error, Line: 3, Message: "Property bogus doesn't exist", Context: "p", Source: CSSSource},
+	}
+	if missing, extra := CompareIssues(issues, want, "Col"); len(missing) > 0 || len(extra) > 0 {
+		t.Errorf("CSSWithOptions returned %q; want %q", issues, want)
+	}
+
+	opts.WarningRules[0].Action = DropWarning
+	if issues, _, err = CSSWithOptions(context.Background(), strings.NewReader("a{}"), Stylesheet, opts); err != nil {
+		t.Error("CSSWithOptions reported error: ", err)
+	} else if len(issues) != 2 {
+		t.Errorf("CSSWithOptions returned %q; want 2 issues", issues)
+	}
+}
+
+func TestParseCSSResults(t *testing.T) {
+	issues, err := ParseCSSResults([]byte(jigsawPage(
+		jigsawRow("error", 17, "body", "Property bogus doesn't exist"),
+		jigsawRow("warning", 15, "", "-webkit-transform is an unknown vendor extension"),
+		`<tr class="error"><td class="linenumber" title="Line 3">3</td><td class="codeContext">p</td>`+
+			`<td class="parse-error"><a href="https://www.w3.org/TR/css-color-3/#color0">color</a> `+
+			`zzz is not a color value</td></tr>`)))
+	if err != nil {
+		t.Fatal("ParseCSSResults failed: ", err)
+	}
+	want := []Issue{
+		{Severity: Error, Line: 17, Context: "body", Message: "Property bogus doesn't exist"},
+		{Severity: Warning, Line: 15, Message: "-webkit-transform is an unknown vendor extension"},
+		{Severity: Error, Line: 3, Context: "p", Message: "color zzz is not a color value",
+			URL: "https://www.w3.org/TR/css-color-3/#color0"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("ParseCSSResults returned %q; want %q", issues, want)
+	}
+
+	if _, err := ParseCSSResults([]byte("<html><body>Not a results page</body></html>")); err == nil {
+		t.Error("ParseCSSResults unexpectedly succeeded for non-results page")
+	}
+}
+
+// jigsawColumnsPage is a results page in the format used by versions of
+// https://jigsaw.w3.org/css-validator/ that report columns.
+const jigsawColumnsPage = `<!DOCTYPE html>
+<html><head><title>Results</title></head><body>
+<div id="errors"><table>
+<tr class="error">
+  <td class="linenumber" title="Line 3">3</td>
+  <td class="colnumber">9</td>
+  <td class="codeContext"> p </td>
+  <td class="parse-error">Property <code>colr</code> doesn't exist</td>
+</tr>
+<tr class="error">
+  <td class="linenumber" title="Line 5">5</td>
+  <td class="codeContext" title="Line 5, Column 12"> a </td>
+  <td class="parse-error">Value Error : <code>color</code> zzz is not a color value</td>
+</tr>
+<tr class="error">
+  <td class="linenumber" title="Line 8">8</td>
+  <td class="codeContext"> b </td>
+  <td class="parse-error">Parse Error</td>
+</tr>
+</table></div>
+</body></html>
+`
+
+func TestParseCSSResults_Columns(t *testing.T) {
+	issues, err := ParseCSSResults([]byte(jigsawColumnsPage))
+	if err != nil {
+		t.Fatal("ParseCSSResults failed: ", err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, fmt.Sprintf("%d:%d", is.Line, is.Col))
+	}
+	if want := []string{"3:9", "5:12", "8:0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCSSResults returned issues at %q; want %q", got, want)
+	}
+}
+
+func TestCSSWithOptions_OnIssue(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(
+			jigsawRow("error", 2, "p", "Property bogus doesn't exist"),
+			jigsawRow("warning", 3, "", "-webkit-transform is an unknown vendor extension")))
+	})
+	var reported []Issue
+	issues, _, err := CSSWithOptions(context.Background(), strings.NewReader("a{}"), Stylesheet,
+		&Options{OnIssue: func(is Issue) { reported = append(reported, is) }})
+	if err != nil {
+		t.Fatal("CSSWithOptions failed: ", err)
+	}
+	want := []Issue{
+		{Severity: Error, Line: 2, Context: "p", Message: "Property bogus doesn't exist", Source: CSSSource},
+		{Severity: Warning, Line: 3, Message: "-webkit-transform is an unknown vendor extension", Source: CSSSource},
+	}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("OnIssue received %q; want %q", reported, want)
+	}
+	if len(issues) != len(want) {
+		t.Errorf("CSSWithOptions returned %q; want %v issues", issues, len(want))
+	}
+}
+
+func TestCSS_Columns(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(
+			jigsawRow("error", 2, "p", "Property bogus doesn't exist"),
+			jigsawRow("error", 3, "a", "Parse Error [:hover]")))
+	})
+	issues, _, err := CSS(context.Background(), strings.NewReader(
+		"body { color: black }\np { color: red; bogus: 0 }\na:hover{ color: blue }\n"), Stylesheet)
+	if err != nil {
+		t.Error("CSS reported error: ", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("CSS returned %v issues (%q); want 2", len(issues), issues)
+	}
+	if got, want := issues[0].Col, 17; got != want {
+		t.Errorf("CSS returned column %v for %q; want %v", got, issues[0], want)
+	}
+	// Columns can't be computed for messages that don't name a property or value.
+	if got := issues[1].Col; got != 0 {
+		t.Errorf("CSS returned column %v for %q; want 0", got, issues[1])
+	}
+}
+
+// jigsawPage returns a minimal results page in the format used by https://jigsaw.w3.org/css-validator/
+// containing the supplied <tr> elements (see jigsawRow). If no error rows are supplied,
+// the page reports success.
+func jigsawPage(rows ...string) string {
+	s := "<!DOCTYPE html><html><head><title>Results</title></head><body>"
+	if joined := strings.Join(rows, ""); !strings.Contains(joined, `class="error"`) {
+		s += cssSuccess
+	}
+	return s + "<table>" + strings.Join(rows, "") + "</table></body></html>"
+}
+
+// fakeJigsaw installs a fake CSS validation service that mimics
+// https://jigsaw.w3.org/css-validator/ by reporting an error for each line of the
+// uploaded document that uses a property named "invalid-property".
+func fakeJigsaw(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		var rows []string
+		for i, ln := range strings.Split(string(b), "\n") {
+			if strings.Contains(ln, "invalid-property") {
+				rows = append(rows, jigsawRow("error", i+1, "body", "Property invalid-property doesn't exist"))
+			}
+		}
+		io.WriteString(w, jigsawPage(rows...))
+	})
+}
+
+// jigsawRow returns a <tr> element with the supplied class ("error" or "warning")
+// describing an issue.
+func jigsawRow(class string, line int, ctx, msg string) string {
+	return fmt.Sprintf(`<tr class="%s"><td class="linenumber" title="Line %d">%d</td>`+
+		`<td class="codeContext">%s</td><td class="parse-error">%s</td></tr>`,
+		class, line, line, html.EscapeString(ctx), html.EscapeString(msg))
+}
+
+func TestCSSWithOptions_AllowedVendorPrefixes(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(
+			jigsawRow("warning", 1, "a", "-webkit-transform is an unknown vendor extension"),
+			jigsawRow("warning", 2, "b", "-xyz-transform is an unknown vendor extension"),
+			jigsawRow("warning", 3, "c", "-webkit-gradient(linear) is an unknown vendor extension"),
+			jigsawRow("warning", 4, "", "Same color for background-color and color")))
+	})
+	issues, _, err := CSSWithOptions(context.Background(), strings.NewReader("a{}"), Stylesheet,
+		&Options{AllowedVendorPrefixes: []string{"-webkit-"}})
+	if err != nil {
+		t.Fatal("CSSWithOptions failed: ", err)
+	}
+	want := []Issue{
+		{Severity: Warning, Line: 2, Message: "-xyz-transform is an unknown vendor extension", Context: "b",
+			Source: CSSSource},
+		{Severity: Warning, Line: 4, Message: "Same color for background-color and color", Source: CSSSource},
+	}
+	if missing, extra := CompareIssues(issues, want, "Col"); len(missing) > 0 || len(extra) > 0 {
+		t.Errorf("CSSWithOptions returned %q; want %q", issues, want)
+	}
+}
+
+func TestCSSFragment(t *testing.T) {
+	var uploaded string
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		uploaded = string(b)
+		io.WriteString(w, jigsawPage(jigsawRow("error", 2, ".x", "Value Error : color zzz is not a color value")))
+	})
+
+	issues, _, err := CSSFragment(context.Background(), strings.NewReader("color: zzz;"))
+	if err != nil {
+		t.Fatal("CSSFragment failed: ", err)
+	}
+	if want := ".x {\ncolor: zzz;\n}\n"; uploaded != want {
+		t.Errorf("CSSFragment uploaded %q; want %q", uploaded, want)
+	}
+	if len(issues) != 1 || issues[0].Line != 1 {
+		t.Errorf("CSSFragment returned %q; want one issue on line 1", issues)
+	}
+}
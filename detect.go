@@ -0,0 +1,47 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// xhtmlNamespace is the XML namespace used by XHTML documents.
+const xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+
+// UnsupportedTypeError is returned by DetectType and ValidateAuto if a document's type
+// isn't supported.
+type UnsupportedTypeError struct {
+	// MediaType contains the document's detected MIME type, e.g. "image/png".
+	MediaType string
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported document type %q", e.MediaType)
+}
+
+// DetectType infers the type of the document b by sniffing its contents. HTML documents whose
+// <html> elements have AMP attributes (e.g. <html ⚡>) are reported as AMPDoc, and XML documents
+// using the XHTML namespace as XHTMLDoc. Plain text is assumed to be a Stylesheet, since CSS
+// has no distinguishing signature. If the type isn't supported, an *UnsupportedTypeError is
+// returned.
+func DetectType(b []byte) (FileType, error) {
+	ctype := http.DetectContentType(b)
+	switch {
+	case strings.HasPrefix(ctype, "text/html"):
+		if isAMPDoc(b) {
+			return AMPDoc, nil
+		}
+		return HTMLDoc, nil
+	case strings.HasPrefix(ctype, "text/xml") && bytes.Contains(b, []byte(xhtmlNamespace)):
+		return XHTMLDoc, nil
+	case strings.HasPrefix(ctype, "text/plain"):
+		return Stylesheet, nil
+	default:
+		return "", &UnsupportedTypeError{ctype}
+	}
+}
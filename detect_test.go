@@ -0,0 +1,36 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectType(t *testing.T) {
+	for _, tc := range []struct {
+		doc  string
+		want FileType // empty if unsupported
+	}{
+		{"<!DOCTYPE html>\n<html lang=\"en\"><title>Hi</title>", HTMLDoc},
+		{"<!doctype html>\n<html ⚡4email>", AMPDoc},
+		{minimalAMP, AMPDoc},
+		{`<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"></html>`, XHTMLDoc},
+		{"body { color: red }\n", Stylesheet},
+		{`<?xml version="1.0"?><urlset></urlset>`, ""},
+		{"\x89PNG\r\n\x1a\n", ""},
+	} {
+		got, err := DetectType([]byte(tc.doc))
+		if tc.want == "" {
+			var ute *UnsupportedTypeError
+			if !errors.As(err, &ute) {
+				t.Errorf("DetectType(%q) returned %q, %v; want *UnsupportedTypeError", tc.doc, got, err)
+			}
+		} else if err != nil {
+			t.Errorf("DetectType(%q) failed: %v", tc.doc, err)
+		} else if got != tc.want {
+			t.Errorf("DetectType(%q) = %q; want %q", tc.doc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultEmailUnsupportedProperties lists CSS properties that are poorly supported by
+// email clients. It is used by Email if Options.EmailUnsupportedProperties is nil.
+var DefaultEmailUnsupportedProperties = []string{
+	"animation",
+	"box-shadow",
+	"filter",
+	"flex",
+	"grid",
+	"position",
+	"transform",
+	"transition",
+	"z-index",
+}
+
+// Email reads an HTML email from r and validates it using HTML. Constructs that are
+// unfriendly to email clients (external stylesheets, <script> elements, and CSS properties
+// listed in DefaultEmailUnsupportedProperties) are additionally reported as warnings.
+func Email(ctx context.Context, r io.Reader) ([]Issue, []byte, error) {
+	return EmailWithOptions(ctx, r, nil)
+}
+
+// EmailWithOptions is similar to Email but accepts additional options.
+// opts may be nil.
+func EmailWithOptions(ctx context.Context, r io.Reader, opts *Options) ([]Issue, []byte, error) {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	issues, out, err := HTMLWithOptions(ctx, bytes.NewReader(in), opts)
+	if err != nil {
+		return issues, out, err
+	}
+	props := DefaultEmailUnsupportedProperties
+	if opts != nil && opts.EmailUnsupportedProperties != nil {
+		props = opts.EmailUnsupportedProperties
+	}
+	return append(issues, checkEmail(in, props)...), out, nil
+}
+
+// checkEmail returns warnings about email-unfriendly constructs in the HTML document doc.
+// props contains unsupported CSS properties.
+func checkEmail(doc []byte, props []string) []Issue {
+	unsupported := make(map[string]struct{}, len(props))
+	for _, p := range props {
+		unsupported[strings.ToLower(p)] = struct{}{}
+	}
+
+	var issues []Issue
+	warn := func(line int, code, msg string) {
+		issues = append(issues, Issue{Severity: Warning, Line: line, Message: msg, Code: code})
+	}
+	// checkDecls checks the CSS declarations in decls, which start at the supplied line.
+	checkDecls := func(decls string, line int) {
+		for _, d := range strings.Split(decls, ";") {
+			if i := strings.Index(d, ":"); i >= 0 {
+				prop := strings.ToLower(strings.TrimSpace(d[:i]))
+				if _, ok := unsupported[prop]; ok {
+					warn(line+strings.Count(d[:i], "\n"), "email-unsupported-css",
+						fmt.Sprintf("CSS property %q is unsupported by many email clients", prop))
+				}
+			}
+			line += strings.Count(d, "\n")
+		}
+	}
+
+	inStyle := false
+	for _, t := range tokenizeLines(doc) {
+		switch t.Type {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch t.Data {
+			case "link":
+				if rel, _ := tokenAttr(&t.Token, "rel"); strings.EqualFold(strings.TrimSpace(rel), "stylesheet") {
+					warn(t.line, "email-external-stylesheet",
+						"External stylesheets are unsupported by many email clients")
+				}
+			case "script":
+				warn(t.line, "email-script", "Scripts are unsupported by email clients")
+			}
+			if style, ok := tokenAttr(&t.Token, "style"); ok {
+				checkDecls(style, t.line)
+			}
+			inStyle = t.Type == html.StartTagToken && t.Data == "style"
+		case html.TextToken:
+			if inStyle {
+				// Only check the declarations within each rule's braces.
+				line := t.line
+				text := t.Data
+				for {
+					start := strings.Index(text, "{")
+					if start < 0 {
+						break
+					}
+					line += strings.Count(text[:start], "\n")
+					text = text[start+1:]
+					end := strings.Index(text, "}")
+					if end < 0 {
+						end = len(text)
+					}
+					checkDecls(text[:end], line)
+					line += strings.Count(text[:end], "\n")
+					text = text[end:]
+				}
+			}
+		case html.EndTagToken:
+			inStyle = false
+		}
+	}
+	return issues
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage())
+	})
+	issues, _, err := Email(context.Background(), strings.NewReader(`<!DOCTYPE html>
+<html>
+  <head>
+    <title>Newsletter</title>
+    <link rel="stylesheet" href="https://example.org/style.css">
+    <style>
+      p { color: red;
+          position: absolute; }
+    </style>
+  </head>
+  <body>
+    <p style="margin: 0; z-index: 3">Hello!</p>
+    <script>track();</script>
+  </body>
+</html>
+`))
+	if err != nil {
+		t.Error("Email failed: ", err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, fmt.Sprintf("%d %v %v", is.Line, is.Severity, is.Code))
+	}
+	want := []string{
+		"5 Warning email-external-stylesheet",
+		"8 Warning email-unsupported-css",
+		"12 Warning email-unsupported-css",
+		"13 Warning email-script",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Email returned %q; want %q", got, want)
+	}
+}
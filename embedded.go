@@ -0,0 +1,73 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/net/html"
+)
+
+// splitEmbedded splits the HTML document b into a skeleton in which the contents of
+// <script> and <style> elements are blanked and a stylesheet containing only the contents
+// of <style> elements. Newlines are preserved in both, so line numbers match b's.
+func splitEmbedded(b []byte) (skel, css []byte) {
+	skel = append([]byte(nil), b...)
+	css = blankBytes(append([]byte(nil), b...))
+	var elem string // name of the current <script> or <style> element
+	for _, t := range tokenizeLines(b) {
+		switch t.Type {
+		case html.StartTagToken:
+			if t.Data == "script" || t.Data == "style" {
+				elem = t.Data
+			}
+		case html.TextToken:
+			if elem == "" {
+				continue
+			}
+			blankBytes(skel[t.offset:t.end])
+			if elem == "style" {
+				copy(css[t.offset:t.end], b[t.offset:t.end])
+			}
+		default:
+			elem = ""
+		}
+	}
+	return skel, css
+}
+
+// blankBytes replaces all bytes in b other than line endings with spaces and returns b.
+func blankBytes(b []byte) []byte {
+	for i, c := range b {
+		if c != '\n' && c != '\r' {
+			b[i] = ' '
+		}
+	}
+	return b
+}
+
+// validateEmbedded validates the HTML document in (after blanking the contents of its
+// <script> and <style> elements) and the CSS within its <style> elements separately.
+// The returned issues and page are the ones returned by the HTML validation service.
+// Issues from the CSS validation service are appended to the HTML issues and have
+// their Location fields set to StyleElement.
+func validateEmbedded(ctx context.Context, in []byte, ft FileType, opts *Options, useJSON bool) (
+	[]Issue, []byte, error) {
+	skel, css := splitEmbedded(in)
+	issues, out, err := validateHTML(ctx, skel, ft, opts, useJSON)
+	if err != nil {
+		return issues, out, err
+	}
+	if len(bytes.TrimSpace(css)) == 0 {
+		return issues, out, nil
+	}
+	cssIssues, _, err := validateCSS(ctx, css, Stylesheet, opts, useJSON)
+	setCSSColumns(cssIssues, css)
+	for i := range cssIssues {
+		cssIssues[i].Location = StyleElement
+	}
+	setSource(cssIssues, CSSSource)
+	return append(issues, cssIssues...), out, err
+}
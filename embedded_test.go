@@ -0,0 +1,81 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLWithOptions_IsolateEmbedded(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Test</title>
+    <style>
+      body { color: red; </p>
+    </style>
+    <script>if (a < b) { x(); }</script>
+  </head>
+  <body><p>Text</p></body>
+</html>
+`
+	// readUpload returns the document uploaded in r's named field.
+	readUpload := func(r *http.Request, field string) string {
+		f, _, err := r.FormFile(field)
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		return string(b)
+	}
+
+	// Simulate the HTML validator being confused by the broken stylesheet.
+	var htmlDoc, cssDoc string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		htmlDoc = readUpload(r, "uploaded_file")
+		if strings.Contains(htmlDoc, "</p>") && strings.Contains(htmlDoc, "color") {
+			io.WriteString(w, nuPage(nuError(6, 26, "Stray end tag p.")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		cssDoc = readUpload(r, "file")
+		io.WriteString(w, jigsawPage(jigsawRow("error", 6, "body", "Parse Error </p>")))
+	})
+
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc), nil)
+	if err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 || issues[0].Message != "Stray end tag p." {
+		t.Fatalf("HTMLWithOptions without IsolateEmbedded returned %q; want spurious error", issues)
+	}
+
+	issues, _, err = HTMLWithOptions(context.Background(), strings.NewReader(doc), &Options{IsolateEmbedded: true})
+	if err != nil {
+		t.Fatal("HTMLWithOptions with IsolateEmbedded failed: ", err)
+	}
+	want := []Issue{{Severity: Error, Line: 6, Message: "Parse Error </p>", Location: StyleElement, Context: "body",
+		Source: CSSSource}}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("HTMLWithOptions with IsolateEmbedded returned %q; want %q", issues, want)
+	}
+	if got, want := strings.Count(htmlDoc, "\n"), strings.Count(doc, "\n"); got != want {
+		t.Errorf("HTML validator received %v lines; want %v", got, want)
+	}
+	if strings.Contains(htmlDoc, "x();") {
+		t.Errorf("HTML validator received script contents: %q", htmlDoc)
+	}
+	if lines := strings.Split(cssDoc, "\n"); len(lines) < 6 || strings.TrimSpace(lines[5]) != "body { color: red; </p>" {
+		t.Errorf("CSS validator received %q; want stylesheet on line 6", cssDoc)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// Handler returns an HTTP handler that validates documents supplied in POST request bodies
+// and replies with a JSON array of issues. Documents with a "text/html" Content-Type header
+// are validated using HTML, "application/xhtml+xml" documents are validated using XHTML,
+// and "text/css" documents are validated using CSS.
+func Handler() http.HandlerFunc {
+	return makeHandler("")
+}
+
+// HTMLHandler is similar to Handler but always validates documents using HTML,
+// regardless of their Content-Type header.
+func HTMLHandler() http.HandlerFunc {
+	return makeHandler(HTMLDoc)
+}
+
+// CSSHandler is similar to Handler but always validates documents as stylesheets using CSS,
+// regardless of their Content-Type header.
+func CSSHandler() http.HandlerFunc {
+	return makeHandler(Stylesheet)
+}
+
+// makeHandler returns a handler that validates documents of type ft,
+// or infers the type from the Content-Type header if ft is empty.
+func makeHandler(ft FileType) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		t := ft
+		if t == "" {
+			mtype, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil {
+				http.Error(w, "Bad Content-Type header", http.StatusUnsupportedMediaType)
+				return
+			}
+			t = FileType(mtype)
+		}
+
+		var issues []Issue
+		var err error
+		switch t {
+		case HTMLDoc:
+			issues, _, err = HTML(req.Context(), req.Body)
+		case XHTMLDoc:
+			issues, _, err = XHTML(req.Context(), req.Body)
+		case Stylesheet:
+			issues, _, err = CSS(req.Context(), req.Body, Stylesheet)
+		default:
+			http.Error(w, fmt.Sprintf("Unsupported type %q", t), http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Validation failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if issues == nil {
+			issues = []Issue{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(issues)
+	}
+}
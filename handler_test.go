@@ -0,0 +1,53 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(1, 16, "HTML error")))
+	})
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(jigsawRow("error", 1, "body", "CSS error")))
+	})
+
+	for _, tc := range []struct {
+		handler http.Handler
+		ctype   string
+		status  int
+		msg     string // expected message of single issue
+	}{
+		{Handler(), "text/html; charset=utf-8", http.StatusOK, "HTML error"},
+		{Handler(), "text/css", http.StatusOK, "CSS error"},
+		{Handler(), "image/png", http.StatusUnsupportedMediaType, ""},
+		{HTMLHandler(), "text/plain", http.StatusOK, "HTML error"},
+		{CSSHandler(), "text/plain", http.StatusOK, "CSS error"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<!DOCTYPE html><bogus>"))
+		req.Header.Set("Content-Type", tc.ctype)
+		rec := httptest.NewRecorder()
+		tc.handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%q request returned %v; want %v", tc.ctype, rec.Code, tc.status)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		var issues []Issue
+		if err := json.Unmarshal(rec.Body.Bytes(), &issues); err != nil {
+			t.Errorf("Failed unmarshaling %q response %q: %v", tc.ctype, rec.Body.String(), err)
+		} else if len(issues) != 1 || issues[0].Message != tc.msg {
+			t.Errorf("%q request returned %q; want single %q issue", tc.ctype, issues, tc.msg)
+		}
+	}
+}
@@ -6,62 +6,327 @@ package validate
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // Text included in https://validator.w3.org/nu/ results pages on success.
 const htmlSuccess = "The document validates according to the specified schema(s)."
 
+// URL of the HTML validation service. Overridden by tests.
+var htmlURL = "https://validator.w3.org/nu/"
+
 // HTML reads an HTML document from r and validates it using https://validator.w3.org/nu/.
 // Parsed issues and the raw HTML results page returned by the validation service are returned.
 // If the returned error is non-nil, an issue occurred in the validation process.
 func HTML(ctx context.Context, r io.Reader) ([]Issue, []byte, error) {
-	resp, err := post(ctx, "https://validator.w3.org/nu/",
-		map[string]string{"action": "check"},
-		[]fileInfo{fileInfo{field: "uploaded_file", name: "data", ctype: string(HTMLDoc), r: r}})
+	return HTMLWithOptions(ctx, r, nil)
+}
+
+// HTMLWithOptions is similar to HTML but accepts additional options.
+// Options.HTMLServiceURL may be used to validate against a self-hosted instance of
+// the Nu HTML Checker (vnu). opts may be nil.
+func HTMLWithOptions(ctx context.Context, r io.Reader, opts *Options) ([]Issue, []byte, error) {
+	return validateMarkup(ctx, r, HTMLDoc, opts)
+}
+
+// HTMLFiles validates the HTML documents at paths using HTML, with at most concurrency
+// requests in flight at once, and returns their issues keyed by path. If concurrency is
+// less than 1, the files are validated one at a time. If some files couldn't be read or
+// validated, the successful results are returned along with an error describing the failures.
+func HTMLFiles(ctx context.Context, paths []string, concurrency int) (map[string][]Issue, error) {
+	return HTMLFilesWithOptions(ctx, paths, concurrency, nil)
+}
+
+// HTMLFilesWithOptions is similar to HTMLFiles but accepts additional options.
+// Requests are separated by at least Options.RequestInterval. opts may be nil.
+func HTMLFilesWithOptions(ctx context.Context, paths []string, concurrency int,
+	opts *Options) (map[string][]Issue, error) {
+	limiter := opts.requestLimiter()
+	return validateConcurrently(paths, concurrency, func(i int) ([]Issue, error) {
+		b, err := ioutil.ReadFile(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		issues, _, err := HTMLWithOptions(ctx, bytes.NewReader(b), opts)
+		return issues, err
+	})
+}
+
+// XHTML reads an XHTML document from r and validates it using https://validator.w3.org/nu/.
+// The document is uploaded as application/xhtml+xml and checked using the validator's XML parser,
+// so well-formedness errors (e.g. unclosed tags) that would be tolerated in HTML are reported.
+// Parsed issues and the raw HTML results page returned by the validation service are returned.
+// If the returned error is non-nil, an issue occurred in the validation process.
+func XHTML(ctx context.Context, r io.Reader) ([]Issue, []byte, error) {
+	return XHTMLWithOptions(ctx, r, nil)
+}
+
+// XHTMLWithOptions is similar to XHTML but accepts additional options.
+// opts may be nil.
+func XHTMLWithOptions(ctx context.Context, r io.Reader, opts *Options) ([]Issue, []byte, error) {
+	return validateMarkup(ctx, r, XHTMLDoc, opts)
+}
+
+// validateMarkup implements HTMLWithOptions and XHTMLWithOptions.
+// ft should be HTMLDoc or XHTMLDoc.
+func validateMarkup(ctx context.Context, r io.Reader, ft FileType, opts *Options) ([]Issue, []byte, error) {
+	ctx, cancel := opts.serviceContext(ctx)
+	defer cancel()
+
+	// Buffer the input so it can be resent if needed.
+	in, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer resp.Body.Close()
+	in, extra, err := opts.prepareInput(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	validate := validateHTML
+	if ft == HTMLDoc && opts != nil && opts.IsolateEmbedded {
+		validate = validateEmbedded
+	}
+	issues, out, err := validate(ctx, in, ft, opts, opts.useJSON())
+	if _, ok := err.(*ResponseError); ok && opts.retryAlternateFormat() {
+		if ri, rout, rerr := validate(ctx, in, ft, opts, !opts.useJSON()); rerr == nil {
+			issues, out, err = ri, rout, nil
+		}
+	}
+	if err == nil && ft == HTMLDoc && opts != nil && opts.ValidateTemplates {
+		var ti []Issue
+		ti, err = validateTemplates(ctx, in, opts)
+		issues = append(issues, ti...)
+	}
+	if err == nil && ft == HTMLDoc && opts != nil && opts.ValidateNoscript {
+		var ni []Issue
+		ni, err = validateNoscripts(ctx, in, opts)
+		issues = append(issues, ni...)
+	}
+	setSource(issues, HTMLSource)
+	issues = append(issues, extra...)
+	if opts != nil && opts.Region != WholeDocument {
+		issues = filterRegion(issues, tokenizeLines(in), opts.Region)
+	}
+	opts.mapSourceLines(issues)
+	opts.addContextWindows(issues, in)
+	normalizeColumns(issues)
+	return issues, out, err
+}
 
-	out, err := ioutil.ReadAll(resp.Body)
+// validateHTML uploads the document in of type ft (HTMLDoc or XHTMLDoc) to the validation
+// service and parses the response. If useJSON is true, the service is asked to return JSON
+// rather than an HTML page.
+func validateHTML(ctx context.Context, in []byte, ft FileType, opts *Options, useJSON bool) (
+	[]Issue, []byte, error) {
+	fields := map[string]string{"action": "check"}
+	if ft == XHTMLDoc {
+		fields["parser"] = "xml"
+	}
+	if useJSON {
+		fields["out"] = "json"
+	}
+	resp, err := post(ctx, opts, opts.htmlServiceURL(), fields,
+		[]fileInfo{fileInfo{field: "uploaded_file", name: "data", ctype: string(ft), r: bytes.NewReader(in)}},
+		opts.compressThreshold())
 	if err != nil {
 		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	node, err := html.Parse(bytes.NewReader(out))
+	if fn := opts.onIssue(); fn != nil && !useJSON {
+		// Parse the page as it's received rather than buffering it. The raw page isn't returned.
+		body := &maxSizeReader{r: resp.Body, max: opts.maxResponseSize()}
+		report := func(is Issue) { is.Source = HTMLSource; fn(is) }
+		issues, success, err := streamIssues(body, htmlSuccess, htmlIssueMatcher(opts.reportHTMLInfo()), atom.Ol, makeHTMLIssue, report)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkResponse(success, issues); err != nil {
+			return issues, nil, &ResponseError{err, nil}
+		}
+		return issues, nil, nil
+	}
+
+	out, err := readResponse(resp.Body, opts.maxResponseSize())
 	if err != nil {
-		return nil, out, fmt.Errorf("failed to parse response: %v", err)
+		return nil, nil, err
+	}
+
+	var issues []Issue
+	var success bool
+	if useJSON {
+		if issues, err = parseHTMLJSON(out, opts.reportHTMLInfo()); err != nil {
+			return nil, out, &ResponseError{err, out}
+		}
+		success = !hasErrors(issues)
+	} else if issues, success, err = parseHTMLPage(out, opts.reportHTMLInfo()); err != nil {
+		return nil, out, &ResponseError{err, out}
+	}
+	if err := checkResponse(success, issues); err != nil {
+		return issues, out, &ResponseError{err, out}
 	}
-	issues := extractHTMLIssues(node)
-	err = checkResponse(strings.Contains(string(out), htmlSuccess), issues)
 	return issues, out, nil
 }
 
+// parseHTMLJSON parses issues from a JSON response returned by https://validator.w3.org/nu/.
+// See https://github.com/validator/validator/wiki/Output-»-JSON for the format.
+// If info is true, informational messages are returned as Info issues.
+func parseHTMLJSON(b []byte, info bool) ([]Issue, error) {
+	var out struct {
+		Messages []struct {
+			Type       string `json:"type"`    // "error", "info", "non-document-error"
+			SubType    string `json:"subType"` // "warning" for some "info" messages
+			LastLine   int    `json:"lastLine"`
+			LastColumn int    `json:"lastColumn"`
+			Message    string `json:"message"`
+			Extract    string `json:"extract"`
+			// These are counts of UTF-16 code units within Extract.
+			HiliteStart  int `json:"hiliteStart"`
+			HiliteLength int `json:"hiliteLength"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var issues []Issue
+	for _, m := range out.Messages {
+		switch m.Type {
+		case "error":
+			is := Issue{
+				Severity: Error,
+				Line:     m.LastLine,
+				Col:      m.LastColumn,
+				Message:  m.Message,
+				Context:  strings.TrimSpace(m.Extract),
+			}
+			if m.HiliteLength > 0 {
+				// Convert the range to byte offsets within the trimmed context.
+				trimmed := len(m.Extract) - len(strings.TrimLeftFunc(m.Extract, unicode.IsSpace))
+				start := utf16ToByteOffset(m.Extract, m.HiliteStart)
+				end := utf16ToByteOffset(m.Extract, m.HiliteStart+m.HiliteLength)
+				if start >= trimmed && end <= trimmed+len(is.Context) {
+					is.HighlightStart = start - trimmed
+					is.HighlightLength = end - start
+				}
+			}
+			issues = append(issues, is)
+		case "info":
+			sev := Info
+			if m.SubType == "warning" {
+				sev = Warning
+			} else if !info {
+				continue
+			}
+			issues = append(issues, Issue{
+				Severity: sev,
+				Line:     m.LastLine,
+				Col:      m.LastColumn,
+				Message:  m.Message,
+				Context:  strings.TrimSpace(m.Extract),
+			})
+		case "non-document-error":
+			return nil, fmt.Errorf("validator reported error: %v", m.Message)
+		}
+	}
+	return issues, nil
+}
+
+// utf16ToByteOffset returns the byte offset within s corresponding to n UTF-16 code units.
+func utf16ToByteOffset(s string, n int) int {
+	units := 0
+	for i, r := range s {
+		if units >= n {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(s)
+}
+
+// ParseHTMLResults parses issues from b, a results page previously returned by the
+// validation service (e.g. the raw output saved from HTML). An error is returned if
+// the page can't be parsed or doesn't look like a results page.
+func ParseHTMLResults(b []byte) ([]Issue, error) {
+	issues, success, err := parseHTMLPage(b, false)
+	if err != nil {
+		return nil, err
+	}
+	return issues, checkResponse(success, issues)
+}
+
+// parseHTMLPage parses issues from the HTML results page b returned by https://validator.w3.org/nu/.
+// The returned bool reports whether the page reported that the document was valid.
+// If info is true, informational messages are returned as Info issues.
+func parseHTMLPage(b []byte, info bool) ([]Issue, bool, error) {
+	node, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return extractHTMLIssues(node, info), strings.Contains(string(b), htmlSuccess), nil
+}
+
 // extractHTMLIssues recursively walks n and returns validation issues.
 // n is all or part of a document returned by https://validator.w3.org/nu/,
-// where errors are denoted by <li class="error">.
-func extractHTMLIssues(n *html.Node) []Issue {
-	// TODO: Does the validator return warnings?
-	if n.Type == html.ElementNode && n.Data == "li" && getAttr(n, "class") == "error" {
-		return []Issue{makeHTMLIssue(n, Error)}
+// where errors are denoted by <li class="error"> and warnings by <li class="warning"> or
+// <li class="info warning">. If info is true, informational messages (denoted by
+// <li class="info">) are returned as Info issues.
+func extractHTMLIssues(n *html.Node, info bool) []Issue {
+	if n.Type == html.ElementNode && n.Data == "li" {
+		if sev, ok := nuIssueSeverity(getAttr(n, "class"), info); ok {
+			return []Issue{makeHTMLIssue(n, sev)}
+		}
 	}
 
 	var issues []Issue
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		issues = append(issues, extractHTMLIssues(c)...)
+		issues = append(issues, extractHTMLIssues(c, info)...)
 	}
 	return issues
 }
 
-// makeHTMLIssue creates a new issue by examining the supplied <li class="error"> node.
+// nuIssueSeverity returns the severity of the issue described by an <li> element with
+// the supplied class attribute in a results page returned by https://validator.w3.org/nu/.
+// false is returned if the element doesn't describe an issue or describes an informational
+// message and info is false.
+func nuIssueSeverity(class string, info bool) (Severity, bool) {
+	var isError, isWarning, isInfo bool
+	for _, c := range strings.Fields(class) {
+		switch c {
+		case "error":
+			isError = true
+		case "warning":
+			isWarning = true
+		case "info":
+			isInfo = true
+		}
+	}
+	switch {
+	case isError:
+		return Error, true
+	case isWarning:
+		return Warning, true
+	case isInfo:
+		return Info, info
+	}
+	return 0, false
+}
+
+// makeHTMLIssue creates a new issue by examining the supplied <li class="error">,
+// <li class="warning">, or <li class="info"> node.
 //
 // Here's an example error, with line breaks and whitespace added for legibility:
 //
@@ -98,6 +363,13 @@ func makeHTMLIssue(li *html.Node, sev Severity) Issue {
 				return n.Type == html.ElementNode && n.Data == "span" && getAttr(n, "class") == "last-col"
 			})
 			is.Col, _ = strconv.Atoi(strings.TrimSpace(cstr))
+
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && c.Data == "a" {
+					is.Anchor = strings.TrimPrefix(getAttr(c, "href"), "#")
+					break
+				}
+			}
 		case "extract":
 			is.Context = strings.TrimSpace(getText(n, nil))
 		case "":
@@ -107,6 +379,8 @@ func makeHTMLIssue(li *html.Node, sev Severity) Issue {
 			is.Message = spacesAroundLines.ReplaceAllString(msg, "\n")
 		}
 	}
+	// The service links some messages (or elaborations following them) to help or spec pages.
+	is.URL = getLinkURL(li)
 
 	return is
 }
@@ -4,12 +4,28 @@
 package validate
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/net/html"
 )
 
 func TestHTML_Valid(t *testing.T) {
+	fakeNu(t)
 	issues, out, err := HTML(context.Background(), strings.NewReader(`<!DOCTYPE html>
 <html>
   <head>
@@ -31,6 +47,7 @@ func TestHTML_Valid(t *testing.T) {
 }
 
 func TestHTML_Invalid(t *testing.T) {
+	fakeNu(t)
 	issues, out, err := HTML(context.Background(), strings.NewReader(`<!DOCTYPE html>
 <html>
   <head>
@@ -61,3 +78,702 @@ func TestHTML_Invalid(t *testing.T) {
 		t.Error("HTML returned empty output")
 	}
 }
+
+func TestMakeHTMLIssue_Anchor(t *testing.T) {
+	// This is the example from makeHTMLIssue's comment.
+	const li = `<li class="error">
+  <p>    <strong>Error</strong>: <span>Saw <code>&lt;&gt;</code>. Probable causes:
+    Unescaped <code>&lt;</code> (escape as <code>&amp;lt;</code>) or mistyped
+    start tag.</span>
+  </p>
+  <p class="location">
+    <a href="#cl6c14">At line <span class="last-line">6</span>, column
+    <span class="last-col">14</span></a>
+  </p>
+  <p class="extract">    <code>&gt;<span class="lf" title="Line break">↩</span>ueaueohtn
+    u&gt;&lt;<b>&gt;</b>&lt;&gt; Y<span class="lf" title="Line
+    break">↩</span>&lt;body&gt;<span class="lf" title="Line
+    break">↩</span>&lt;p</code>
+  </p>
+</li>`
+	root, err := html.Parse(strings.NewReader("<ul>" + li + "</ul>"))
+	if err != nil {
+		t.Fatal("Failed parsing fragment: ", err)
+	}
+	issues := extractHTMLIssues(root, false)
+	if len(issues) != 1 {
+		t.Fatalf("Got %v issues (%q); want 1", len(issues), issues)
+	}
+	is := issues[0]
+	if is.Line != 6 || is.Col != 14 {
+		t.Errorf("Got issue at %d:%d; want 6:14", is.Line, is.Col)
+	}
+	if want := "cl6c14"; is.Anchor != want {
+		t.Errorf("Got anchor %q; want %q", is.Anchor, want)
+	}
+	if is.URL != "" {
+		t.Errorf("Got URL %q; want none", is.URL)
+	}
+}
+
+func TestMakeHTMLIssue_URL(t *testing.T) {
+	const (
+		help = "https://html.spec.whatwg.org/multipage/grouping-content.html#the-p-element"
+		li   = `<li class="error"><p><strong>Error</strong>: <span>Element <code>div</code> not allowed ` +
+			`as child of element <code>p</code> in this context.</span></p>` +
+			`<p class="location"><a href="#l3c6">At line <span class="last-line">3</span>, column ` +
+			`<span class="last-col">6</span></a></p>` +
+			`<dl><dt>Content model for element <a href="` + help + `">p</a>:</dt>` +
+			`<dd>Phrasing content.</dd></dl></li>`
+	)
+	root, err := html.Parse(strings.NewReader("<ul>" + li + "</ul>"))
+	if err != nil {
+		t.Fatal("Failed parsing fragment: ", err)
+	}
+	issues := extractHTMLIssues(root, false)
+	if len(issues) != 1 {
+		t.Fatalf("Got %v issues (%q); want 1", len(issues), issues)
+	}
+	if issues[0].URL != help {
+		t.Errorf("Got URL %q; want %q", issues[0].URL, help)
+	}
+}
+
+func TestHTMLWithOptions_MaxResponseSize(t *testing.T) {
+	const max = 1024
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		chunk := []byte(strings.Repeat("x", 256))
+		for i := 0; i < 16; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+	_, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"),
+		&Options{MaxResponseSize: max})
+	if err == nil {
+		t.Fatal("HTMLWithOptions unexpectedly succeeded with oversized response")
+	}
+	if want := "exceeded max size"; !strings.Contains(err.Error(), want) {
+		t.Errorf("HTMLWithOptions returned error %q; want one containing %q", err, want)
+	}
+}
+
+func TestHTMLWithOptions_SourceLineMap(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(8, 11, "Element bogus not allowed as child of element body")))
+	})
+	// Pretend that the HTML document was generated from a Markdown file, and that the
+	// document's line 8 came from the Markdown file's line 3.
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"),
+		&Options{SourceLineMap: func(line int) int {
+			if line == 8 {
+				return 3
+			}
+			return 0
+		}})
+	if err != nil {
+		t.Error("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("HTMLWithOptions returned %v issues (%q); want 1", len(issues), issues)
+	}
+	if is := issues[0]; is.Line != 8 || is.SourceLine != 3 {
+		t.Errorf("HTMLWithOptions returned issue with line %d and source line %d; want 8 and 3",
+			is.Line, is.SourceLine)
+	}
+}
+
+func TestParseHTMLResults(t *testing.T) {
+	issues, err := ParseHTMLResults([]byte(nuPage(
+		nuError(8, 11, "Element bogus not allowed as child of element body"),
+		nuError(12, 3, "Stray end tag div."))))
+	if err != nil {
+		t.Fatal("ParseHTMLResults failed: ", err)
+	}
+	want := []Issue{
+		{Severity: Error, Line: 8, Col: 11, Message: "Element bogus not allowed as child of element body", Anchor: "l8c11"},
+		{Severity: Error, Line: 12, Col: 3, Message: "Stray end tag div.", Anchor: "l12c3"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("ParseHTMLResults returned %q; want %q", issues, want)
+	}
+
+	if issues, err := ParseHTMLResults([]byte(nuPage())); err != nil {
+		t.Error("ParseHTMLResults failed for successful page: ", err)
+	} else if len(issues) != 0 {
+		t.Errorf("ParseHTMLResults returned %q for successful page", issues)
+	}
+	if _, err := ParseHTMLResults([]byte("<html><body>Not a results page</body></html>")); err == nil {
+		t.Error("ParseHTMLResults unexpectedly succeeded for non-results page")
+	}
+}
+
+// nuPage returns a minimal results page in the format used by https://validator.w3.org/nu/
+// containing the supplied <li> elements (see nuError). If no elements are supplied,
+// the page reports success.
+func nuPage(items ...string) string {
+	s := "<!DOCTYPE html><html><head><title>Showing results</title></head><body><div id=\"results\">"
+	if len(items) == 0 {
+		s += "<p class=\"success\">" + htmlSuccess + "</p>"
+	} else {
+		s += "<ol>" + strings.Join(items, "") + "</ol>"
+		s += "<p class=\"failure\">There were errors.</p>"
+	}
+	return s + "</div></body></html>"
+}
+
+// fakeNu installs a fake HTML validation service that mimics https://validator.w3.org/nu/
+// by reporting an error at the end of each <bogus> start tag in the uploaded document.
+func fakeNu(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		var items []string
+		for i, ln := range strings.Split(string(b), "\n") {
+			if j := strings.Index(ln, "<bogus>"); j >= 0 {
+				items = append(items, nuError(i+1, j+len("<bogus>"),
+					"Element bogus not allowed as child of element body"))
+			}
+		}
+		io.WriteString(w, nuPage(items...))
+	})
+}
+
+// nuError returns an <li class="error"> element describing an error at the supplied location.
+func nuError(line, col int, msg string) string {
+	return fmt.Sprintf(`<li class="error"><p><strong>Error</strong>: <span>%s</span></p>`+
+		`<p class="location"><a href="#l%dc%d">At line <span class="last-line">%d</span>, column `+
+		`<span class="last-col">%d</span></a></p></li>`, html.EscapeString(msg), line, col, line, col)
+}
+
+func TestHTMLWithOptions_RetryAlternateFormat(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("out") == "json" {
+			io.WriteString(w, `{"messages":[{"type":"error","lastLine":8,"lastColumn":11,`+
+				`"message":"Element bogus not allowed as child of element body","extract":"<bogus>"}]}`)
+		} else {
+			// Simulate a change to the HTML format that leaves us unable to find errors.
+			io.WriteString(w, `<!DOCTYPE html><html><body><li class="problem">Error</li></body></html>`)
+		}
+	})
+
+	doc := "<!DOCTYPE html>"
+	if _, out, err := HTMLWithOptions(context.Background(), strings.NewReader(doc), nil); err == nil {
+		t.Error("HTMLWithOptions unexpectedly succeeded without retrying")
+	} else if rerr, ok := err.(*ResponseError); !ok {
+		t.Errorf("HTMLWithOptions returned %T %q; want *ResponseError", err, err)
+	} else if !bytes.Equal(rerr.Response, out) || len(out) == 0 {
+		t.Errorf("ResponseError contains response %q; want %q", rerr.Response, out)
+	}
+
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc),
+		&Options{RetryAlternateFormat: true})
+	if err != nil {
+		t.Error("HTMLWithOptions failed: ", err)
+	}
+	want := []Issue{{
+		Severity: Error,
+		Line:     8,
+		Col:      11,
+		Message:  "Element bogus not allowed as child of element body",
+		Context:  "<bogus>",
+		Source:   HTMLSource,
+	}}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("HTMLWithOptions returned %q; want %q", issues, want)
+	}
+}
+
+func TestHTMLWithOptions_ContextLines(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(5, 5, "Element bogus not allowed as child of element body")))
+	})
+	const doc = "<!DOCTYPE html>\n<html>\n<body>\n<p>\n<bogus>\n</p>\n</body>\n</html>\n"
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc), &Options{ContextLines: 2})
+	if err != nil {
+		t.Error("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("HTMLWithOptions returned %v issues (%q); want 1", len(issues), issues)
+	}
+	want := "  <body>\n  <p>\n> <bogus>\n  </p>\n  </body>"
+	if got := issues[0].ContextWindow; got != want {
+		t.Errorf("HTMLWithOptions returned context window %q; want %q", got, want)
+	}
+}
+
+func TestHTMLWithOptions_NormalizeLineEndings(t *testing.T) {
+	var uploaded []byte
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		uploaded, _ = ioutil.ReadAll(f)
+		// Report an error on the line containing the bogus element.
+		line := bytes.Count(uploaded[:bytes.Index(uploaded, []byte("<bogus>"))], []byte("\n")) + 1
+		io.WriteString(w, nuPage(nuError(line, 1, "Bad element")))
+	})
+
+	for _, tc := range []struct {
+		doc      string
+		wantInfo int // line of mixed-endings notice, or 0
+	}{
+		{"<!DOCTYPE html>\r\n<html>\r\n<body>\r\n<bogus>\r\n</body>\r\n</html>\r\n", 0},
+		{"<!DOCTYPE html>\r\n<html>\n<body>\r\n<bogus>\r\n</body>\r\n</html>\r\n", 2},
+	} {
+		issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(tc.doc),
+			&Options{NormalizeLineEndings: true, ReportMixedLineEndings: true})
+		if err != nil {
+			t.Errorf("HTMLWithOptions(%q) failed: %v", tc.doc, err)
+			continue
+		}
+		if bytes.Contains(uploaded, []byte("\r")) {
+			t.Errorf("HTMLWithOptions(%q) uploaded %q", tc.doc, uploaded)
+		}
+		want := []Issue{{Severity: Error, Line: 4, Col: 1, Message: "Bad element", Anchor: "l4c1",
+			Source: HTMLSource}}
+		if tc.wantInfo > 0 {
+			want = append(want, Issue{
+				Severity: Info,
+				Line:     tc.wantInfo,
+				Message:  "Document uses mixed line endings",
+				Code:     "mixed-line-endings",
+			})
+		}
+		if !reflect.DeepEqual(issues, want) {
+			t.Errorf("HTMLWithOptions(%q) returned %q; want %q", tc.doc, issues, want)
+		}
+	}
+}
+
+func TestHTMLWithOptions_ReportHTMLInfo(t *testing.T) {
+	const msg = "The Content-Type was text/html. Using the HTML parser."
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Replace(nuPage(), `<div id="results">`,
+			`<div id="results"><ol><li class="info"><p><strong>Info</strong>: <span>`+msg+
+				`</span></p></li></ol>`, 1))
+	})
+	for _, report := range []bool{false, true} {
+		issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"),
+			&Options{ReportHTMLInfo: report})
+		if err != nil {
+			t.Errorf("HTMLWithOptions with ReportHTMLInfo=%v failed: %v", report, err)
+			continue
+		}
+		var want []Issue
+		if report {
+			want = []Issue{{Severity: Info, Message: msg, Source: HTMLSource}}
+		}
+		if !reflect.DeepEqual(issues, want) {
+			t.Errorf("HTMLWithOptions with ReportHTMLInfo=%v returned %q; want %q", report, issues, want)
+		}
+	}
+}
+
+func TestHTMLWithOptions_Warnings(t *testing.T) {
+	// This is reported for e.g. "<section><p>Hi</p></section>".
+	const msg = "Section lacks heading. Consider using h2-h6 elements to add identifying headings to all sections."
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("out") == "json" {
+			io.WriteString(w, `{"messages":[{"type":"info","subType":"warning","lastLine":4,"lastColumn":9,`+
+				`"message":"`+msg+`"},{"type":"info","lastLine":1,"message":"Using the HTML parser."}]}`)
+			return
+		}
+		io.WriteString(w, strings.Replace(nuPage(), `<div id="results">`,
+			`<div id="results"><ol><li class="info warning"><p><strong>Warning</strong>: <span>`+msg+
+				`</span></p><p class="location"><a href="#l4c9">At line <span class="last-line">4</span>, `+
+				`column <span class="last-col">9</span></a></p></li></ol>`, 1))
+	})
+	for _, opts := range []*Options{
+		{},
+		{JSON: true},
+		{OnIssue: func(Issue) {}},
+	} {
+		issues, _, err := HTMLWithOptions(context.Background(),
+			strings.NewReader("<!DOCTYPE html>\n<html lang=\"en\">\n<title>Test</title>\n<section><p>Hi</p></section>\n"),
+			opts)
+		if err != nil {
+			t.Errorf("HTMLWithOptions(%+v) failed: %v", opts, err)
+			continue
+		}
+		want := []Issue{{Severity: Warning, Line: 4, Col: 9, Message: msg, Source: HTMLSource}}
+		if missing, extra := CompareIssues(issues, want, "Anchor"); len(missing) > 0 || len(extra) > 0 {
+			t.Errorf("HTMLWithOptions(%+v) returned %q; want %q", opts, issues, want)
+		}
+	}
+}
+
+func TestHTMLWithOptions_OnIssue(t *testing.T) {
+	// Build a large page and send its first half before waiting for an issue to be reported.
+	const n = 2000
+	var items []string
+	for i := 1; i <= n; i++ {
+		items = append(items, nuError(i, 1, fmt.Sprintf("Error %d", i)))
+	}
+	page := nuPage(items...)
+	split := strings.Index(page, items[n/2])
+
+	first := make(chan struct{})
+	var early bool // true if an issue was reported before the second half was sent
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, page[:split])
+		w.(http.Flusher).Flush()
+		select {
+		case <-first:
+			early = true
+		case <-time.After(10 * time.Second):
+		}
+		io.WriteString(w, page[split:])
+	})
+
+	var reported []Issue
+	issues, out, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"),
+		&Options{OnIssue: func(is Issue) {
+			if reported = append(reported, is); len(reported) == 1 {
+				close(first)
+			}
+		}})
+	if err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	}
+	if !early {
+		t.Error("OnIssue wasn't called before the entire page was received")
+	}
+	if len(issues) != n {
+		t.Errorf("HTMLWithOptions returned %v issues; want %v", len(issues), n)
+	}
+	if !reflect.DeepEqual(reported, issues) {
+		t.Error("Issues passed to OnIssue don't match returned issues")
+	}
+	if out != nil {
+		t.Errorf("HTMLWithOptions returned %v-byte page; want nil", len(out))
+	}
+}
+
+func TestHTMLWithOptions_ServiceTimeout(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices that the client has gone away after the body is read.
+		io.Copy(ioutil.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	})
+	_, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"),
+		&Options{ServiceTimeout: 10 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("HTMLWithOptions returned %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseHTMLJSON_Highlight(t *testing.T) {
+	// This is based on a message returned by the validator, with a non-ASCII character
+	// added to the extract to exercise conversion from UTF-16 code units to bytes.
+	const msg = `{"messages":[{"type":"error","lastLine":8,"lastColumn":11,"firstColumn":5,` +
+		`"message":"Element “bogus” not allowed as child of element “body” in this context.",` +
+		`"extract":"  <body>é\n    <bogus>Test</bogus>","hiliteStart":14,"hiliteLength":7}]}`
+	issues, err := parseHTMLJSON([]byte(msg), false)
+	if err != nil {
+		t.Fatal("parseHTMLJSON failed: ", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("parseHTMLJSON returned %v issues (%q); want 1", len(issues), issues)
+	}
+	is := issues[0]
+	if want := 13; is.HighlightStart != want || is.HighlightLength != 7 {
+		t.Errorf("parseHTMLJSON returned highlight (%d, %d); want (%d, 7)",
+			is.HighlightStart, is.HighlightLength, want)
+	} else if got := is.Context[is.HighlightStart : is.HighlightStart+is.HighlightLength]; got != "<bogus>" {
+		t.Errorf("Highlighted %q in context %q; want %q", got, is.Context, "<bogus>")
+	}
+}
+
+func TestXHTML(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, hdr, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if ct := hdr.Header.Get("Content-Type"); ct != XHTMLDoc || r.FormValue("parser") != "xml" {
+			http.Error(w, fmt.Sprintf("Got type %q and parser %q", ct, r.FormValue("parser")),
+				http.StatusBadRequest)
+			return
+		}
+		// Pretend to be the XML parser, which rejects unclosed tags.
+		b, _ := ioutil.ReadAll(f)
+		if strings.Contains(string(b), "<br>") {
+			io.WriteString(w, nuPage(nuError(4, 13, "XML parse error")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+  <head><title>Test</title></head>
+  <body>Hi<br/></body>
+</html>
+`
+	if issues, _, err := XHTML(context.Background(), strings.NewReader(doc)); err != nil {
+		t.Error("XHTML failed for valid document: ", err)
+	} else if len(issues) != 0 {
+		t.Errorf("XHTML returned issues for valid document: %q", issues)
+	}
+
+	bad := strings.Replace(doc, "<br/>", "<br>", 1)
+	issues, _, err := XHTML(context.Background(), strings.NewReader(bad))
+	if err != nil {
+		t.Error("XHTML failed for document with unclosed tag: ", err)
+	}
+	if len(issues) != 1 || issues[0].Line != 4 {
+		t.Errorf("XHTML returned %q; want one issue at line 4", issues)
+	}
+}
+
+func TestHTMLWithOptions_Preprocess(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if b, _ := ioutil.ReadAll(f); bytes.Contains(b, []byte("<bogus>")) {
+			io.WriteString(w, nuPage(nuError(2, 8, "Element bogus not allowed as child of element body")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+
+	// Pretend to expand a template that produces invalid markup.
+	const doc = "<!DOCTYPE html>\n{{widget}}\n"
+	opts := &Options{Preprocess: func(b []byte) ([]byte, error) {
+		return bytes.ReplaceAll(b, []byte("{{widget}}"), []byte("<bogus>")), nil
+	}}
+	if issues, _, err := HTML(context.Background(), strings.NewReader(doc)); err != nil {
+		t.Error("HTML failed: ", err)
+	} else if len(issues) != 0 {
+		t.Errorf("HTML returned %q for unprocessed document", issues)
+	}
+	if issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc), opts); err != nil {
+		t.Error("HTMLWithOptions failed: ", err)
+	} else if len(issues) != 1 || issues[0].Line != 2 {
+		t.Errorf("HTMLWithOptions returned %q; want one issue at line 2", issues)
+	}
+
+	opts.Preprocess = func(b []byte) ([]byte, error) { return nil, errors.New("bad template") }
+	if _, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc), opts); err == nil {
+		t.Error("HTMLWithOptions unexpectedly succeeded when preprocessing failed")
+	}
+}
+
+func TestHTMLWithOptions_Redirect(t *testing.T) {
+	// Serve an unrelated page (e.g. a maintenance notice) that contains no issues.
+	otherRequests := 0
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherRequests++
+		io.WriteString(w, "<!DOCTYPE html><html><body><p>Down for maintenance</p></body></html>")
+	}))
+	defer other.Close()
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/maintenance", http.StatusFound)
+	})
+
+	for _, tc := range []struct {
+		opts      *Options
+		wantOther int // expected requests to other server
+	}{
+		{nil, 1},
+		{&Options{DisallowServiceRedirects: true}, 0},
+	} {
+		otherRequests = 0
+		_, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"), tc.opts)
+		var rerr *RedirectError
+		if !errors.As(err, &rerr) {
+			t.Errorf("HTMLWithOptions(%+v) returned error %v; want *RedirectError", tc.opts, err)
+		} else if want := other.URL + "/maintenance"; rerr.URL != want {
+			t.Errorf("HTMLWithOptions(%+v) returned *RedirectError with URL %q; want %q", tc.opts, rerr.URL, want)
+		}
+		if otherRequests != tc.wantOther {
+			t.Errorf("HTMLWithOptions(%+v) made %d request(s) to other server; want %d",
+				tc.opts, otherRequests, tc.wantOther)
+		}
+	}
+}
+
+func TestHTMLWithOptions_HTMLServiceURL(t *testing.T) {
+	defaultRequests := 0
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		defaultRequests++
+		io.WriteString(w, nuPage())
+	})
+	// Simulate a self-hosted Nu HTML Checker instance that isn't served from the root.
+	var paths []string
+	vnu := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.WriteString(w, nuPage(nuError(1, 16, "Element bogus not allowed")))
+	}))
+	defer vnu.Close()
+
+	opts := &Options{HTMLServiceURL: vnu.URL + "/vnu/"}
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html><bogus>"), opts)
+	if err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 || issues[0].Message != "Element bogus not allowed" {
+		t.Errorf("HTMLWithOptions returned %q; want one issue from self-hosted service", issues)
+	}
+	if want := []string{"/vnu/"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Self-hosted service got requests for %q; want %q", paths, want)
+	}
+	if defaultRequests != 0 {
+		t.Errorf("Default service got %d request(s); want 0", defaultRequests)
+	}
+
+	// The default service should be used if HTMLServiceURL is empty.
+	if _, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"),
+		&Options{}); err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	} else if defaultRequests != 1 {
+		t.Errorf("Default service got %d request(s); want 1", defaultRequests)
+	}
+}
+
+func TestHTMLFiles(t *testing.T) {
+	const concurrency = 2
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		switch doc := string(b); {
+		case strings.Contains(doc, "FAIL"):
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		case strings.Contains(doc, "<bogus>"):
+			io.WriteString(w, nuPage(nuError(1, 22, "Element bogus not allowed")))
+		default:
+			io.WriteString(w, nuPage())
+		}
+	})
+
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	var paths []string
+	for name, doc := range map[string]string{
+		"a.html": "<!DOCTYPE html><bogus>",
+		"b.html": "<!DOCTYPE html>",
+		"c.html": "<!DOCTYPE html>",
+		"d.html": "<!DOCTYPE html>",
+		"e.html": "<!DOCTYPE html>FAIL",
+	} {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	missing := filepath.Join(dir, "missing.html")
+	paths = append(paths, missing)
+
+	results, err := HTMLFiles(context.Background(), paths, concurrency)
+	if err == nil {
+		t.Error("HTMLFiles unexpectedly succeeded")
+	} else if msg := err.Error(); !strings.Contains(msg, "2 file(s)") ||
+		!strings.Contains(msg, filepath.Join(dir, "e.html")) || !strings.Contains(msg, missing) {
+		t.Errorf("HTMLFiles returned error %q; want it to describe failures for e.html and missing.html", msg)
+	}
+	if len(results) != 4 {
+		t.Errorf("HTMLFiles returned results for %d file(s); want 4", len(results))
+	}
+	if issues := results[filepath.Join(dir, "a.html")]; len(issues) != 1 || issues[0].Line != 1 {
+		t.Errorf("HTMLFiles returned %q for a.html; want 1 issue", issues)
+	}
+	if issues, ok := results[filepath.Join(dir, "b.html")]; !ok || len(issues) != 0 {
+		t.Errorf("HTMLFiles returned %q (present=%v) for b.html; want no issues", issues, ok)
+	}
+	if maxActive > concurrency {
+		t.Errorf("Service handled %d concurrent requests; want at most %d", maxActive, concurrency)
+	}
+}
+
+func TestHTMLWithOptions_CompressHTML(t *testing.T) {
+	var encodings []string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		enc := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, enc)
+		if enc == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = zr
+		}
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if b, _ := ioutil.ReadAll(f); bytes.Contains(b, []byte("<bogus>")) {
+			io.WriteString(w, nuPage(nuError(1, 22, "Element bogus not allowed")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+
+	const small = "<!DOCTYPE html><bogus>"
+	large := small + strings.Repeat("<p>x</p>", 1000)
+	for i, tc := range []struct {
+		doc  string
+		opts *Options
+		enc  string
+	}{
+		{large, nil, ""},
+		{small, &Options{CompressHTML: true}, ""},
+		{large, &Options{CompressHTML: true}, ""}, // under DefaultCompressThreshold
+		{large, &Options{CompressHTML: true, CompressThreshold: 1024}, "gzip"},
+	} {
+		encodings = nil
+		issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(tc.doc), tc.opts)
+		if err != nil {
+			t.Errorf("Case %d: HTMLWithOptions failed: %v", i, err)
+			continue
+		}
+		if len(issues) != 1 || issues[0].Message != "Element bogus not allowed" {
+			t.Errorf("Case %d: HTMLWithOptions returned %q; want one issue", i, issues)
+		}
+		if want := []string{tc.enc}; !reflect.DeepEqual(encodings, want) {
+			t.Errorf("Case %d: HTMLWithOptions sent encodings %q; want %q", i, encodings, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// CompareIssues compares got against want, ignoring the Issue fields named in ignoreFields
+// (e.g. "URL" or "Col"). Issues from want that weren't found in got are returned in missing,
+// while issues from got that weren't found in want are returned in extra. Both are empty
+// if the slices contain the same issues, regardless of order.
+//
+// CompareIssues panics if ignoreFields contains an unknown field name.
+func CompareIssues(got, want []Issue, ignoreFields ...string) (missing, extra []Issue) {
+	clean := func(is Issue) Issue {
+		v := reflect.ValueOf(&is).Elem()
+		for _, name := range ignoreFields {
+			f := v.FieldByName(name)
+			if !f.IsValid() {
+				panic(fmt.Sprintf("unknown Issue field %q", name))
+			}
+			f.Set(reflect.Zero(f.Type()))
+		}
+		return is
+	}
+
+	matched := make([]bool, len(got))
+	for _, w := range want {
+		cw := clean(w)
+		found := false
+		for i, g := range got {
+			if !matched[i] && reflect.DeepEqual(clean(g), cw) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+	for i, g := range got {
+		if !matched[i] {
+			extra = append(extra, g)
+		}
+	}
+	return missing, extra
+}
+
+// DefaultFingerprintFields lists the Issue fields used by Issue.Fingerprint. Line and Col
+// (along with other location-specific fields like Context) are excluded so that fingerprints
+// are unaffected by edits elsewhere in the document.
+var DefaultFingerprintFields = []string{"Severity", "Code", "Message", "File"}
+
+// Fingerprint returns a stable hex-encoded hash of is's Severity, Code, Message, and File
+// fields (see DefaultFingerprintFields), suitable for identifying the issue across
+// validation runs, e.g. for deduplication or storing baselines.
+func (is Issue) Fingerprint() string {
+	return is.FingerprintFields(DefaultFingerprintFields...)
+}
+
+// FingerprintFields is similar to Fingerprint but hashes the named Issue fields instead.
+// It panics if fields contains an unknown field name.
+func (is Issue) FingerprintFields(fields ...string) string {
+	v := reflect.ValueOf(is)
+	h := sha256.New()
+	for _, name := range fields {
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			panic(fmt.Sprintf("unknown Issue field %q", name))
+		}
+		fmt.Fprintf(h, "%s=%v\x00", name, f.Interface())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// GroupByCode returns issues grouped by their Code fields, preserving their original order
+// within each group. Issues without codes are grouped under the empty string.
+func GroupByCode(issues []Issue) map[string][]Issue {
+	groups := make(map[string][]Issue)
+	for _, is := range issues {
+		groups[is.Code] = append(groups[is.Code], is)
+	}
+	return groups
+}
+
+// CodeCounts returns the number of issues with each Code.
+// Issues without codes are counted under the empty string.
+func CodeCounts(issues []Issue) map[string]int {
+	counts := make(map[string]int)
+	for _, is := range issues {
+		counts[is.Code]++
+	}
+	return counts
+}
+
+// RelativizeResults returns a copy of results (e.g. as returned by AMPFiles) with keys and
+// Issue.File fields rewritten to be relative to the directory base. Relative paths are
+// interpreted relative to the current directory. Paths outside of base are left unchanged.
+func RelativizeResults(results map[string][]Issue, base string) map[string][]Issue {
+	out := make(map[string][]Issue, len(results))
+	for p, issues := range results {
+		rel := relativePath(p, base)
+		ri := make([]Issue, len(issues))
+		for i, is := range issues {
+			if is.File != "" {
+				is.File = relativePath(is.File, base)
+			}
+			ri[i] = is
+		}
+		out[rel] = ri
+	}
+	return out
+}
+
+// relativePath returns p relative to base, or p if it isn't within base.
+func relativePath(p, base string) string {
+	ap, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	ab, err := filepath.Abs(base)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(ab, ap)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return rel
+}
+
+// MaxSummaryLen is the maximum length in bytes of strings returned by SummaryLine.
+// It matches the limit on GitHub commit status descriptions.
+const MaxSummaryLen = 140
+
+// SummaryLine returns a single-line description of results (keyed by path) suitable for
+// e.g. a commit status, like "3 errors, 1 warning in 2 files (1 HTML, 3 CSS)". The
+// parenthesized counts are the number of issues reported by each validator (see
+// Issue.Source), with issues from local checks counted as "other". The returned string is
+// at most MaxSummaryLen bytes long.
+func SummaryLine(results map[string][]Issue) string {
+	var nerrors, nwarnings, ninfo, nfiles int
+	sources := make(map[IssueSource]int)
+	for _, issues := range results {
+		if len(issues) > 0 {
+			nfiles++
+		}
+		for _, is := range issues {
+			switch is.Severity {
+			case Error:
+				nerrors++
+			case Warning:
+				nwarnings++
+			case Info:
+				ninfo++
+			}
+			sources[is.Source]++
+		}
+	}
+	if nfiles == 0 {
+		return fmt.Sprintf("No issues in %s", plural(len(results), "file"))
+	}
+
+	var counts []string
+	for _, c := range []struct {
+		n    int
+		noun string
+	}{{nerrors, "error"}, {nwarnings, "warning"}, {ninfo, "info message"}} {
+		if c.n > 0 {
+			counts = append(counts, plural(c.n, c.noun))
+		}
+	}
+	s := fmt.Sprintf("%s in %s", strings.Join(counts, ", "), plural(nfiles, "file"))
+
+	var srcs []string
+	for _, src := range []IssueSource{HTMLSource, CSSSource, AMPSource} {
+		if n := sources[src]; n > 0 {
+			srcs = append(srcs, fmt.Sprintf("%d %s", n, src))
+			delete(sources, src)
+		}
+	}
+	var nother int
+	for _, n := range sources {
+		nother += n
+	}
+	if nother > 0 {
+		srcs = append(srcs, fmt.Sprintf("%d other", nother))
+	}
+	if full := s + " (" + strings.Join(srcs, ", ") + ")"; len(full) <= MaxSummaryLen {
+		s = full
+	}
+	if len(s) > MaxSummaryLen {
+		s = s[:MaxSummaryLen]
+	}
+	return s
+}
+
+// plural returns n followed by noun, with an "s" appended to noun if n isn't 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
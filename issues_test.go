@@ -0,0 +1,142 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareIssues(t *testing.T) {
+	a := Issue{Line: 1, Col: 2, Message: "A", URL: "https://example.org/a"}
+	b := Issue{Line: 3, Col: 4, Message: "B"}
+	c := Issue{Severity: Warning, Line: 5, Message: "C"}
+
+	// The URL and column should be ignored, and order shouldn't matter.
+	got := []Issue{b, {Line: 1, Col: 7, Message: "A"}, c}
+	missing, extra := CompareIssues(got, []Issue{a, b, c}, "URL", "Col")
+	if len(missing) != 0 || len(extra) != 0 {
+		t.Errorf("CompareIssues reported missing %q and extra %q for matching issues", missing, extra)
+	}
+
+	// Without ignoring the URL and column, the first issue shouldn't match.
+	missing, extra = CompareIssues(got, []Issue{a, b, c, b})
+	if want := []Issue{a, b}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("CompareIssues reported missing %q; want %q", missing, want)
+	}
+	if want := []Issue{got[1]}; !reflect.DeepEqual(extra, want) {
+		t.Errorf("CompareIssues reported extra %q; want %q", extra, want)
+	}
+}
+
+func TestGroupByCode(t *testing.T) {
+	a1 := Issue{Line: 1, Message: "A1", Code: "a"}
+	b := Issue{Line: 2, Message: "B", Code: "b"}
+	n1 := Issue{Line: 3, Message: "No code"}
+	a2 := Issue{Severity: Warning, Line: 4, Message: "A2", Code: "a"}
+	n2 := Issue{Line: 5, Message: "Also no code"}
+	issues := []Issue{a1, b, n1, a2, n2}
+
+	if got, want := GroupByCode(issues), map[string][]Issue{
+		"a": {a1, a2},
+		"b": {b},
+		"":  {n1, n2},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByCode returned %v; want %v", got, want)
+	}
+	if got, want := CodeCounts(issues), map[string]int{"a": 2, "b": 1, "": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeCounts returned %v; want %v", got, want)
+	}
+	if got := GroupByCode(nil); len(got) != 0 {
+		t.Errorf("GroupByCode(nil) returned %v; want empty map", got)
+	}
+}
+
+func TestRelativizeResults(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(cwd, "site")
+	inside := filepath.Join(base, "a", "index.html")
+	outside := filepath.Join(cwd, "other", "page.html")
+	rel := filepath.Join("site", "b.html") // relative to cwd
+	is := Issue{Line: 1, Message: "Bad"}
+	withFile := func(p string) Issue {
+		c := is
+		c.File = p
+		return c
+	}
+
+	results := map[string][]Issue{
+		inside:  {withFile(inside)},
+		outside: {withFile(outside)},
+		rel:     {withFile(rel)},
+		base:    {is},
+	}
+	want := map[string][]Issue{
+		filepath.Join("a", "index.html"): {withFile(filepath.Join("a", "index.html"))},
+		outside:                          {withFile(outside)},
+		"b.html":                         {withFile("b.html")},
+		".":                              {is},
+	}
+	if got := RelativizeResults(results, base); !reflect.DeepEqual(got, want) {
+		t.Errorf("RelativizeResults returned %v; want %v", got, want)
+	}
+	// The original results shouldn't be modified.
+	if got := results[inside][0].File; got != inside {
+		t.Errorf("RelativizeResults changed original File to %q", got)
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	for _, tc := range []struct {
+		results map[string][]Issue
+		want    string
+	}{
+		{map[string][]Issue{
+			"index.html": {
+				{Severity: Error, Message: "Bad element", Source: HTMLSource},
+				{Severity: Error, Message: "Bad property", Source: CSSSource},
+				{Severity: Warning, Message: "Vendor extension", Source: CSSSource},
+			},
+			"style.css": {{Severity: Error, Message: "Bad property", Source: CSSSource}},
+			"empty.css": nil,
+		}, "3 errors, 1 warning in 2 files (1 HTML, 3 CSS)"},
+		{map[string][]Issue{
+			"amp.html": {
+				{Severity: Error, Message: "Bad", Source: AMPSource},
+				{Severity: Info, Message: "Mixed line endings", Code: "mixed-line-endings"},
+			},
+		}, "1 error, 1 info message in 1 file (1 AMP, 1 other)"},
+		{map[string][]Issue{"a.html": nil, "b.css": nil}, "No issues in 2 files"},
+	} {
+		if got := SummaryLine(tc.results); got != tc.want {
+			t.Errorf("SummaryLine(%v) = %q; want %q", tc.results, got, tc.want)
+		}
+	}
+}
+
+func TestIssue_Fingerprint(t *testing.T) {
+	is := Issue{Severity: Error, Line: 3, Col: 5, Message: "Bad element", Code: "bad", File: "index.html"}
+	moved := is
+	moved.Line, moved.Col, moved.Context = 10, 1, "<bogus>"
+	if got, want := moved.Fingerprint(), is.Fingerprint(); got != want {
+		t.Errorf("Fingerprint() for moved issue = %q; want %q", got, want)
+	}
+	for _, changed := range []Issue{
+		{Severity: Warning, Line: 3, Col: 5, Message: "Bad element", Code: "bad", File: "index.html"},
+		{Severity: Error, Line: 3, Col: 5, Message: "Other element", Code: "bad", File: "index.html"},
+		{Severity: Error, Line: 3, Col: 5, Message: "Bad element", Code: "bad", File: "other.html"},
+	} {
+		if changed.Fingerprint() == is.Fingerprint() {
+			t.Errorf("Fingerprint() for %+v matches %+v", changed, is)
+		}
+	}
+	if moved.FingerprintFields("Message", "Line") == is.FingerprintFields("Message", "Line") {
+		t.Error("FingerprintFields with Line matches for issues on different lines")
+	}
+}
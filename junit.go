@@ -0,0 +1,82 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteJUnit writes results (keyed by file path, e.g. as returned by AMPFiles) to w as
+// JUnit XML, which is displayed by CI systems like GitLab and Jenkins. Each file is reported
+// as a test case in lexical order, and each of its Error issues is reported as a failure.
+// Other issues are listed in the test case's system-out element.
+func WriteJUnit(w io.Writer, results map[string][]Issue) error {
+	type failureElem struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr,omitempty"`
+		Text    string `xml:",chardata"`
+	}
+	type testCaseElem struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Failures  []failureElem `xml:"failure"`
+		SystemOut string        `xml:"system-out,omitempty"`
+	}
+	type testSuiteElem struct {
+		Name      string         `xml:"name,attr"`
+		Tests     int            `xml:"tests,attr"`
+		Failures  int            `xml:"failures,attr"`
+		TestCases []testCaseElem `xml:"testcase"`
+	}
+	type testSuitesElem struct {
+		XMLName xml.Name        `xml:"testsuites"`
+		Suites  []testSuiteElem `xml:"testsuite"`
+	}
+
+	paths := make([]string, 0, len(results))
+	for p := range results {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	suite := testSuiteElem{Name: "validate", Tests: len(paths)}
+	for _, p := range paths {
+		tc := testCaseElem{Name: p, ClassName: "validate"}
+		var out []string
+		for _, is := range results[p] {
+			loc := fmt.Sprintf("%s:%d:%d", p, is.Line, is.Col)
+			if is.Severity == Error {
+				tc.Failures = append(tc.Failures, failureElem{
+					Message: is.Message,
+					Type:    is.Code,
+					Text:    loc + ": " + is.Message,
+				})
+			} else {
+				out = append(out, fmt.Sprintf("%s: %s: %s", loc, strings.ToLower(is.Severity.String()), is.Message))
+			}
+		}
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		}
+		if len(out) > 0 {
+			tc.SystemOut = strings.Join(out, "\n")
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(testSuitesElem{Suites: []testSuiteElem{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
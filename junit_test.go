@@ -0,0 +1,41 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	results := map[string][]Issue{
+		"style.css": {{Severity: Warning, Line: 3, Message: "Unknown vendor extension"}},
+		"index.html": {
+			{Severity: Error, Line: 1, Col: 5, Message: `Bad "value" & more`, Code: "bad-value"},
+			{Severity: Error, Line: 4, Col: 2, Message: "Stray end tag"},
+		},
+		"empty.html": nil,
+	}
+	const want = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="validate" tests="3" failures="1">
+    <testcase name="empty.html" classname="validate"></testcase>
+    <testcase name="index.html" classname="validate">
+      <failure message="Bad &#34;value&#34; &amp; more" type="bad-value">index.html:1:5: Bad &#34;value&#34; &amp; more</failure>
+      <failure message="Stray end tag">index.html:4:2: Stray end tag</failure>
+    </testcase>
+    <testcase name="style.css" classname="validate">
+      <system-out>style.css:3:0: warning: Unknown vendor extension</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	var b bytes.Buffer
+	if err := WriteJUnit(&b, results); err != nil {
+		t.Fatal("WriteJUnit failed: ", err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("WriteJUnit wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+// LintOptions configures the stylistic checks performed by Lint.
+// A nil *LintOptions is equivalent to the zero value, i.e. the defaults are used.
+type LintOptions struct {
+	// SkipTrailingWhitespace disables reporting of lines ending in spaces or tabs.
+	SkipTrailingWhitespace bool
+	// SkipFinalNewline disables reporting of non-empty documents that don't end in a newline.
+	SkipFinalNewline bool
+	// Indentation is "spaces" or "tabs" to report lines indented using the other character.
+	// If empty, indentation isn't checked.
+	Indentation string
+}
+
+// Lint reads an HTML or CSS document from r and reports stylistic issues that aren't covered
+// by validators, e.g. trailing whitespace. Issues have Info severity and codes starting with
+// "lint-" so they can be distinguished from validation results, and are returned in order of
+// increasing line number. opts may be nil.
+func Lint(r io.Reader, opts *LintOptions) ([]Issue, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &LintOptions{}
+	}
+
+	var issues []Issue
+	add := func(line, col int, code, msg string) {
+		issues = append(issues, Issue{Severity: Info, Line: line, Col: col, Message: msg, Code: code})
+	}
+	lines := strings.Split(string(b), "\n")
+	for i, ln := range lines {
+		ln = strings.TrimSuffix(ln, "\r")
+		if opts.Indentation != "" {
+			indent := ln[:len(ln)-len(strings.TrimLeft(ln, " \t"))]
+			if opts.Indentation == "spaces" && strings.Contains(indent, "\t") {
+				add(i+1, 1, "lint-indentation", "Line is indented with tabs")
+			} else if opts.Indentation == "tabs" && strings.Contains(indent, " ") {
+				add(i+1, 1, "lint-indentation", "Line is indented with spaces")
+			}
+		}
+		if !opts.SkipTrailingWhitespace {
+			if trimmed := strings.TrimRight(ln, " \t"); trimmed != ln {
+				add(i+1, utf8.RuneCountInString(trimmed)+1, "lint-trailing-whitespace", "Line has trailing whitespace")
+			}
+		}
+	}
+	if !opts.SkipFinalNewline && len(b) > 0 && b[len(b)-1] != '\n' {
+		last := strings.TrimSuffix(lines[len(lines)-1], "\r")
+		add(len(lines), utf8.RuneCountInString(last)+1, "lint-final-newline", "Document doesn't end in a newline")
+	}
+	normalizeColumns(issues)
+	return issues, nil
+}
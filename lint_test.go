@@ -0,0 +1,47 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lintIssues runs Lint on doc and returns "line:col code" strings for the issues.
+func lintIssues(t *testing.T, doc string, opts *LintOptions) []string {
+	issues, err := Lint(strings.NewReader(doc), opts)
+	if err != nil {
+		t.Fatalf("Lint(%q) failed: %v", doc, err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, fmt.Sprintf("%d:%d %s", is.Line, is.Col, is.Code))
+	}
+	return got
+}
+
+func TestLint(t *testing.T) {
+	const doc = "body {\r\n  color: red; \r\n\tmargin: 0;\t\n}"
+	for _, tc := range []struct {
+		opts *LintOptions
+		want []string
+	}{
+		{nil, []string{"2:14 lint-trailing-whitespace", "3:12 lint-trailing-whitespace", "4:2 lint-final-newline"}},
+		{&LintOptions{SkipTrailingWhitespace: true}, []string{"4:2 lint-final-newline"}},
+		{&LintOptions{SkipTrailingWhitespace: true, SkipFinalNewline: true}, nil},
+		{&LintOptions{SkipTrailingWhitespace: true, SkipFinalNewline: true, Indentation: "spaces"},
+			[]string{"3:1 lint-indentation"}},
+		{&LintOptions{SkipTrailingWhitespace: true, SkipFinalNewline: true, Indentation: "tabs"},
+			[]string{"2:1 lint-indentation"}},
+	} {
+		if got := lintIssues(t, doc, tc.opts); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Lint(%q, %+v) returned %q; want %q", doc, tc.opts, got, tc.want)
+		}
+	}
+	if got := lintIssues(t, "<p>OK</p>\n", nil); len(got) != 0 {
+		t.Errorf("Lint returned %q for clean document", got)
+	}
+}
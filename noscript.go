@@ -0,0 +1,97 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/net/html"
+)
+
+// noscriptContent describes the contents of a <noscript> element.
+type noscriptContent struct {
+	data   []byte // raw markup between the start and end tags
+	line   int    // 1-indexed line in the document where data starts
+	inHead bool   // true if the element is within the document's <head>
+}
+
+// extractNoscripts returns the contents of the <noscript> elements in the HTML document b.
+// The tokenizer treats <noscript> contents as raw text (as browsers do when scripting is
+// enabled), so the elements can't be nested.
+func extractNoscripts(b []byte) []noscriptContent {
+	var nss []noscriptContent
+	var cur *noscriptContent
+	inHead := true // cleared after </head>, <body>, or a body-only element is seen
+	z := html.NewTokenizer(bytes.NewReader(b))
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		name, _ := z.TagName()
+		switch {
+		case tt == html.StartTagToken && string(name) == "noscript":
+			cur = &noscriptContent{line: line + bytes.Count(raw, []byte{'\n'}), inHead: inHead}
+		case tt == html.EndTagToken && string(name) == "noscript" && cur != nil:
+			nss = append(nss, *cur)
+			cur = nil
+		case cur != nil:
+			cur.data = append(cur.data, raw...)
+		case tt == html.EndTagToken && string(name) == "head":
+			inHead = false
+		case tt == html.StartTagToken || tt == html.SelfClosingTagToken:
+			if _, ok := headElements[string(name)]; !ok {
+				inHead = false
+			}
+		}
+		line += bytes.Count(raw, []byte{'\n'})
+	}
+	return nss
+}
+
+// headElements contains the names of elements that don't imply the end of the document's <head>.
+var headElements = stringSet([]string{
+	"base", "html", "head", "link", "meta", "noscript", "script", "style", "template", "title",
+}, nil)
+
+// wrapNoscript returns a complete HTML document containing the <noscript> contents ns.data
+// within a <noscript> element in the document's <head> or <body> (matching ns.inHead).
+// The content starts on the document's first line.
+func wrapNoscript(ns noscriptContent) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<!DOCTYPE html><html lang="en"><head><title>noscript</title>`)
+	if !ns.inHead {
+		b.WriteString(`</head><body>`)
+	}
+	b.WriteString(`<noscript>`)
+	b.Write(ns.data)
+	b.WriteString(`</noscript>`)
+	if ns.inHead {
+		b.WriteString(`</head><body>`)
+	}
+	b.WriteString("</body></html>\n")
+	return b.Bytes()
+}
+
+// validateNoscripts validates the contents of each <noscript> element in the HTML document in
+// as a separate document and returns the issues with line numbers adjusted to refer to in.
+func validateNoscripts(ctx context.Context, in []byte, opts *Options) ([]Issue, error) {
+	var issues []Issue
+	for _, ns := range extractNoscripts(in) {
+		ni, _, err := validateHTML(ctx, wrapNoscript(ns), HTMLDoc, opts, opts.useJSON())
+		if err != nil {
+			return issues, err
+		}
+		for _, is := range ni {
+			if is.Line > 0 {
+				is.Line += ns.line - 1
+			}
+			issues = append(issues, is)
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLWithOptions_ValidateNoscript(t *testing.T) {
+	var docs []string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		doc := string(b)
+		docs = append(docs, doc)
+		// Pretend that the service only reports the malformed element in the separate document.
+		if strings.HasPrefix(doc, `<!DOCTYPE html><html lang="en"><head><title>noscript</title></head>`) &&
+			strings.Contains(doc, "<bogus>") {
+			io.WriteString(w, nuPage(nuError(2, 3, "Element bogus not allowed as child of element p")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+
+	const doc = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<title>Test</title>
+<noscript><style>body{opacity:1}</style></noscript>
+</head>
+<body>
+<noscript><p>
+  <bogus>Enable JavaScript</bogus>
+</p></noscript>
+</body>
+</html>
+`
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc),
+		&Options{ValidateNoscript: true})
+	if err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 || issues[0].Line != 9 {
+		t.Errorf("HTMLWithOptions returned %q; want one issue at line 9", issues)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("Service got %d document(s); want 3", len(docs))
+	}
+	if want := "<title>noscript</title><noscript><style>"; !strings.Contains(docs[1], want) {
+		t.Errorf("Head <noscript> was uploaded as %q; want it to contain %q", docs[1], want)
+	}
+
+	// <noscript> elements shouldn't be validated separately by default.
+	docs = nil
+	if _, _, err := HTML(context.Background(), strings.NewReader(doc)); err != nil {
+		t.Error("HTML failed: ", err)
+	} else if len(docs) != 1 {
+		t.Errorf("HTML uploaded %d document(s); want 1", len(docs))
+	}
+}
+
+func TestExtractNoscripts(t *testing.T) {
+	const doc = "<head><noscript><link rel=a></noscript></head>\n<p>\n<noscript\nid=a>x\n<b>y</b></noscript>"
+	got := extractNoscripts([]byte(doc))
+	want := []noscriptContent{
+		{data: []byte("<link rel=a>"), line: 1, inHead: true},
+		{data: []byte("x\n<b>y</b>"), line: 4, inHead: false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractNoscripts returned %+v; want %+v", got, want)
+	}
+}
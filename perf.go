@@ -0,0 +1,115 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultMaxInlineImageSize is the default maximum decoded size in bytes of base64-encoded
+// data: URI images before HTMLChecks reports them. See CheckOptions.MaxInlineImageSize.
+const DefaultMaxInlineImageSize = 4096
+
+// DefaultEagerImages is the default number of <img> elements at the start of the document's
+// body that are assumed to be above the fold. See CheckOptions.EagerImages.
+const DefaultEagerImages = 1
+
+// Documentation URLs for performance checks.
+const (
+	renderBlockingURL = "https://web.dev/render-blocking-resources/"
+	lazyLoadingURL    = "https://web.dev/browser-level-image-lazy-loading/"
+)
+
+// checkPerformance reports render-blocking scripts in the document's <head>, large inline
+// images, and images that are probably below the fold but aren't lazy-loaded. These are
+// advisory and are reported as warnings or informational issues.
+func checkPerformance(toks []lineToken, opts *CheckOptions) []Issue {
+	maxInline := opts.MaxInlineImageSize
+	if maxInline == 0 {
+		maxInline = DefaultMaxInlineImageSize
+	}
+	eager := opts.EagerImages
+	if eager == 0 {
+		eager = DefaultEagerImages
+	} else if eager < 0 {
+		eager = 0
+	}
+
+	var issues []Issue
+	add := func(sev Severity, line int, code, msg, u string) {
+		issues = append(issues, Issue{Severity: sev, Line: line, Message: msg, Code: code, URL: u})
+	}
+	inHead := true // cleared after </head>, <body>, or a body-only element is seen
+	nimgs := 0
+	for _, t := range toks {
+		if t.Type == html.EndTagToken && t.Data == "head" {
+			inHead = false
+		}
+		if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+			continue
+		}
+		if _, ok := headElements[t.Data]; !ok {
+			inHead = false
+		}
+		switch t.Data {
+		case "script":
+			if !inHead || opts.SkipRenderBlocking {
+				continue
+			}
+			src, ok := tokenAttr(&t.Token, "src")
+			if !ok || strings.TrimSpace(src) == "" {
+				continue
+			}
+			typ, _ := tokenAttr(&t.Token, "type")
+			if strings.EqualFold(strings.TrimSpace(typ), "module") {
+				continue // module scripts are deferred by default
+			}
+			_, async := tokenAttr(&t.Token, "async")
+			_, deferred := tokenAttr(&t.Token, "defer")
+			if !async && !deferred {
+				add(Warning, t.line, "render-blocking-script",
+					fmt.Sprintf("Script %q in head blocks rendering; add \"async\" or \"defer\"", src),
+					renderBlockingURL)
+			}
+		case "img":
+			src, _ := tokenAttr(&t.Token, "src")
+			if n := inlineImageSize(src); !opts.SkipInlineImages && n > maxInline {
+				add(Warning, t.line, "large-inline-image",
+					fmt.Sprintf("Inline image is %d bytes; serve images larger than %d bytes separately",
+						n, maxInline), "")
+			}
+			nimgs++
+			if opts.SkipLazyLoading || nimgs <= eager {
+				continue
+			}
+			if _, ok := tokenAttr(&t.Token, "loading"); !ok {
+				add(Info, t.line, "missing-lazy-loading",
+					`Image may be below the fold; consider adding loading="lazy"`, lazyLoadingURL)
+			}
+		}
+	}
+	return issues
+}
+
+// inlineImageSize returns the approximate decoded size in bytes of the base64-encoded
+// image in the data: URI src, or 0 if src isn't such a URI.
+func inlineImageSize(src string) int {
+	src = strings.TrimSpace(src)
+	if len(src) < 5 || !strings.EqualFold(src[:5], "data:") {
+		return 0
+	}
+	i := strings.IndexByte(src, ',')
+	if i < 0 {
+		return 0
+	}
+	meta := strings.ToLower(src[5:i])
+	if !strings.HasPrefix(meta, "image/") || !strings.HasSuffix(meta, ";base64") {
+		return 0
+	}
+	data := strings.TrimRight(src[i+1:], "=")
+	return len(data) * 3 / 4
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLChecks_Performance(t *testing.T) {
+	doc := `<!DOCTYPE html>
+<html>
+  <head>
+    <script src="blocking.js"></script>
+    <script src="async.js" async></script>
+    <script src="defer.js" defer></script>
+    <script src="module.js" type="module"></script>
+    <script>inline()</script>
+  </head>
+  <body>
+    <script src="body.js"></script>
+    <img src="hero.jpg" alt="">
+    <img src="below.jpg" alt="">
+    <img src="lazy.jpg" alt="" loading="lazy">
+    <img src="data:image/png;base64,` + strings.Repeat("A", 8000) + `" alt="" loading="eager">
+  </body>
+</html>
+`
+	want := []string{"4 render-blocking-script", "13 missing-lazy-loading", "15 large-inline-image"}
+	if got := checkIssues(t, doc, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+
+	opts := &CheckOptions{SkipRenderBlocking: true, MaxInlineImageSize: 10000, EagerImages: -1}
+	want = []string{"12 missing-lazy-loading", "13 missing-lazy-loading"}
+	if got := checkIssues(t, doc, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks with custom options returned %q; want %q", got, want)
+	}
+
+	opts = &CheckOptions{SkipRenderBlocking: true, SkipInlineImages: true, SkipLazyLoading: true}
+	if got := checkIssues(t, doc, opts); len(got) != 0 {
+		t.Errorf("HTMLChecks with all performance checks skipped returned %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter enforces a minimum interval between requests to online validation services.
+// It can be shared by multiple goroutines.
+type RateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time // earliest time at which the next request may be made
+}
+
+// NewRateLimiter returns a RateLimiter that separates requests by at least interval.
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{interval: interval}
+}
+
+// Wait blocks until the next request is permitted or ctx is done.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	rl.mu.Lock()
+	now := time.Now()
+	t := rl.next
+	if t.Before(now) {
+		t = now
+	}
+	rl.next = t.Add(rl.interval)
+	rl.mu.Unlock()
+
+	if d := t.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// requestLimiter returns a RateLimiter that enforces o.RequestInterval.
+func (o *Options) requestLimiter() *RateLimiter {
+	var interval time.Duration
+	if o != nil {
+		interval = o.RequestInterval
+	}
+	return NewRateLimiter(interval)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Region identifies part of an HTML document. See Options.Region and CheckOptions.Region.
+type Region string
+
+const (
+	// WholeDocument is the entire document.
+	WholeDocument Region = ""
+	// HeadRegion extends from the start of the document to the end of its <head> element.
+	HeadRegion Region = "head"
+	// BodyRegion extends from the end of the document's <head> element to the end of the
+	// document.
+	BodyRegion Region = "body"
+)
+
+// headBounds returns the 1-indexed lines on which the <head> element in toks ends and
+// on which the document's body starts. They're the same line if the head is explicitly
+// closed by </head>. If the head is implicitly closed by an element that can't appear
+// in it (or by text), the body starts on that line and the head ends on the line of the
+// preceding tag. If the head isn't closed, the document's last line is returned for both.
+func headBounds(toks []lineToken) (headEnd, bodyStart int) {
+	last := 1         // end line of last tag in head
+	var inText string // name of open head element containing text, e.g. "title"
+	for _, t := range toks {
+		switch t.Type {
+		case html.EndTagToken:
+			if t.Data == "head" {
+				return t.line, t.line
+			}
+			if t.Data == inText {
+				inText = ""
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if _, ok := headElements[t.Data]; !ok {
+				return last, t.line
+			}
+			switch t.Data {
+			case "noscript", "script", "style", "template", "title":
+				if t.Type == html.StartTagToken {
+					inText = t.Data
+				}
+			}
+		case html.TextToken:
+			if inText == "" && strings.TrimSpace(t.Data) != "" {
+				return last, t.line
+			}
+			continue
+		default:
+			continue
+		}
+		last = t.endLine
+	}
+	return last, last
+}
+
+// filterRegion returns the issues within region of the HTML document tokenized as toks.
+// Issues with unknown lines are always returned.
+func filterRegion(issues []Issue, toks []lineToken, region Region) []Issue {
+	if region == WholeDocument {
+		return issues
+	}
+	headEnd, bodyStart := headBounds(toks)
+	var filtered []Issue
+	for _, is := range issues {
+		if is.Line <= 0 || (region == HeadRegion && is.Line <= headEnd) ||
+			(region == BodyRegion && is.Line >= bodyStart) {
+			filtered = append(filtered, is)
+		}
+	}
+	return filtered
+}
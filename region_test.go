@@ -0,0 +1,93 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLWithOptions_Region(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<title>Test</title>
+<meta name="bogus" contnt="x">
+</head>
+<body>
+<p><bogus>Hi</bogus></p>
+</body>
+</html>
+`
+	var uploaded string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		uploaded = string(b)
+		io.WriteString(w, nuPage(
+			nuError(5, 30, "Attribute contnt not allowed on element meta"),
+			nuError(8, 9, "Element bogus not allowed as child of element p"),
+		))
+	})
+
+	for _, tc := range []struct {
+		region Region
+		want   []int // lines
+	}{
+		{WholeDocument, []int{5, 8}},
+		{HeadRegion, []int{5}},
+		{BodyRegion, []int{8}},
+	} {
+		uploaded = ""
+		issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc),
+			&Options{Region: tc.region})
+		if err != nil {
+			t.Errorf("HTMLWithOptions with region %q failed: %v", tc.region, err)
+			continue
+		}
+		var got []int
+		for _, is := range issues {
+			got = append(got, is.Line)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("HTMLWithOptions with region %q returned issues on lines %v; want %v",
+				tc.region, got, tc.want)
+		}
+		if uploaded != doc {
+			t.Errorf("HTMLWithOptions with region %q uploaded %q; want full document", tc.region, uploaded)
+		}
+	}
+}
+
+func TestHTMLChecks_Region(t *testing.T) {
+	// The <head> element is implicitly closed by <center>.
+	const doc = `<!DOCTYPE html>
+<html>
+<meta charset="utf-8"><meta charset="utf-8">
+<center>Old-school</center>
+</html>
+`
+	for _, tc := range []struct {
+		region Region
+		want   []string
+	}{
+		{WholeDocument, []string{"3 duplicate-charset", "4 deprecated-element"}},
+		{HeadRegion, []string{"3 duplicate-charset"}},
+		{BodyRegion, []string{"4 deprecated-element"}},
+	} {
+		if got := checkIssues(t, doc, &CheckOptions{Region: tc.region}); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("HTMLChecks with region %q returned %q; want %q", tc.region, got, tc.want)
+		}
+	}
+}
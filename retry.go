@@ -0,0 +1,103 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRetryDelay is the default value of Options.MaxRetryDelay.
+const DefaultMaxRetryDelay = time.Minute
+
+// retryBaseDelay is the delay before the first retry of a request whose response
+// didn't include a Retry-After header. It's doubled for each subsequent retry.
+const retryBaseDelay = time.Second
+
+// retryableStatus returns true if a validation service's response with the supplied
+// HTTP status code indicates that the request should be retried.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxRetries returns o.MaxRetries or 0.
+func (o *Options) maxRetries() int {
+	if o == nil || o.MaxRetries < 0 {
+		return 0
+	}
+	return o.MaxRetries
+}
+
+// retryDelay returns how long to wait before retrying a request after the attempt'th
+// (0-indexed) attempt received a response with the supplied Retry-After header value.
+// If the header is empty or invalid, exponential backoff with jitter is used.
+// The delay is capped by o.MaxRetryDelay.
+func (o *Options) retryDelay(attempt int, retryAfter string, now time.Time) time.Duration {
+	max := DefaultMaxRetryDelay
+	if o != nil && o.MaxRetryDelay > 0 {
+		max = o.MaxRetryDelay
+	}
+	d, ok := parseRetryAfter(retryAfter, now)
+	if !ok {
+		d = retryBaseDelay << uint(attempt)
+		if d <= 0 || d > max { // check for overflow
+			d = max
+		}
+		// Wait for a random duration between d/2 and d so clients don't retry in lockstep.
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// parseRetryAfter parses v, the value of a Retry-After header containing either a number of
+// seconds or an HTTP date, and returns the corresponding delay after now.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// sleepContext waits for d or until ctx is done. If ctx's deadline would be reached before
+// d elapses, it returns context.DeadlineExceeded immediately rather than waiting.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) < d {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLWithOptions_Retry(t *testing.T) {
+	var reqs int
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		reqs++
+		if reqs == 1 {
+			w.Header().Set("Retry-After", "120")
+			http.Error(w, "Overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, nuPage(nuError(1, 2, "Bad element")))
+	})
+
+	// The Retry-After delay should be capped by MaxRetryDelay.
+	opts := &Options{MaxRetries: 2, MaxRetryDelay: 10 * time.Millisecond}
+	start := time.Now()
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"), opts)
+	if err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 {
+		t.Errorf("HTMLWithOptions returned %q; want 1 issue", issues)
+	}
+	if reqs != 2 {
+		t.Errorf("Service got %d request(s); want 2", reqs)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("HTMLWithOptions took %v", elapsed)
+	}
+
+	// Without retries, the error page is returned.
+	reqs = 0
+	if _, _, err := HTML(context.Background(), strings.NewReader("<!DOCTYPE html>")); err == nil {
+		t.Error("HTML unexpectedly succeeded without retries")
+	} else if reqs != 1 {
+		t.Errorf("Service got %d request(s) without retries; want 1", reqs)
+	}
+
+	// Don't wait past the context's deadline.
+	reqs = 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	opts = &Options{MaxRetries: 2, MaxRetryDelay: time.Hour}
+	if _, _, err := HTMLWithOptions(ctx, strings.NewReader("<!DOCTYPE html>"), opts); !errors.Is(
+		err, context.DeadlineExceeded) {
+		t.Errorf("HTMLWithOptions with short deadline returned %v; want %v", err, context.DeadlineExceeded)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("HTMLWithOptions waited past deadline")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		val  string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{" 0 ", 0, true},
+		{"-5", 0, false},
+		{"Fri, 01 May 2020 12:01:30 GMT", 90 * time.Second, true},
+		{"Fri, 01 May 2020 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	} {
+		if got, ok := parseRetryAfter(tc.val, now); got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.val, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestOptions_RetryDelay(t *testing.T) {
+	opts := &Options{MaxRetryDelay: 3 * time.Second}
+	now := time.Now()
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if d := opts.retryDelay(attempt, "", now); d < max/2 || d > max {
+			t.Errorf("retryDelay(%d) = %v; want between %v and %v", attempt, d, max/2, max)
+		}
+	}
+	if d := opts.retryDelay(0, "60", now); d != 3*time.Second {
+		t.Errorf("retryDelay with Retry-After = %v; want %v", d, 3*time.Second)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+// ruleURLs maps from issue codes reported by this package's local checks (and collapsed
+// AMP issues) to documentation URLs. AMP codes are handled separately by RuleURLs.
+var ruleURLs = map[string]string{
+	ampBoilerplateCode:          ampBoilerplateURL,
+	"conflicting-charset":       "https://html.spec.whatwg.org/multipage/semantics.html#character-encoding-declaration",
+	"csp-directive-in-meta":     cspURL,
+	"deprecated-attribute":      "https://html.spec.whatwg.org/multipage/obsolete.html#non-conforming-features",
+	"deprecated-element":        "https://html.spec.whatwg.org/multipage/obsolete.html#non-conforming-features",
+	"duplicate-charset":         "https://html.spec.whatwg.org/multipage/semantics.html#character-encoding-declaration",
+	"duplicate-viewport-key":    viewportURL,
+	"email-external-stylesheet": "https://www.caniemail.com/features/html-link/",
+	"email-script":              "https://www.caniemail.com/features/html-script/",
+	"email-unsupported-css":     "https://www.caniemail.com/",
+	"font-missing-crossorigin":  "https://developer.mozilla.org/en-US/docs/Web/HTML/Attributes/rel/preload#cors-enabled_fetches",
+	"invalid-aria-value":        "https://www.w3.org/TR/wai-aria-1.2/#state_prop_def",
+	"invalid-csp-source":        cspURL,
+	"invalid-social-value":      openGraphURL,
+	"invalid-url":               "https://url.spec.whatwg.org/#url-parsing",
+	"invalid-viewport-value":    viewportURL,
+	"late-charset":              "https://html.spec.whatwg.org/multipage/semantics.html#character-encoding-declaration",
+	"missing-social-property":   openGraphURL,
+	"preload-invalid-as":        "https://developer.mozilla.org/en-US/docs/Web/HTML/Attributes/rel/preload#what_types_of_content_can_be_preloaded",
+	"preload-missing-as":        "https://developer.mozilla.org/en-US/docs/Web/HTML/Attributes/rel/preload",
+	"unknown-aria-attribute":    "https://www.w3.org/TR/wai-aria-1.2/#state_prop_def",
+	"unknown-csp-directive":     cspURL,
+	"unknown-link-rel":          "https://html.spec.whatwg.org/multipage/links.html#linkTypes",
+	"unknown-role":              "https://www.w3.org/TR/wai-aria-1.2/#role_definitions",
+	"unknown-viewport-key":      viewportURL,
+}
+
+// RuleURLs returns a map from each non-empty Issue.Code in results (e.g. as returned by
+// AMPFiles) to a URL documenting it, suitable for including rule metadata in reports.
+// Issue.URL is used if it is set for any issue with the code; otherwise a built-in table
+// covering this package's local checks and common amphtml-validator codes is consulted.
+// Codes without known documentation are mapped to empty strings.
+func RuleURLs(results map[string][]Issue) map[string]string {
+	urls := make(map[string]string)
+	for _, issues := range results {
+		for _, is := range issues {
+			if is.Code == "" {
+				continue
+			}
+			if is.URL != "" {
+				urls[is.Code] = is.URL
+			} else if _, ok := urls[is.Code]; !ok {
+				urls[is.Code] = ""
+			}
+		}
+	}
+	for code, u := range urls {
+		if u != "" {
+			continue
+		}
+		if u, ok := ruleURLs[code]; ok {
+			urls[code] = u
+		} else if _, ok := ExplainAMPCode(code); ok {
+			urls[code] = ampErrorsURL
+		}
+	}
+	return urls
+}
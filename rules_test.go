@@ -0,0 +1,33 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleURLs(t *testing.T) {
+	results := map[string][]Issue{
+		"a.html": {
+			{Code: "deprecated-element"},
+			{Code: "DISALLOWED_TAG", URL: "https://amp.dev/documentation/components/amp-img"},
+			{Message: "No code"},
+		},
+		"b.html": {
+			{Code: "DISALLOWED_TAG"},
+			{Code: "MANDATORY_ATTR_MISSING"},
+			{Code: "made-up-code"},
+		},
+	}
+	want := map[string]string{
+		"deprecated-element":     ruleURLs["deprecated-element"],
+		"DISALLOWED_TAG":         "https://amp.dev/documentation/components/amp-img",
+		"MANDATORY_ATTR_MISSING": ampErrorsURL,
+		"made-up-code":           "",
+	}
+	if got := RuleURLs(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("RuleURLs returned %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// robotsFields contains lowercase field names recognized in robots.txt files.
+var robotsFields = map[string]struct{}{
+	"user-agent":  {},
+	"allow":       {},
+	"disallow":    {},
+	"sitemap":     {},
+	"crawl-delay": {},
+	"host":        {},
+}
+
+// Robots reads a robots.txt file from r and checks it locally for problems as described in
+// https://www.rfc-editor.org/rfc/rfc9309.html. Malformed lines and rules that don't follow a
+// user-agent line are reported as errors, while unrecognized fields are reported as warnings.
+func Robots(r io.Reader) ([]Issue, error) {
+	var issues []Issue
+	add := func(sev Severity, line int, msg string) {
+		issues = append(issues, Issue{Severity: sev, Line: line, Message: msg})
+	}
+	sc := bufio.NewScanner(r)
+	sawAgent := false
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		i := strings.IndexByte(text, ':')
+		if i < 0 {
+			add(Error, line, fmt.Sprintf("Line %q isn't of the form \"field: value\"", text))
+			continue
+		}
+		field := strings.ToLower(strings.TrimSpace(text[:i]))
+		val := strings.TrimSpace(text[i+1:])
+		if _, ok := robotsFields[field]; !ok {
+			add(Warning, line, fmt.Sprintf("Unknown field %q", field))
+			continue
+		}
+		switch field {
+		case "user-agent":
+			sawAgent = true
+		case "allow", "disallow":
+			if !sawAgent {
+				add(Error, line, fmt.Sprintf("%q rule not preceded by user-agent line", field))
+			}
+		case "sitemap":
+			if u, err := url.Parse(val); err != nil || !u.IsAbs() {
+				add(Error, line, fmt.Sprintf("Sitemap URL %q isn't absolute", val))
+			}
+		}
+	}
+	return issues, sc.Err()
+}
+
+// sitemapNS is the XML namespace used by sitemaps.
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Sitemap reads a sitemap.xml file from r and checks it locally for problems as described in
+// https://www.sitemaps.org/protocol.html. Both <urlset> sitemaps and <sitemapindex> files
+// are supported.
+func Sitemap(r io.Reader) ([]Issue, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	lineAt := func(off int64) int { return bytes.Count(b[:off], []byte{'\n'}) + 1 }
+	add := func(off int64, msg string) {
+		issues = append(issues, Issue{Severity: Error, Line: lineAt(off), Message: msg})
+	}
+
+	var stack []string // names of open elements
+	var text strings.Builder
+	var sawRoot, sawLoc bool
+	for {
+		off := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			is := Issue{Severity: Error, Line: lineAt(dec.InputOffset()), Message: err.Error()}
+			if serr, ok := err.(*xml.SyntaxError); ok {
+				is.Line = serr.Line
+				is.Message = serr.Msg
+			}
+			return append(issues, is), nil
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if len(stack) == 0 {
+				sawRoot = true
+				if name != "urlset" && name != "sitemapindex" {
+					add(off, fmt.Sprintf("Root element is <%s>; want <urlset> or <sitemapindex>", name))
+				} else if t.Name.Space != sitemapNS {
+					add(off, fmt.Sprintf("Root element has namespace %q; want %q", t.Name.Space, sitemapNS))
+				}
+			}
+			if name == "url" || name == "sitemap" {
+				sawLoc = false
+			}
+			stack = append(stack, name)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			name := t.Name.Local
+			stack = stack[:len(stack)-1]
+			switch name {
+			case "loc":
+				sawLoc = true
+				loc := strings.TrimSpace(text.String())
+				if u, err := url.Parse(loc); err != nil || !u.IsAbs() {
+					add(off, fmt.Sprintf("<loc> URL %q isn't absolute", loc))
+				}
+			case "url", "sitemap":
+				if !sawLoc {
+					add(off, fmt.Sprintf("<%s> element missing <loc>", name))
+				}
+			}
+		}
+	}
+	if !sawRoot {
+		issues = append(issues, Issue{Severity: Error, Line: 1, Message: "Missing root element"})
+	}
+	return issues, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRobots(t *testing.T) {
+	issues, err := Robots(strings.NewReader(`# Comment
+Disallow: /early
+User-agent: *
+Disallow: /private # trailing comment
+Bogus: value
+not a rule
+Sitemap: /sitemap.xml
+`))
+	if err != nil {
+		t.Fatal("Robots failed: ", err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, is.String())
+	}
+	want := []string{
+		`2:0 Error: "disallow" rule not preceded by user-agent line`,
+		`5:0 Warning: Unknown field "bogus"`,
+		`6:0 Error: Line "not a rule" isn't of the form "field: value"`,
+		`7:0 Error: Sitemap URL "/sitemap.xml" isn't absolute`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Robots returned %q; want %q", got, want)
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	for _, tc := range []struct {
+		doc  string
+		want []string
+	}{
+		{`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.org/</loc></url>
+</urlset>
+`, nil},
+		{`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>/relative</loc></url>
+  <url><lastmod>2020-01-01</lastmod></url>
+</urlset>
+`, []string{
+			`3:0 Error: <loc> URL "/relative" isn't absolute`,
+			`4:0 Error: <url> element missing <loc>`,
+		}},
+		{`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.org/</url>
+</urlset>
+`, []string{
+			`3:0 Error: element <loc> closed by </url>`,
+		}},
+	} {
+		issues, err := Sitemap(strings.NewReader(tc.doc))
+		if err != nil {
+			t.Errorf("Sitemap(%q) failed: %v", tc.doc, err)
+			continue
+		}
+		var got []string
+		for _, is := range issues {
+			got = append(got, is.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Sitemap(%q) returned %q; want %q", tc.doc, got, tc.want)
+		}
+	}
+}
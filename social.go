@@ -0,0 +1,133 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultRequiredOpenGraph lists the Open Graph properties reported as missing by HTMLChecks
+// if CheckOptions.RequiredOpenGraph is nil. See https://ogp.me/#metadata.
+var DefaultRequiredOpenGraph = []string{"og:title", "og:type", "og:image", "og:url"}
+
+// Documentation URLs for social metadata.
+const (
+	openGraphURL   = "https://ogp.me/"
+	twitterCardURL = "https://developer.twitter.com/en/docs/twitter-for-websites/cards/overview/markup"
+)
+
+// ogTypes contains the global Open Graph object types. See https://ogp.me/#types.
+var ogTypes = stringSet([]string{
+	"article", "book", "music.album", "music.playlist", "music.radio_station", "music.song",
+	"profile", "video.episode", "video.movie", "video.other", "video.tv_show", "website",
+}, nil)
+
+// twitterCards contains valid twitter:card values.
+var twitterCards = stringSet([]string{"app", "player", "summary", "summary_large_image"}, nil)
+
+// socialURLProps contains Open Graph and Twitter Card properties whose values must be
+// absolute HTTP(S) URLs.
+var socialURLProps = stringSet([]string{
+	"og:audio", "og:audio:secure_url", "og:image", "og:image:secure_url", "og:image:url",
+	"og:url", "og:video", "og:video:secure_url", "twitter:image", "twitter:player",
+}, nil)
+
+// socialIntProps contains Open Graph and Twitter Card properties whose values must be
+// positive integers.
+var socialIntProps = stringSet([]string{
+	"og:image:height", "og:image:width", "og:video:height", "og:video:width",
+	"twitter:player:height", "twitter:player:width",
+}, nil)
+
+// checkSocial reports invalid Open Graph and Twitter Card <meta> elements. If the document
+// contains any Open Graph properties, missing required properties are also reported.
+func checkSocial(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipSocial {
+		return nil
+	}
+	var issues []Issue
+	add := func(sev Severity, line int, code, msg, u string) {
+		issues = append(issues, Issue{Severity: sev, Line: line, Message: msg, Code: code, URL: u})
+	}
+	seen := make(map[string]struct{})
+	ogLine := 0 // line of first Open Graph property
+	var twitterCard string
+	twitterLine := 0
+	for _, t := range toks {
+		if (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) || t.Data != "meta" {
+			continue
+		}
+		prop, ok := tokenAttr(&t.Token, "property")
+		if !ok {
+			prop, _ = tokenAttr(&t.Token, "name")
+		}
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		isOG, isTwitter := strings.HasPrefix(prop, "og:"), strings.HasPrefix(prop, "twitter:")
+		if !isOG && !isTwitter {
+			continue
+		}
+		docURL := openGraphURL
+		if isTwitter {
+			docURL = twitterCardURL
+		}
+		if isOG && ogLine == 0 {
+			ogLine = t.line
+		}
+		seen[prop] = struct{}{}
+		val, _ := tokenAttr(&t.Token, "content")
+		val = strings.TrimSpace(val)
+
+		_, isURL := socialURLProps[prop]
+		_, isInt := socialIntProps[prop]
+		switch {
+		case val == "":
+			add(Error, t.line, "invalid-social-value", fmt.Sprintf("Property %q has empty content", prop), docURL)
+		case isURL:
+			if u, err := url.Parse(val); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add(Error, t.line, "invalid-social-value",
+					fmt.Sprintf("Property %q has non-absolute URL %q", prop, val), docURL)
+			}
+		case isInt:
+			if n, err := strconv.Atoi(val); err != nil || n <= 0 {
+				add(Error, t.line, "invalid-social-value",
+					fmt.Sprintf("Property %q has invalid size %q", prop, val), docURL)
+			}
+		case prop == "og:type":
+			if _, ok := ogTypes[strings.ToLower(val)]; !ok {
+				add(Warning, t.line, "invalid-social-value", fmt.Sprintf("Unknown Open Graph type %q", val), docURL)
+			}
+		case prop == "twitter:card":
+			twitterCard, twitterLine = strings.ToLower(val), t.line
+			if _, ok := twitterCards[twitterCard]; !ok {
+				add(Error, t.line, "invalid-social-value", fmt.Sprintf("Invalid Twitter card type %q", val), docURL)
+			}
+		}
+	}
+
+	if ogLine > 0 {
+		req := opts.RequiredOpenGraph
+		if req == nil {
+			req = DefaultRequiredOpenGraph
+		}
+		for _, p := range req {
+			p = strings.ToLower(p)
+			if _, ok := seen[p]; !ok {
+				add(Warning, ogLine, "missing-social-property",
+					fmt.Sprintf("Missing required Open Graph property %q", p), openGraphURL)
+			}
+		}
+	}
+	if twitterCard == "player" {
+		if _, ok := seen["twitter:player"]; !ok {
+			add(Warning, twitterLine, "missing-social-property",
+				`Missing "twitter:player" property required by player card`, twitterCardURL)
+		}
+	}
+	return issues
+}
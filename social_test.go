@@ -0,0 +1,51 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLChecks_Social(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta property="og:title" content="A page">
+    <meta property="og:type" content="website">
+    <meta property="og:url" content="https://example.org/page.html">
+    <meta property="og:image:width" content="wide">
+    <meta name="twitter:card" content="summary_huge_image">
+    <meta name="twitter:image" content="/img.png">
+  </head>
+</html>
+`
+	want := []string{
+		"4 missing-social-property",
+		"7 invalid-social-value",
+		"8 invalid-social-value",
+		"9 invalid-social-value",
+	}
+	if got := checkIssues(t, doc, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+	issues, err := HTMLChecks(strings.NewReader(doc), nil)
+	if err != nil {
+		t.Fatal("HTMLChecks failed: ", err)
+	}
+	if got, want := issues[0].Message, `Missing required Open Graph property "og:image"`; got != want {
+		t.Errorf("HTMLChecks returned message %q; want %q", got, want)
+	}
+
+	// The required properties are configurable.
+	opts := &CheckOptions{RequiredOpenGraph: []string{"og:title"}}
+	want = []string{"7 invalid-social-value", "8 invalid-social-value", "9 invalid-social-value"}
+	if got := checkIssues(t, doc, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks with RequiredOpenGraph returned %q; want %q", got, want)
+	}
+	if got := checkIssues(t, doc, &CheckOptions{SkipSocial: true}); len(got) != 0 {
+		t.Errorf("HTMLChecks with SkipSocial returned %q", got)
+	}
+}
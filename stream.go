@@ -0,0 +1,130 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// issueMatcher returns the severity of the issue described by the element starting with t
+// (e.g. <li class="error">) and true, or false if t doesn't start an issue.
+type issueMatcher func(t *html.Token) (Severity, bool)
+
+// htmlIssueMatcher returns an issueMatcher for results pages returned by
+// https://validator.w3.org/nu/. See extractHTMLIssues.
+func htmlIssueMatcher(info bool) issueMatcher {
+	return func(t *html.Token) (Severity, bool) {
+		if t.Data != "li" {
+			return 0, false
+		}
+		class, _ := tokenAttr(t, "class")
+		return nuIssueSeverity(class, info)
+	}
+}
+
+// cssIssueMatcher is an issueMatcher for results pages returned by
+// https://jigsaw.w3.org/css-validator/. See extractCSSIssues.
+func cssIssueMatcher(t *html.Token) (Severity, bool) {
+	if t.Data != "tr" {
+		return 0, false
+	}
+	switch class, _ := tokenAttr(t, "class"); class {
+	case "error":
+		return Error, true
+	case "warning":
+		return Warning, true
+	}
+	return 0, false
+}
+
+// streamIssues incrementally tokenizes the results page in r without buffering it.
+// Each element identified by match is parsed as a fragment within an element of type
+// parent and passed to makeIssue, and the resulting issue is passed to fn (if non-nil)
+// as soon as the element's end tag is read. All issues are also returned, along with
+// a bool reporting whether the page contained success.
+func streamIssues(r io.Reader, success string, match issueMatcher, parent atom.Atom,
+	makeIssue func(*html.Node, Severity) Issue, fn func(Issue)) ([]Issue, bool, error) {
+	var issues []Issue
+	var found bool
+	var raw []byte // raw data of the current issue's element
+	var tag string // name of the current issue's element
+	var sev Severity
+	depth := 0 // nesting depth of tag within the current issue's element
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return issues, found, err
+			}
+			break
+		}
+		// Examine the raw data before calling Token, since it's only valid until then.
+		tokRaw := z.Raw()
+		if !found && bytes.Contains(tokRaw, []byte(success)) {
+			found = true
+		}
+		if depth > 0 {
+			raw = append(raw, tokRaw...)
+		} else if tt == html.StartTagToken {
+			raw = append(raw[:0], tokRaw...)
+		}
+		if tt != html.StartTagToken && tt != html.EndTagToken {
+			continue
+		}
+		t := z.Token()
+		if depth == 0 {
+			if s, ok := match(&t); ok && tt == html.StartTagToken {
+				tag, sev, depth = t.Data, s, 1
+			}
+			continue
+		}
+		if t.Data != tag {
+			continue
+		}
+		if tt == html.StartTagToken {
+			depth++
+			continue
+		}
+		if depth--; depth > 0 {
+			continue
+		}
+		nodes, err := html.ParseFragment(bytes.NewReader(raw),
+			&html.Node{Type: html.ElementNode, Data: parent.String(), DataAtom: parent})
+		if err != nil {
+			return issues, found, fmt.Errorf("failed to parse response: %v", err)
+		}
+		for _, n := range nodes {
+			if n.Type == html.ElementNode && n.Data == tag {
+				is := makeIssue(n, sev)
+				issues = append(issues, is)
+				if fn != nil {
+					fn(is)
+				}
+				break
+			}
+		}
+	}
+	return issues, found, nil
+}
+
+// maxSizeReader wraps an io.Reader and returns an error after more than max bytes are read.
+type maxSizeReader struct {
+	r   io.Reader
+	n   int64 // bytes read so far
+	max int64
+}
+
+func (mr *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := mr.r.Read(p)
+	if mr.n += int64(n); mr.n > mr.max {
+		return n, fmt.Errorf("response exceeded max size of %d bytes", mr.max)
+	}
+	return n, err
+}
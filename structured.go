@@ -0,0 +1,86 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// jsonLDType is the type attribute of <script> elements containing JSON-LD structured data.
+const jsonLDType = "application/ld+json"
+
+// StructuredData reads an HTML document from r and checks that each of its
+// <script type="application/ld+json"> elements contains syntactically valid JSON.
+// Each syntax error is reported as an Error issue with Code "invalid-json-ld" at the
+// location of the problem within the document. The structured data isn't checked
+// against schema.org vocabularies.
+func StructuredData(ctx context.Context, r io.Reader) ([]Issue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var issues []Issue
+	inScript := false
+	for _, t := range tokenizeLines(b) {
+		switch t.Type {
+		case html.StartTagToken:
+			typ, _ := tokenAttr(&t.Token, "type")
+			inScript = t.Data == "script" && strings.EqualFold(strings.TrimSpace(typ), jsonLDType)
+		case html.EndTagToken:
+			inScript = false
+		case html.TextToken:
+			if inScript {
+				if is, ok := checkJSONLD(b, t); ok {
+					issues = append(issues, is)
+				}
+			}
+		}
+	}
+	setSource(issues, HTMLSource)
+	normalizeColumns(issues)
+	return issues, nil
+}
+
+// checkJSONLD parses the contents of a JSON-LD <script> element in the text token t
+// from doc and returns an issue describing the first syntax error, if any.
+func checkJSONLD(doc []byte, t lineToken) (Issue, bool) {
+	data := []byte(t.Data)
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	if err == nil {
+		return Issue{}, false
+	}
+	is := Issue{
+		Severity: Error,
+		Line:     t.line,
+		Message:  fmt.Sprintf("Invalid JSON-LD: %v", err),
+		Code:     "invalid-json-ld",
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		is.Message = "Empty JSON-LD script"
+	} else if serr, ok := err.(*json.SyntaxError); ok && len(data) == t.end-t.offset {
+		// Offset is the number of bytes read before the error, so point at the last one.
+		off := int(serr.Offset) - 1
+		if off >= len(data) {
+			off = len(data) - 1
+		} else if off < 0 {
+			off = 0
+		}
+		before := doc[:t.offset+off]
+		is.Line = bytes.Count(before, []byte{'\n'}) + 1
+		is.Col = len(before) - bytes.LastIndexByte(before, '\n')
+	}
+	return is, true
+}
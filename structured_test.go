@@ -0,0 +1,50 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStructuredData(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+<head>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Article"}
+</script>
+<script type="application/ld+json">
+{
+  "@context": "https://schema.org",
+  "headline": "Oops",,
+}
+</script>
+<script>var notJSON = {;</script>
+<script type="Application/LD+JSON">  </script>
+</head>
+<body>
+<script type="application/ld+json">{"@type": "Person", "name": "A"</script>
+</body>
+</html>
+`
+	issues, err := StructuredData(context.Background(), strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("StructuredData failed: ", err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, is.String())
+	}
+	want := []string{
+		`10:22 Error: Invalid JSON-LD: invalid character ',' looking for beginning of object key string (invalid-json-ld)`,
+		`14:0 Error: Empty JSON-LD script (invalid-json-ld)`,
+		`17:66 Error: Invalid JSON-LD: unexpected end of JSON input (invalid-json-ld)`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StructuredData returned:\n%v\nwant:\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
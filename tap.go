@@ -0,0 +1,55 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteTAP writes results (keyed by file path, e.g. as returned by AMPFiles) to w using
+// version 13 of the Test Anything Protocol. Each file is reported as a test in lexical
+// order, with files containing errors reported as failures. Files' issues are listed
+// in YAML diagnostic blocks, and a plan line is written at the end.
+func WriteTAP(w io.Writer, results map[string][]Issue) error {
+	paths := make([]string, 0, len(results))
+	for p := range results {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TAP version 13")
+	for i, p := range paths {
+		issues := results[p]
+		status := "ok"
+		if hasErrors(issues) {
+			status = "not ok"
+		}
+		fmt.Fprintf(bw, "%s %d - %s\n", status, i+1, strings.ReplaceAll(p, "#", `\#`))
+		if len(issues) == 0 {
+			continue
+		}
+		fmt.Fprintln(bw, "  ---")
+		fmt.Fprintln(bw, "  issues:")
+		for _, is := range issues {
+			fmt.Fprintf(bw, "    - severity: %s\n", strings.ToLower(is.Severity.String()))
+			fmt.Fprintf(bw, "      line: %d\n", is.Line)
+			if is.Col != 0 {
+				fmt.Fprintf(bw, "      column: %d\n", is.Col)
+			}
+			fmt.Fprintf(bw, "      message: %s\n", strconv.Quote(is.Message))
+			if is.Code != "" {
+				fmt.Fprintf(bw, "      code: %s\n", strconv.Quote(is.Code))
+			}
+		}
+		fmt.Fprintln(bw, "  ...")
+	}
+	fmt.Fprintf(bw, "1..%d\n", len(paths))
+	return bw.Flush()
+}
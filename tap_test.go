@@ -0,0 +1,51 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTAP(t *testing.T) {
+	results := map[string][]Issue{
+		"style.css": {{Severity: Warning, Line: 3, Message: "Unknown vendor extension"}},
+		"index.html": {
+			{Severity: Error, Line: 1, Col: 5, Message: `Bad "value" & more`, Code: "bad-value"},
+			{Severity: Info, Line: 7, Message: "Note", Code: "mixed-line-endings"},
+		},
+		"empty#1.html": nil,
+	}
+	const want = `TAP version 13
+ok 1 - empty\#1.html
+not ok 2 - index.html
+  ---
+  issues:
+    - severity: error
+      line: 1
+      column: 5
+      message: "Bad \"value\" & more"
+      code: "bad-value"
+    - severity: info
+      line: 7
+      message: "Note"
+      code: "mixed-line-endings"
+  ...
+ok 3 - style.css
+  ---
+  issues:
+    - severity: warning
+      line: 3
+      message: "Unknown vendor extension"
+  ...
+1..3
+`
+	var b bytes.Buffer
+	if err := WriteTAP(&b, results); err != nil {
+		t.Fatal("WriteTAP failed: ", err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("WriteTAP wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
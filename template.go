@@ -0,0 +1,116 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/net/html"
+)
+
+// templateContent describes the contents of a <template> element.
+type templateContent struct {
+	data []byte // raw markup between the start and end tags
+	line int    // 1-indexed line in the document where data starts
+}
+
+// extractTemplates returns the contents of the outermost <template> elements in the HTML document b.
+func extractTemplates(b []byte) []templateContent {
+	var temps []templateContent
+	var cur *templateContent
+	depth := 0 // nesting depth of <template> elements
+	z := html.NewTokenizer(bytes.NewReader(b))
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		line += bytes.Count(raw, []byte{'\n'})
+		name, _ := z.TagName()
+		isTemplate := string(name) == "template"
+
+		if tt == html.EndTagToken && isTemplate && depth > 0 {
+			if depth--; depth == 0 {
+				temps = append(temps, *cur)
+				cur = nil
+				continue
+			}
+		}
+		if cur != nil {
+			cur.data = append(cur.data, raw...)
+		}
+		if tt == html.StartTagToken && isTemplate {
+			if depth++; depth == 1 {
+				cur = &templateContent{line: line}
+			}
+		}
+	}
+	return temps
+}
+
+// templateContexts maps from the names of elements that are only permitted in specific
+// contexts to the start and end tags that are wrapped around them by wrapTemplate.
+var templateContexts = map[string][2]string{
+	"caption":  {"<table>", "</table>"},
+	"col":      {"<table><colgroup>", "</colgroup></table>"},
+	"colgroup": {"<table>", "</table>"},
+	"dd":       {"<dl>", "</dl>"},
+	"dt":       {"<dl>", "</dl>"},
+	"li":       {"<ul>", "</ul>"},
+	"optgroup": {"<select>", "</select>"},
+	"option":   {"<select>", "</select>"},
+	"tbody":    {"<table>", "</table>"},
+	"td":       {"<table><tbody><tr>", "</tr></tbody></table>"},
+	"tfoot":    {"<table>", "</table>"},
+	"th":       {"<table><tbody><tr>", "</tr></tbody></table>"},
+	"thead":    {"<table>", "</table>"},
+	"tr":       {"<table><tbody>", "</tbody></table>"},
+}
+
+// wrapTemplate returns a complete HTML document containing the template contents data.
+// If the first element in data is only permitted in a specific context (e.g. <tr>),
+// that context is added. The content starts on the document's first line.
+func wrapTemplate(data []byte) []byte {
+	var ctx [2]string
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			name, _ := z.TagName()
+			ctx = templateContexts[string(name)]
+			break
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString(`<!DOCTYPE html><html lang="en"><head><title>template</title></head><body>`)
+	b.WriteString(ctx[0])
+	b.Write(data)
+	b.WriteString(ctx[1] + "</body></html>\n")
+	return b.Bytes()
+}
+
+// validateTemplates validates the contents of each <template> element in the HTML document in
+// as a separate document and returns the issues with line numbers adjusted to refer to in.
+func validateTemplates(ctx context.Context, in []byte, opts *Options) ([]Issue, error) {
+	var issues []Issue
+	for _, t := range extractTemplates(in) {
+		ti, _, err := validateHTML(ctx, wrapTemplate(t.data), HTMLDoc, opts, opts.useJSON())
+		if err != nil {
+			return issues, err
+		}
+		for _, is := range ti {
+			if is.Line > 0 {
+				is.Line += t.line - 1
+			}
+			issues = append(issues, is)
+		}
+	}
+	return issues, nil
+}
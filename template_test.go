@@ -0,0 +1,84 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLWithOptions_ValidateTemplates(t *testing.T) {
+	var docs []string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		doc := string(b)
+		docs = append(docs, doc)
+		// Pretend that the service only checks the second line of the row template.
+		if strings.Contains(doc, "<table><tbody><tr>") && strings.Contains(doc, "<div>") {
+			io.WriteString(w, nuPage(nuError(2, 5, "Element div not allowed as child of element tr")))
+		} else {
+			io.WriteString(w, nuPage())
+		}
+	})
+
+	const doc = `<!DOCTYPE html>
+<html lang="en">
+<head><title>Test</title></head>
+<body>
+<template id="row"><tr>
+  <div>Bad</div>
+</tr></template>
+<template id="item"><p>OK</p></template>
+</body>
+</html>
+`
+	issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader(doc),
+		&Options{ValidateTemplates: true})
+	if err != nil {
+		t.Fatal("HTMLWithOptions failed: ", err)
+	}
+	if len(issues) != 1 || issues[0].Line != 6 {
+		t.Errorf("HTMLWithOptions returned %q; want one issue at line 6", issues)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("Service got %d document(s); want 3", len(docs))
+	}
+	if want := "<table><tbody><tr>\n  <div>Bad</div>\n</tr></tbody></table>"; !strings.Contains(docs[1], want) {
+		t.Errorf("Row template was uploaded as %q; want it to contain %q", docs[1], want)
+	}
+
+	// Templates shouldn't be validated separately by default.
+	docs = nil
+	if _, _, err := HTML(context.Background(), strings.NewReader(doc)); err != nil {
+		t.Error("HTML failed: ", err)
+	} else if len(docs) != 1 {
+		t.Errorf("HTML uploaded %d document(s); want 1", len(docs))
+	}
+}
+
+func TestExtractTemplates(t *testing.T) {
+	const doc = "<p>\n<template>a<template>b</template>\nc</template>\n<template></template>"
+	temps := extractTemplates([]byte(doc))
+	var got []string
+	for _, tc := range temps {
+		got = append(got, string(tc.data))
+	}
+	if want := []string{"a<template>b</template>\nc", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extractTemplates returned %q; want %q", got, want)
+	}
+	if len(temps) == 2 && (temps[0].line != 2 || temps[1].line != 4) {
+		t.Errorf("extractTemplates returned lines %d and %d; want 2 and 4", temps[0].line, temps[1].line)
+	}
+}
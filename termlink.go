@@ -0,0 +1,77 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TerminalLinkMode describes whether Issue.TerminalLink emits hyperlinks.
+type TerminalLinkMode int
+
+const (
+	// AutoTerminalLinks emits hyperlinks if stdout is a terminal other than "dumb".
+	AutoTerminalLinks TerminalLinkMode = iota
+	// AlwaysTerminalLinks always emits hyperlinks.
+	AlwaysTerminalLinks
+	// NeverTerminalLinks never emits hyperlinks.
+	NeverTerminalLinks
+)
+
+// TerminalLinks controls whether Issue.TerminalLink emits hyperlinks or plain text.
+var TerminalLinks = AutoTerminalLinks
+
+// terminalLinksSupported returns true if Issue.TerminalLink should emit hyperlinks.
+func terminalLinksSupported() bool {
+	switch TerminalLinks {
+	case AlwaysTerminalLinks:
+		return true
+	case NeverTerminalLinks:
+		return false
+	}
+	if t := os.Getenv("TERM"); t == "" || t == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// TerminalLink returns is's location as "file:line:col" text (omitting unknown parts).
+// If is.File is set and hyperlinks are enabled (see TerminalLinks), the text is wrapped in an
+// OSC 8 escape sequence linking to the file via a file:// URL with the line number as its
+// fragment, e.g. "file:///home/user/site/index.html#12". Relative paths in is.File are
+// resolved against baseDir, or against the working directory if baseDir is empty.
+func (is Issue) TerminalLink(baseDir string) string {
+	text := is.File
+	if is.Line > 0 {
+		text += fmt.Sprintf(":%d", is.Line)
+		if is.Col > 0 {
+			text += fmt.Sprintf(":%d", is.Col)
+		}
+	}
+	if is.File == "" {
+		return strings.TrimPrefix(text, ":")
+	}
+	if !terminalLinksSupported() {
+		return text
+	}
+
+	p := is.File
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(baseDir, p)
+	}
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return text
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+	if is.Line > 0 {
+		u.Fragment = fmt.Sprint(is.Line)
+	}
+	return "\x1b]8;;" + u.String() + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"testing"
+)
+
+func TestIssue_TerminalLink(t *testing.T) {
+	defer func(orig TerminalLinkMode) { TerminalLinks = orig }(TerminalLinks)
+
+	for _, tc := range []struct {
+		mode TerminalLinkMode
+		is   Issue
+		base string
+		want string
+	}{
+		{AlwaysTerminalLinks, Issue{File: "sub/index.html", Line: 12, Col: 3}, "/home/user/site",
+			"\x1b]8;;file:///home/user/site/sub/index.html#12\x1b\\sub/index.html:12:3\x1b]8;;\x1b\\"},
+		{AlwaysTerminalLinks, Issue{File: "/tmp/my page.css", Line: 4}, "/ignored",
+			"\x1b]8;;file:///tmp/my%20page.css#4\x1b\\/tmp/my page.css:4\x1b]8;;\x1b\\"},
+		{AlwaysTerminalLinks, Issue{Line: 5, Col: 1}, "/home/user", "5:1"},
+		{NeverTerminalLinks, Issue{File: "index.html", Line: 12, Col: 3}, "/home/user", "index.html:12:3"},
+	} {
+		TerminalLinks = tc.mode
+		if got := tc.is.TerminalLink(tc.base); got != tc.want {
+			t.Errorf("TerminalLink(%q) with mode %v for %+v returned %q; want %q",
+				tc.base, tc.mode, tc.is, got, tc.want)
+		}
+	}
+}
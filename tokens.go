@@ -0,0 +1,50 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+
+	"golang.org/x/net/html"
+)
+
+// lineToken is an HTML token annotated with its location within the document.
+type lineToken struct {
+	html.Token
+	line    int // 1-indexed line where the token starts
+	endLine int // 1-indexed line where the token ends
+	offset  int // byte offset of the start of the token
+	end     int // byte offset just past the end of the token
+}
+
+// tokenizeLines tokenizes the HTML document in b and returns its tokens.
+// Unlike html.Parse, this preserves line numbers, which is useful for local checks.
+func tokenizeLines(b []byte) []lineToken {
+	var toks []lineToken
+	z := html.NewTokenizer(bytes.NewReader(b))
+	line, off := 1, 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		// Examine the raw data before calling Token, since it's only valid until then.
+		raw := z.Raw()
+		start, startOff := line, off
+		line += bytes.Count(raw, []byte{'\n'})
+		off += len(raw)
+		toks = append(toks, lineToken{Token: z.Token(), line: start, endLine: line, offset: startOff, end: off})
+	}
+	return toks
+}
+
+// tokenAttr returns the named attribute from t and a bool indicating whether it was present.
+func tokenAttr(t *html.Token, name string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,265 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// HTMLURL fetches the HTML page at url (following redirects) and validates it using HTML.
+// The page is fetched locally and its contents are uploaded to the validation service.
+// If the server returns a non-2xx status, a *FetchError is returned.
+func HTMLURL(ctx context.Context, url string) ([]Issue, []byte, error) {
+	return HTMLURLWithOptions(ctx, url, nil)
+}
+
+// HTMLURLWithOptions is similar to HTMLURL but accepts additional options.
+// Options.FetchHeader and Options.FetchCookies can be used to fetch pages that require
+// authentication. opts may be nil.
+// data: URLs are decoded locally rather than being fetched.
+func HTMLURLWithOptions(ctx context.Context, url string, opts *Options) ([]Issue, []byte, error) {
+	if isDataURI(url) {
+		return htmlDataURI(ctx, url, opts)
+	}
+	resp, err := fetch(ctx, opts, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	page, err := readPage(resp, url, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return HTMLWithOptions(ctx, bytes.NewReader(page), opts)
+}
+
+// htmlDataURI decodes the HTML document in the supplied data: URI and validates it
+// using HTMLWithOptions.
+func htmlDataURI(ctx context.Context, uri string, opts *Options) ([]Issue, []byte, error) {
+	_, data, err := ParseDataURI(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	return HTMLWithOptions(ctx, bytes.NewReader(data), opts)
+}
+
+// readPage reads and returns the body of resp, which was returned by fetch for url.
+// A *FetchError is returned if the server returned a non-2xx status.
+func readPage(resp *http.Response, url string, opts *Options) ([]byte, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &FetchError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return readResponse(resp.Body, opts.maxResponseSize())
+}
+
+// FetchError is returned by HTMLURL and URLCache if a page couldn't be fetched
+// because the server returned a non-2xx status.
+type FetchError struct {
+	// URL is the URL of the page.
+	URL string
+	// StatusCode is the HTTP status code returned by the server, e.g. 404.
+	StatusCode int
+	// Status is the HTTP status returned by the server, e.g. "404 Not Found".
+	Status string
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("fetching %v failed: %v", e.URL, e.Status)
+}
+
+// URLCache validates HTML pages fetched from URLs and caches the results.
+// When a page is validated again, a conditional request is sent using the ETag and
+// Last-Modified headers from the previous response, and the cached results are returned
+// if the server reports that the page hasn't changed.
+//
+// URLCache is safe for concurrent use. The zero value is ready to use.
+type URLCache struct {
+	// Options is passed to HTMLWithOptions. It may be nil.
+	Options *Options
+
+	mu      sync.Mutex
+	entries map[string]urlCacheEntry // keyed by URL
+}
+
+// urlCacheEntry holds a page's validation results.
+type urlCacheEntry struct {
+	etag, lastModified string // from fetch response
+	issues             []Issue
+	out                []byte
+}
+
+// HTML fetches the HTML page at url and validates it using HTMLWithOptions.
+// The page is fetched locally and its contents are uploaded to the validation service,
+// so pages that require authentication can be validated using Options.FetchHeader
+// and Options.FetchCookies.
+// Cached results are returned if the page is unchanged since it was last validated.
+// data: URLs are decoded locally and never cached.
+func (c *URLCache) HTML(ctx context.Context, url string) ([]Issue, []byte, error) {
+	if isDataURI(url) {
+		return htmlDataURI(ctx, url, c.Options)
+	}
+
+	c.mu.Lock()
+	ent, cached := c.entries[url]
+	c.mu.Unlock()
+
+	hdr := make(http.Header)
+	if cached {
+		if ent.etag != "" {
+			hdr.Set("If-None-Match", ent.etag)
+		}
+		if ent.lastModified != "" {
+			hdr.Set("If-Modified-Since", ent.lastModified)
+		}
+	}
+	resp, err := fetch(ctx, c.Options, url, hdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		// Return a copy so callers can't modify the cached issues.
+		return append([]Issue(nil), ent.issues...), ent.out, nil
+	}
+	page, err := readPage(resp, url, c.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issues, out, err := HTMLWithOptions(ctx, bytes.NewReader(page), c.Options)
+	if err != nil {
+		return issues, out, err
+	}
+
+	ent = urlCacheEntry{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		issues:       append([]Issue(nil), issues...),
+		out:          out,
+	}
+	c.mu.Lock()
+	if ent.etag != "" || ent.lastModified != "" {
+		if c.entries == nil {
+			c.entries = make(map[string]urlCacheEntry)
+		}
+		c.entries[url] = ent
+	} else {
+		delete(c.entries, url)
+	}
+	c.mu.Unlock()
+
+	return issues, out, nil
+}
+
+// fetchUserAgent is the default User-Agent header sent by fetch.
+const fetchUserAgent = "validate (+https://github.com/derat/validate)"
+
+// fetch sends a GET request for url with the supplied headers, along with opts.FetchHeader
+// and opts.FetchCookies. fetchUserAgent is sent unless another User-Agent header is supplied.
+// The caller is responsible for checking the response's status and closing its body.
+func fetch(ctx context.Context, opts *Options, url string, hdr http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fetchUserAgent)
+	if opts != nil {
+		for k, vals := range opts.FetchHeader {
+			req.Header.Del(k)
+			for _, v := range vals {
+				req.Header.Add(k, v)
+			}
+		}
+		for _, c := range opts.FetchCookies {
+			req.AddCookie(c)
+		}
+	}
+	for k, vals := range hdr {
+		req.Header.Del(k)
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	return opts.client().Do(req)
+}
+
+// isDataURI returns true if uri uses the data: scheme.
+func isDataURI(uri string) bool {
+	return len(uri) >= 5 && strings.EqualFold(uri[:5], "data:")
+}
+
+// ParseDataURI parses a data: URI as described in RFC 2397, e.g.
+// "data:text/html;base64,PCFET0NUWVBFIGh0bWw+" or "data:text/css,body%7Bmargin:0%7D".
+// The media type (without parameters, e.g. "text/html") and the decoded data are returned.
+func ParseDataURI(uri string) (string, []byte, error) {
+	if !isDataURI(uri) {
+		return "", nil, errors.New("not a data: URI")
+	}
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return "", nil, errors.New("data: URI missing comma")
+	}
+	meta, payload := uri[len("data:"):comma], uri[comma+1:]
+
+	isBase64 := false
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		isBase64 = true
+		meta = meta[:len(meta)-len(";base64")]
+	}
+	mtype := "text/plain"
+	if meta != "" && !strings.HasPrefix(meta, ";") {
+		var err error
+		if mtype, _, err = mime.ParseMediaType(meta); err != nil {
+			return "", nil, fmt.Errorf("bad media type %q: %v", meta, err)
+		}
+	}
+
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	if !isBase64 {
+		return mtype, []byte(data), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		// Some encoders omit padding.
+		if b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+			return "", nil, err
+		}
+	}
+	return mtype, b, nil
+}
+
+// DataURI decodes the document in the supplied data: URI (see ParseDataURI) and validates it
+// using HTML, XHTML, or CSS depending on its declared media type ("text/html",
+// "application/xhtml+xml", or "text/css").
+// Only the decoding is performed locally: the document is uploaded to validator.w3.org
+// or jigsaw.w3.org just as if it had been passed to HTML, XHTML, or CSS directly.
+func DataURI(ctx context.Context, uri string) ([]Issue, []byte, error) {
+	mtype, data, err := ParseDataURI(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch mtype {
+	case string(HTMLDoc):
+		return HTML(ctx, bytes.NewReader(data))
+	case string(XHTMLDoc):
+		return XHTML(ctx, bytes.NewReader(data))
+	case string(Stylesheet):
+		return CSS(ctx, bytes.NewReader(data), Stylesheet)
+	default:
+		return nil, nil, fmt.Errorf("unsupported media type %q", mtype)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLURL(t *testing.T) {
+	var uploaded string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		uploaded = string(b)
+		io.WriteString(w, nuPage(nuError(1, 16, "Bad element")))
+	})
+
+	const doc = "<!DOCTYPE html><bogus>"
+	var agents []string
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+		switch r.URL.Path {
+		case "/old.html":
+			http.Redirect(w, r, "/page.html", http.StatusMovedPermanently)
+		case "/page.html":
+			io.WriteString(w, doc)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer page.Close()
+
+	issues, out, err := HTMLURL(context.Background(), page.URL+"/old.html")
+	if err != nil {
+		t.Fatal("HTMLURL failed: ", err)
+	}
+	if len(issues) != 1 || issues[0].Message != "Bad element" {
+		t.Errorf("HTMLURL returned %q; want single issue", issues)
+	}
+	if len(out) == 0 {
+		t.Error("HTMLURL returned empty output")
+	}
+	if uploaded != doc {
+		t.Errorf("HTMLURL uploaded %q; want %q", uploaded, doc)
+	}
+	if want := []string{fetchUserAgent, fetchUserAgent}; !reflect.DeepEqual(agents, want) {
+		t.Errorf("HTMLURL sent user agents %q; want %q", agents, want)
+	}
+
+	_, _, err = HTMLURL(context.Background(), page.URL+"/missing.html")
+	if ferr, ok := err.(*FetchError); !ok {
+		t.Errorf("HTMLURL for missing page returned %v; want *FetchError", err)
+	} else if ferr.StatusCode != http.StatusNotFound {
+		t.Errorf("HTMLURL for missing page returned status %v; want %v", ferr.StatusCode, http.StatusNotFound)
+	}
+
+	uploaded = ""
+	uri := "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(doc))
+	if issues, _, err = HTMLURL(context.Background(), uri); err != nil {
+		t.Errorf("HTMLURL(%q) failed: %v", uri, err)
+	} else if len(issues) != 1 || uploaded != doc {
+		t.Errorf("HTMLURL(%q) uploaded %q and returned %q; want %q and single issue", uri, uploaded, issues, doc)
+	}
+}
+
+func TestURLCache_HTML(t *testing.T) {
+	var validations int
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		validations++
+		io.WriteString(w, nuPage(nuError(1, 2, "Bad page")))
+	})
+
+	var fetches, notModified int
+	const etag = `"v1"`
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, "<!DOCTYPE html><bogus>")
+	}))
+	defer page.Close()
+
+	var cache URLCache
+	first, _, err := cache.HTML(context.Background(), page.URL)
+	if err != nil {
+		t.Fatal("First HTML call failed: ", err)
+	}
+	second, _, err := cache.HTML(context.Background(), page.URL)
+	if err != nil {
+		t.Fatal("Second HTML call failed: ", err)
+	}
+	if !reflect.DeepEqual(second, first) {
+		t.Errorf("Second HTML call returned %q; want %q", second, first)
+	}
+	if fetches != 2 || notModified != 1 {
+		t.Errorf("Page was fetched %v time(s) with %v 304(s); want 2 and 1", fetches, notModified)
+	}
+	if validations != 1 {
+		t.Errorf("Page was validated %v time(s); want 1", validations)
+	}
+}
+
+func TestURLCache_HTMLCopiesIssues(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(1, 2, "Bad page")))
+	})
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "<!DOCTYPE html><bogus>")
+	}))
+	defer page.Close()
+
+	var cache URLCache
+	first, _, err := cache.HTML(context.Background(), page.URL)
+	if err != nil {
+		t.Fatal("First HTML call failed: ", err)
+	}
+	first[0].Message = "Modified"
+	for i := 0; i < 2; i++ {
+		issues, _, err := cache.HTML(context.Background(), page.URL)
+		if err != nil {
+			t.Fatal("HTML failed: ", err)
+		}
+		if len(issues) != 1 || issues[0].Message != "Bad page" {
+			t.Fatalf("HTML returned %q after modifying earlier results; want original issue", issues)
+		}
+		issues[0].Message = "Modified"
+	}
+}
+
+func TestURLCache_HTMLAuth(t *testing.T) {
+	var uploaded string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Header["Cookie"]; ok {
+			http.Error(w, "Got cookie", http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		uploaded = string(b)
+		io.WriteString(w, nuPage())
+	})
+
+	const secret = "<!DOCTYPE html><title>Private</title>"
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+			http.Error(w, "Not logged in", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, secret)
+	}))
+	defer page.Close()
+
+	var anon URLCache
+	if _, _, err := anon.HTML(context.Background(), page.URL); err == nil {
+		t.Error("HTML unexpectedly succeeded without cookie")
+	}
+
+	auth := URLCache{Options: &Options{FetchCookies: []*http.Cookie{{Name: "session", Value: "abc"}}}}
+	if _, _, err := auth.HTML(context.Background(), page.URL); err != nil {
+		t.Error("HTML failed with cookie: ", err)
+	} else if uploaded != secret {
+		t.Errorf("HTML uploaded %q; want %q", uploaded, secret)
+	}
+}
+
+func TestDataURI(t *testing.T) {
+	var uploaded, uploadedType string
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		f, hdr, err := r.FormFile("uploaded_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		uploaded = string(b)
+		uploadedType = hdr.Header.Get("Content-Type")
+		io.WriteString(w, nuPage(nuError(1, 16, "Element bogus not allowed as child of element body")))
+	})
+
+	const doc = "<!DOCTYPE html><bogus>"
+	for _, uri := range []string{
+		"data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(doc)),
+		"data:text/html;charset=utf-8," + strings.ReplaceAll(doc, "<", "%3C"),
+	} {
+		issues, _, err := DataURI(context.Background(), uri)
+		if err != nil {
+			t.Errorf("DataURI(%q) failed: %v", uri, err)
+			continue
+		}
+		if uploaded != doc {
+			t.Errorf("DataURI(%q) uploaded %q; want %q", uri, uploaded, doc)
+		}
+		if len(issues) != 1 {
+			t.Errorf("DataURI(%q) returned %q; want 1 issue", uri, issues)
+		}
+	}
+
+	const xdoc = `<html xmlns="http://www.w3.org/1999/xhtml"><bogus/></html>`
+	uploaded = ""
+	uri := "data:application/xhtml+xml;base64," + base64.StdEncoding.EncodeToString([]byte(xdoc))
+	if issues, _, err := DataURI(context.Background(), uri); err != nil {
+		t.Errorf("DataURI(%q) failed: %v", uri, err)
+	} else if uploaded != xdoc || uploadedType != XHTMLDoc {
+		t.Errorf("DataURI(%q) uploaded %q as %q; want %q as %q", uri, uploaded, uploadedType, xdoc, XHTMLDoc)
+	} else if len(issues) != 1 {
+		t.Errorf("DataURI(%q) returned %q; want 1 issue", uri, issues)
+	}
+
+	if _, _, err := DataURI(context.Background(), "data:image/png;base64,AAAA"); err == nil {
+		t.Error("DataURI unexpectedly accepted image")
+	}
+}
@@ -4,15 +4,20 @@ package validate
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 )
@@ -25,8 +30,96 @@ const (
 	Stylesheet FileType = "text/css"
 	// HTMLDoc is an HTML document.
 	HTMLDoc = "text/html"
+	// XHTMLDoc is an XHTML document, i.e. an HTML document using the XML syntax.
+	XHTMLDoc = "application/xhtml+xml"
+	// AMPDoc is an AMP HTML document. It isn't a MIME type and is only accepted by Validate.
+	AMPDoc = "amp"
+	// HTMLCSS is an HTML document whose embedded CSS (rather than its markup) should be
+	// validated. It isn't a MIME type and is only accepted by Validate.
+	HTMLCSS = "htmlcss"
 )
 
+// Validate reads a document of type ft from r and validates it using the appropriate
+// validator: CSS for Stylesheet, HTML for HTMLDoc, XHTML for XHTMLDoc, AMP for AMPDoc,
+// or CSS with HTMLDoc for HTMLCSS. Parsed issues and the raw results page returned by the
+// validation service are returned. The page is nil for AMPDoc, since amphtml-validator
+// doesn't generate one (see RenderResultsPage).
+// If the returned error is non-nil, an issue occurred in the validation process.
+func Validate(ctx context.Context, r io.Reader, ft FileType) ([]Issue, []byte, error) {
+	return ValidateWithOptions(ctx, r, ft, nil)
+}
+
+// ValidateWithOptions is similar to Validate but accepts additional options.
+// opts may be nil.
+func ValidateWithOptions(ctx context.Context, r io.Reader, ft FileType, opts *Options) ([]Issue, []byte, error) {
+	switch ft {
+	case Stylesheet:
+		return CSSWithOptions(ctx, r, Stylesheet, opts)
+	case HTMLDoc:
+		return HTMLWithOptions(ctx, r, opts)
+	case XHTMLDoc:
+		return XHTMLWithOptions(ctx, r, opts)
+	case AMPDoc:
+		issues, err := AMPWithOptions(ctx, r, opts)
+		return issues, nil, err
+	case HTMLCSS:
+		return CSSWithOptions(ctx, r, HTMLDoc, opts)
+	default:
+		return nil, nil, fmt.Errorf("unsupported file type %q", ft)
+	}
+}
+
+// ValidateAuto reads a document from r, infers its type using DetectType, and validates it
+// as described by Validate. The detected type is returned along with the issues and raw
+// results page (which is nil for AMPDoc). If the type isn't supported, an
+// *UnsupportedTypeError is returned.
+func ValidateAuto(ctx context.Context, r io.Reader) (FileType, []Issue, []byte, error) {
+	return ValidateAutoWithOptions(ctx, r, nil)
+}
+
+// ValidateAutoWithOptions is similar to ValidateAuto but accepts additional options.
+// opts may be nil.
+func ValidateAutoWithOptions(ctx context.Context, r io.Reader, opts *Options) (
+	FileType, []Issue, []byte, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	ft, err := DetectType(b)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	issues, out, err := ValidateWithOptions(ctx, bytes.NewReader(b), ft, opts)
+	return ft, issues, out, err
+}
+
+// Report contains the results of validating a document with ValidateResult.
+type Report struct {
+	// Issues contains the issues that were found.
+	Issues []Issue
+	// Page contains the raw results page returned by the validation service, if any.
+	Page []byte
+	// Passed reports whether the document should be considered valid: it is false if Issues
+	// contains any Error issues or, if Options.FailOnWarnings is set, any Warning issues.
+	Passed bool
+}
+
+// ValidateResult is similar to Validate, but returns a Report containing an authoritative
+// pass/fail verdict in addition to the issues and results page.
+func ValidateResult(ctx context.Context, r io.Reader, ft FileType) (*Report, error) {
+	return ValidateResultWithOptions(ctx, r, ft, nil)
+}
+
+// ValidateResultWithOptions is similar to ValidateResult but accepts additional options.
+// opts may be nil.
+func ValidateResultWithOptions(ctx context.Context, r io.Reader, ft FileType, opts *Options) (*Report, error) {
+	issues, page, err := ValidateWithOptions(ctx, r, ft, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{Issues: issues, Page: page, Passed: opts.passed(issues)}, nil
+}
+
 // Severity describes the severity of an issue.
 type Severity int
 
@@ -35,6 +128,8 @@ const (
 	Error Severity = iota
 	// Warning indicates a minor issue, e.g. a vendor-prefixed CSS property.
 	Warning
+	// Info indicates an informational notice that doesn't necessarily need to be addressed.
+	Info
 )
 
 func (s Severity) String() string {
@@ -43,29 +138,128 @@ func (s Severity) String() string {
 		return "Error"
 	case Warning:
 		return "Warning"
+	case Info:
+		return "Info"
 	default:
 		return ""
 	}
 }
 
+// MarshalJSON encodes s as a lowercase string, e.g. "error".
+func (s Severity) MarshalJSON() ([]byte, error) {
+	str := s.String()
+	if str == "" {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+	return json.Marshal(strings.ToLower(str))
+}
+
+// UnmarshalJSON decodes a string encoded by MarshalJSON.
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	for _, sev := range []Severity{Error, Warning, Info} {
+		if strings.EqualFold(str, sev.String()) {
+			*s = sev
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid severity %q", str)
+}
+
 // Issue describes a problem reported by a validator.
+// It is encoded to JSON as an object with lowercase keys like "severity" and "line".
 type Issue struct {
 	// Severity describes the seriousness of the issue.
-	Severity Severity
+	Severity Severity `json:"severity"`
 	// Line contains the 1-indexed line number where the issue occurred.
 	// It is 0 if the line is unknown.
-	Line int
-	// Col contains the 1-indexed column number where the issue occurred.
-	// It is 0 if the column is unknown.
-	Col int
+	Line int `json:"line"`
+	// Col contains the column number where the issue occurred, counting from ColumnBase
+	// (i.e. 1-indexed by default). It is ColumnBase-1 if the column is unknown.
+	Col int `json:"col"`
 	// Message describes the issue.
-	Message string
-	// Code contains an optional code provided by the validator.
-	Code string
+	Message string `json:"message"`
+	// Code contains an optional machine-readable code provided by the validator (e.g. AMP's
+	// "MANDATORY_TAG_MISSING") or by one of this package's local checks.
+	Code string `json:"code,omitempty"`
 	// Context optionally provides more detail about the context in which the issue occurred.
-	Context string
-	// Context optionally provides a URL with more information about the issue.
-	URL string
+	Context string `json:"context"`
+	// HighlightStart and HighlightLength optionally identify the byte range within Context
+	// corresponding to the issue. HighlightLength is 0 if the range is unknown.
+	HighlightStart  int `json:"highlightStart,omitempty"`
+	HighlightLength int `json:"highlightLength,omitempty"`
+	// URL optionally provides a URL with more information about the issue, e.g. a help or
+	// spec page linked from the validation service's results.
+	URL string `json:"url,omitempty"`
+	// Anchor optionally contains the ID of the element describing the issue within the
+	// results page returned by the validation service (e.g. "cl6c14"). See LaunchBrowserAt.
+	Anchor string `json:"anchor,omitempty"`
+	// SourceLine contains the 1-indexed line number in the document's original source
+	// corresponding to Line, as reported by Options.SourceLineMap. It is 0 if unknown.
+	SourceLine int `json:"sourceLine,omitempty"`
+	// ContextWindow optionally contains newline-separated lines from the validated document
+	// surrounding Line (see Options.ContextLines). The line where the issue occurred is
+	// prefixed by "> ", while other lines are prefixed by two spaces.
+	ContextWindow string `json:"contextWindow,omitempty"`
+	// File contains the path of the file in which the issue occurred, if known
+	// (e.g. for issues returned by AMPFiles).
+	File string `json:"file,omitempty"`
+	// Source identifies the validator that reported the issue. It is empty for issues
+	// reported by local checks (e.g. HTMLChecks or Options.ReportMixedLineEndings).
+	Source IssueSource `json:"source,omitempty"`
+	// Location optionally identifies the part of an HTML document containing the issue,
+	// e.g. StyleElement for issues reported by CSS in a <style> element's contents.
+	Location IssueLocation `json:"location,omitempty"`
+}
+
+// ColumnBase is the number of the first column in each line, as reported in Issue.Col by HTML,
+// XHTML, CSS, AMP, AMPFiles, and Lint (and their *WithOptions variants). It must be 0 or 1.
+// Validators report columns inconsistently, so they are converted to this base. With the
+// default value of 1, unknown columns are reported as 0; if ColumnBase is 0, they are -1.
+var ColumnBase = 1
+
+// normalizeColumns converts the 1-indexed Col fields of issues to use ColumnBase.
+func normalizeColumns(issues []Issue) {
+	if ColumnBase == 1 {
+		return
+	}
+	for i := range issues {
+		issues[i].Col += ColumnBase - 1
+	}
+}
+
+// IssueSource identifies the validator that reported an Issue.
+type IssueSource string
+
+const (
+	// HTMLSource indicates https://validator.w3.org/nu/ (used by HTML and XHTML).
+	HTMLSource IssueSource = "HTML"
+	// CSSSource indicates https://jigsaw.w3.org/css-validator/ (used by CSS).
+	CSSSource IssueSource = "CSS"
+	// AMPSource indicates amphtml-validator (used by AMP and AMPFiles).
+	AMPSource IssueSource = "AMP"
+)
+
+// IssueLocation identifies the part of an HTML document in which an Issue occurred.
+type IssueLocation string
+
+const (
+	// StyleElement indicates the contents of a <style> element.
+	StyleElement IssueLocation = "style-element"
+	// StyleAttribute indicates an element's style attribute.
+	StyleAttribute IssueLocation = "style-attribute"
+)
+
+// setSource sets the Source field of each of issues that doesn't already have one to src.
+func setSource(issues []Issue, src IssueSource) {
+	for i := range issues {
+		if issues[i].Source == "" {
+			issues[i].Source = src
+		}
+	}
 }
 
 func (is Issue) String() string {
@@ -119,19 +313,64 @@ func getText(n *html.Node, f func(*html.Node) bool) string {
 	return s
 }
 
-// checkResponse returns an error if the validator's report of success
-// and the issues parsed from its response disagree, i.e. success is true
-// but there are errors in issues but success is false but no errors were found.
-// This should help prevent reporting success falsely if/when the results format changes.
-func checkResponse(success bool, issues []Issue) error {
-	gotError := false
+// getLinkURL recursively walks n and returns the first absolute HTTP(S) URL linked by an
+// <a> element, or an empty string if none is found. Relative links (e.g. to anchors within
+// a results page) are skipped.
+func getLinkURL(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "a" {
+		href := strings.TrimSpace(getAttr(n, "href"))
+		if strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "http://") {
+			return href
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if u := getLinkURL(c); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// ResponseError is returned by HTML and CSS if a validation service's response couldn't be
+// interpreted, e.g. because the service's output format changed.
+type ResponseError struct {
+	// Err describes the problem.
+	Err error
+	// Response contains the raw response, which may be useful when filing a bug.
+	Response []byte
+}
+
+func (e *ResponseError) Error() string {
+	return e.Err.Error()
+}
+
+// RedirectError is returned by functions that use online validation services (e.g. HTML and CSS)
+// if the service redirected the request to a different host, e.g. a maintenance page.
+type RedirectError struct {
+	// URL is the URL that the service redirected to.
+	URL string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("validation service redirected to %v", e.URL)
+}
+
+// hasErrors returns true if issues contains any issues with Error severity.
+func hasErrors(issues []Issue) bool {
 	for _, is := range issues {
 		if is.Severity == Error {
-			gotError = true
-			break
+			return true
 		}
 	}
+	return false
+}
 
+// checkResponse returns an error if the validator's report of success
+// and the issues parsed from its response disagree, i.e. success is true
+// but there are errors in issues but success is false but no errors were found.
+// This should help prevent reporting success falsely if/when the results format changes.
+func checkResponse(success bool, issues []Issue) error {
+	gotError := hasErrors(issues)
 	if !success && !gotError {
 		return errors.New("got neither errors nor success message")
 	} else if success && gotError {
@@ -148,20 +387,29 @@ type fileInfo struct {
 	r     io.Reader // file data
 }
 
-// post executes a POST request to URL with the supplied fields
-// and files sent as a multipart/form-data body.
-func post(ctx context.Context, url string, fields map[string]string, files []fileInfo) (*http.Response, error) {
+// post uses opts's service client to execute a POST request to URL with the supplied fields
+// and files sent as a multipart/form-data body. If the request is redirected to a
+// different host, a *RedirectError is returned. Requests that receive responses with
+// retryable status codes are retried up to Options.MaxRetries times. If gzipMax is
+// non-negative, bodies larger than it are gzip-compressed.
+func post(ctx context.Context, opts *Options, url string, fields map[string]string,
+	files []fileInfo, gzipMax int) (*http.Response, error) {
 	// See https://stackoverflow.com/a/20397167.
 	var b bytes.Buffer
 	mw := multipart.NewWriter(&b)
 
-	// Add non-file fields.
-	for k, v := range fields {
+	// Add non-file fields. Sort them so the body is consistent across requests.
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
 		fw, err := mw.CreateFormField(k)
 		if err != nil {
 			return nil, err
 		}
-		if _, err := io.WriteString(fw, v); err != nil {
+		if _, err := io.WriteString(fw, fields[k]); err != nil {
 			return nil, err
 		}
 	}
@@ -190,14 +438,72 @@ func post(ctx context.Context, url string, fields map[string]string, files []fil
 		return nil, err
 	}
 
-	// TODO: Update this to use http.NewRequestWithContext (introduced in Go 1.12) someday.
-	req, err := http.NewRequest("POST", url, &b)
-	if err != nil {
+	body := b.Bytes()
+	compressed := gzipMax >= 0 && len(body) > gzipMax
+	if compressed {
+		var err error
+		if body, err = gzipData(body); err != nil {
+			return nil, err
+		}
+	}
+
+	client := opts.serviceClient()
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		if compressed {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		// Don't parse an unrelated page as the service's results. Custom RoundTrippers
+		// (e.g. Cassette) may not set the response's request.
+		if resp.Request != nil && resp.Request.URL.Host != req.URL.Host {
+			resp.Body.Close()
+			return nil, &RedirectError{resp.Request.URL.String()}
+		}
+		if attempt >= opts.maxRetries() || !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		delay := opts.retryDelay(attempt, resp.Header.Get("Retry-After"), time.Now())
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10)) // allow connection reuse
+		resp.Body.Close()
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// gzipData returns b compressed using gzip.
+func gzipData(b []byte) ([]byte, error) {
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	if _, err := gw.Write(b); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
 
-	return http.DefaultClient.Do(req)
+// readResponse reads and returns all of r, which typically contains a validation service's response.
+// An error is returned if r contains more than max bytes.
+func readResponse(r io.Reader, max int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > max {
+		return nil, fmt.Errorf("response exceeded max size of %d bytes", max)
+	}
+	return b, nil
 }
 
 // From Go's src/mime/multipart/writer.go.
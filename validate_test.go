@@ -0,0 +1,367 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	// Make each service report an error describing how it was called.
+	uploadType := func(r *http.Request, field string) string {
+		f, hdr, err := r.FormFile(field)
+		if err != nil {
+			return "missing file"
+		}
+		f.Close()
+		return hdr.Header.Get("Content-Type")
+	}
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		msg := "html " + uploadType(r, "uploaded_file")
+		if r.FormValue("parser") != "" {
+			msg += " " + r.FormValue("parser")
+		}
+		io.WriteString(w, nuPage(nuError(1, 1, msg)))
+	})
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(jigsawRow("error", 1, "", "css "+uploadType(r, "file"))))
+	})
+	stubAMPValidator(t)
+
+	for _, tc := range []struct {
+		ft   FileType
+		msg  string // expected message of single issue
+		page bool   // whether a results page is expected
+	}{
+		{Stylesheet, "css text/css", true},
+		{HTMLDoc, "html text/html", true},
+		{XHTMLDoc, "html application/xhtml+xml xml", true},
+		{HTMLCSS, "css text/html", true},
+		{AMPDoc, "Bad", false},
+	} {
+		issues, out, err := Validate(context.Background(), strings.NewReader("BAD"), tc.ft)
+		if err != nil {
+			t.Errorf("Validate(%q) failed: %v", tc.ft, err)
+			continue
+		}
+		if len(issues) != 1 || issues[0].Message != tc.msg {
+			t.Errorf("Validate(%q) returned %q; want single issue with message %q", tc.ft, issues, tc.msg)
+		}
+		if got := out != nil; got != tc.page {
+			t.Errorf("Validate(%q) returned page %v; want %v", tc.ft, got, tc.page)
+		}
+	}
+
+	if _, _, err := Validate(context.Background(), strings.NewReader(""), "image/png"); err == nil {
+		t.Error("Validate unexpectedly succeeded for unsupported type")
+	}
+}
+
+func TestValidateAuto(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(1, 1, "html")))
+	})
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(jigsawRow("error", 1, "", "css")))
+	})
+	stubAMPValidator(t)
+
+	for _, tc := range []struct {
+		doc  string
+		ft   FileType
+		msg  string // expected message of single issue
+		page bool   // whether a results page is expected
+	}{
+		{"<!DOCTYPE html>\n<html lang=\"en\"><title>Test</title>", HTMLDoc, "html", true},
+		{"body { color: red }\n", Stylesheet, "css", true},
+		{strings.Replace(minimalAMP, "</body>", "BAD</body>", 1), AMPDoc, "Bad", false},
+	} {
+		ft, issues, out, err := ValidateAuto(context.Background(), strings.NewReader(tc.doc))
+		if err != nil {
+			t.Errorf("ValidateAuto(%q) failed: %v", tc.doc, err)
+			continue
+		}
+		if ft != tc.ft {
+			t.Errorf("ValidateAuto(%q) detected %q; want %q", tc.doc, ft, tc.ft)
+		}
+		if len(issues) != 1 || issues[0].Message != tc.msg {
+			t.Errorf("ValidateAuto(%q) returned %q; want single issue with message %q", tc.doc, issues, tc.msg)
+		}
+		if got := out != nil; got != tc.page {
+			t.Errorf("ValidateAuto(%q) returned page %v; want %v", tc.doc, got, tc.page)
+		}
+	}
+
+	var ute *UnsupportedTypeError
+	png := strings.NewReader("\x89PNG\r\n\x1a\n")
+	if _, _, _, err := ValidateAuto(context.Background(), png); !errors.As(err, &ute) {
+		t.Errorf("ValidateAuto returned %v for PNG; want *UnsupportedTypeError", err)
+	}
+}
+
+func TestValidateResult(t *testing.T) {
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		var rows []string
+		if strings.Contains(string(b), "bogus") {
+			rows = append(rows, jigsawRow("error", 1, "p", "Property bogus doesn't exist"))
+		}
+		if strings.Contains(string(b), "-webkit-") {
+			rows = append(rows, jigsawRow("warning", 1, "p", "-webkit-transform is an unknown vendor extension"))
+		}
+		io.WriteString(w, jigsawPage(rows...))
+	})
+
+	for _, tc := range []struct {
+		doc  string
+		opts *Options
+		want bool
+	}{
+		{"p { color: red }", nil, true},
+		{"p { bogus: 0 }", nil, false},
+		{"p { bogus: 0; -webkit-transform: none }", nil, false},
+		// Warnings only cause failure if requested.
+		{"p { -webkit-transform: none }", nil, true},
+		{"p { -webkit-transform: none }", &Options{FailOnWarnings: true}, false},
+		// Info issues never cause failure.
+		{"p {}\r\np {}\n", &Options{ReportMixedLineEndings: true, FailOnWarnings: true}, true},
+	} {
+		rep, err := ValidateResultWithOptions(context.Background(), strings.NewReader(tc.doc), Stylesheet, tc.opts)
+		if err != nil {
+			t.Errorf("ValidateResultWithOptions(%q) failed: %v", tc.doc, err)
+		} else if rep.Passed != tc.want {
+			t.Errorf("ValidateResultWithOptions(%q) reported Passed=%v with issues %q; want %v",
+				tc.doc, rep.Passed, rep.Issues, tc.want)
+		} else if len(rep.Page) == 0 {
+			t.Errorf("ValidateResultWithOptions(%q) didn't return page", tc.doc)
+		}
+	}
+}
+
+// fakeService starts an HTTP server that handles requests using h and points *url
+// (e.g. htmlURL or cssURL) at it. The server is stopped and *url is restored
+// when the test completes.
+func fakeService(t *testing.T, url *string, h http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewServer(h)
+	orig := *url
+	*url = srv.URL
+	t.Cleanup(func() {
+		*url = orig
+		srv.Close()
+	})
+	return srv
+}
+
+// stubCommand writes a shell script named name containing script to a temporary directory
+// and prepends the directory to $PATH. The directory's path is returned. $PATH is restored
+// and the directory is deleted when the test completes.
+func stubCommand(t *testing.T, name, script string) string {
+	dir := makeTempDir(t)
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed writing %v: %v", p, err)
+	}
+	origPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+origPath)
+	t.Cleanup(func() {
+		os.Setenv("PATH", origPath)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// setEnv sets the environment variable named name to val and restores its
+// original value when the test completes.
+func setEnv(t *testing.T, name, val string) {
+	orig, ok := os.LookupEnv(name)
+	os.Setenv(name, val)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, orig)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestColumnBase(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(2, 3, "Bad element")))
+	})
+	stubAMPValidator(t) // reports 0-indexed column 0
+	defer func(orig int) { ColumnBase = orig }(ColumnBase)
+
+	ctx := context.Background()
+	const doc = "<!DOCTYPE html>\n  <bogus>BAD\n"
+	for _, tc := range []struct {
+		base            int
+		html, amp, lint int // expected columns
+	}{
+		{base: 1, html: 3, amp: 1, lint: 4},
+		{base: 0, html: 2, amp: 0, lint: 3},
+	} {
+		ColumnBase = tc.base
+		if issues, _, err := HTML(ctx, strings.NewReader(doc)); err != nil {
+			t.Errorf("HTML with ColumnBase %d failed: %v", tc.base, err)
+		} else if len(issues) != 1 || issues[0].Col != tc.html {
+			t.Errorf("HTML with ColumnBase %d returned %q; want column %d", tc.base, issues, tc.html)
+		}
+		if issues, err := AMP(ctx, strings.NewReader(doc)); err != nil {
+			t.Errorf("AMP with ColumnBase %d failed: %v", tc.base, err)
+		} else if len(issues) != 1 || issues[0].Col != tc.amp {
+			t.Errorf("AMP with ColumnBase %d returned %q; want column %d", tc.base, issues, tc.amp)
+		}
+		if issues, err := Lint(strings.NewReader("p{} \n"), nil); err != nil {
+			t.Errorf("Lint with ColumnBase %d failed: %v", tc.base, err)
+		} else if len(issues) != 1 || issues[0].Col != tc.lint {
+			t.Errorf("Lint with ColumnBase %d returned %q; want column %d", tc.base, issues, tc.lint)
+		}
+	}
+}
+
+func TestOptions_ProxyURL(t *testing.T) {
+	// Act as a forward proxy that answers requests itself rather than passing them on.
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		if user, pass, ok := parseProxyAuth(r); !ok || user != "user" || pass != "secret" {
+			http.Error(w, "Bad credentials", http.StatusProxyAuthRequired)
+			return
+		}
+		io.WriteString(w, nuPage(nuError(2, 3, "Bad element")))
+	}))
+	defer proxy.Close()
+
+	pu, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pu.User = url.UserPassword("user", "secret")
+	const svc = "http://validator.invalid/nu/"
+	for _, client := range []*http.Client{nil, {Transport: &http.Transport{}}} {
+		proxied = nil
+		opts := &Options{HTMLServiceURL: svc, ProxyURL: pu, Client: client}
+		issues, _, err := HTMLWithOptions(context.Background(), strings.NewReader("<!DOCTYPE html>"), opts)
+		if err != nil {
+			t.Errorf("HTMLWithOptions with client %v failed: %v", client, err)
+		} else if len(issues) != 1 {
+			t.Errorf("HTMLWithOptions with client %v returned %q; want 1 issue", client, issues)
+		}
+		if len(proxied) != 1 || proxied[0] != svc {
+			t.Errorf("Proxy with client %v got requests for %q; want %q", client, proxied, svc)
+		}
+		// The proxied client should be reused so its connections can be too.
+		if c1, c2 := opts.client(), (&Options{ProxyURL: pu, Client: client}).client(); c1 != c2 {
+			t.Errorf("Proxied client with client %v was recreated", client)
+		}
+	}
+}
+
+func TestProxyClient_Bounded(t *testing.T) {
+	pu, err := url.Parse("http://proxy.invalid:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*maxProxyClients; i++ {
+		proxyClient(&http.Client{}, pu)
+	}
+	proxyClients.Lock()
+	n := len(proxyClients.m)
+	proxyClients.Unlock()
+	if n > maxProxyClients {
+		t.Errorf("proxyClient cached %d clients; want at most %d", n, maxProxyClients)
+	}
+
+	// Clients that can't be proxied shouldn't be cached.
+	c := &http.Client{Transport: roundTripFunc(nil)}
+	if pc := proxyClient(c, pu); pc != c {
+		t.Error("proxyClient didn't return client with custom RoundTripper unchanged")
+	}
+	proxyClients.Lock()
+	_, ok := proxyClients.m[proxyClientKey{c, pu.String()}]
+	proxyClients.Unlock()
+	if ok {
+		t.Error("proxyClient cached client with custom RoundTripper")
+	}
+}
+
+// roundTripFunc is an http.RoundTripper implemented by a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestOptions_Client(t *testing.T) {
+	// Answer requests directly from the transport so no network access is needed.
+	var hosts []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		body := nuPage()
+		if strings.Contains(req.URL.Host, "jigsaw") {
+			body = jigsawPage()
+		}
+		rec := httptest.NewRecorder()
+		io.WriteString(rec, body)
+		resp := rec.Result()
+		resp.Request = req
+		return resp, nil
+	})}
+	opts := &Options{Client: client}
+	ctx := context.Background()
+	if _, _, err := HTMLWithOptions(ctx, strings.NewReader("<!DOCTYPE html>"), opts); err != nil {
+		t.Error("HTMLWithOptions failed: ", err)
+	}
+	if _, _, err := CSSWithOptions(ctx, strings.NewReader("body { color: red }"), Stylesheet, opts); err != nil {
+		t.Error("CSSWithOptions failed: ", err)
+	}
+	if want := []string{"validator.w3.org", "jigsaw.w3.org"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("Client was used for requests to %q; want %q", hosts, want)
+	}
+}
+
+// parseProxyAuth parses r's Proxy-Authorization header.
+func parseProxyAuth(r *http.Request) (user, pass string, ok bool) {
+	req := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+	return req.BasicAuth()
+}
+
+func TestIssue_JSON(t *testing.T) {
+	is := Issue{Severity: Warning, Line: 3, Col: 5, Message: "Bad thing", Code: "BAD"}
+	b, err := json.Marshal(is)
+	if err != nil {
+		t.Fatal("Marshal failed: ", err)
+	}
+	const want = `{"severity":"warning","line":3,"col":5,"message":"Bad thing","code":"BAD","context":""}`
+	if string(b) != want {
+		t.Errorf("Marshal(%+v) = %s; want %s", is, b, want)
+	}
+	var got Issue
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal(%s) failed: %v", b, err)
+	} else if !reflect.DeepEqual(got, is) {
+		t.Errorf("Unmarshal(%s) = %+v; want %+v", b, got, is)
+	}
+
+	if err := json.Unmarshal([]byte(`{"severity":"bogus"}`), &got); err == nil {
+		t.Error("Unmarshal accepted invalid severity")
+	}
+}
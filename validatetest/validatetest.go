@@ -0,0 +1,88 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+// Package validatetest contains helpers for tests that use the validate package.
+package validatetest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/derat/validate"
+)
+
+// AssertValid reports a test failure via tb if issues contains any errors.
+// Warnings are ignored.
+func AssertValid(tb testing.TB, issues []validate.Issue) {
+	tb.Helper()
+	var errs []string
+	for _, is := range issues {
+		if is.Severity == validate.Error {
+			errs = append(errs, "  "+is.String())
+		}
+	}
+	if len(errs) > 0 {
+		tb.Errorf("Got %d validation error(s):\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+}
+
+// FakeValidator is a validate.Validator that returns canned results without performing any
+// validation. It is safe for concurrent use.
+type FakeValidator struct {
+	// Issues is returned by all methods.
+	Issues []validate.Issue
+	// Page is returned as the results page by HTML and CSS.
+	Page []byte
+	// Err is returned by all methods.
+	Err error
+
+	mu   sync.Mutex
+	docs []string
+}
+
+var _ validate.Validator = (*FakeValidator)(nil)
+
+// Docs returns the documents that have been passed to fv, in order.
+func (fv *FakeValidator) Docs() []string {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	return append([]string(nil), fv.docs...)
+}
+
+// record reads and saves the document in r.
+func (fv *FakeValidator) record(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	fv.mu.Lock()
+	fv.docs = append(fv.docs, string(b))
+	fv.mu.Unlock()
+	return nil
+}
+
+func (fv *FakeValidator) HTML(ctx context.Context, r io.Reader) ([]validate.Issue, []byte, error) {
+	if err := fv.record(r); err != nil {
+		return nil, nil, err
+	}
+	return fv.Issues, fv.Page, fv.Err
+}
+
+func (fv *FakeValidator) CSS(ctx context.Context, r io.Reader, ft validate.FileType) (
+	[]validate.Issue, []byte, error) {
+	if err := fv.record(r); err != nil {
+		return nil, nil, err
+	}
+	return fv.Issues, fv.Page, fv.Err
+}
+
+func (fv *FakeValidator) AMP(ctx context.Context, r io.Reader) ([]validate.Issue, error) {
+	if err := fv.record(r); err != nil {
+		return nil, err
+	}
+	return fv.Issues, fv.Err
+}
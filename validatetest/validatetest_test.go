@@ -0,0 +1,75 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validatetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/derat/validate"
+)
+
+// fakeTB records calls to Errorf.
+type fakeTB struct {
+	testing.TB // embedded to satisfy the interface; unused methods panic
+	errors     []string
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertValid(t *testing.T) {
+	for _, tc := range []struct {
+		issues []validate.Issue
+		fail   bool
+	}{
+		{nil, false},
+		{[]validate.Issue{{Severity: validate.Warning, Line: 1, Message: "Just a warning"}}, false},
+		{[]validate.Issue{
+			{Severity: validate.Warning, Line: 1, Message: "Just a warning"},
+			{Severity: validate.Error, Line: 2, Message: "An error"},
+		}, true},
+	} {
+		var tb fakeTB
+		AssertValid(&tb, tc.issues)
+		if tc.fail {
+			if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "An error") {
+				t.Errorf("AssertValid(%q) reported %q; want single failure mentioning error", tc.issues, tb.errors)
+			}
+		} else if len(tb.errors) != 0 {
+			t.Errorf("AssertValid(%q) reported %q; want no failures", tc.issues, tb.errors)
+		}
+	}
+}
+
+// checkPage is an example of code under test that uses a validate.Validator.
+func checkPage(v validate.Validator, page string) (int, error) {
+	issues, _, err := v.HTML(context.Background(), strings.NewReader(page))
+	return len(issues), err
+}
+
+func TestFakeValidator(t *testing.T) {
+	fv := &FakeValidator{Issues: []validate.Issue{{Severity: validate.Error, Line: 1, Message: "Canned"}}}
+	const page = "<!DOCTYPE html><p>Hi"
+	if n, err := checkPage(fv, page); err != nil {
+		t.Error("checkPage failed: ", err)
+	} else if n != 1 {
+		t.Errorf("checkPage returned %v issue(s); want 1", n)
+	}
+	if docs := fv.Docs(); !reflect.DeepEqual(docs, []string{page}) {
+		t.Errorf("FakeValidator got %q; want %q", docs, []string{page})
+	}
+
+	fv.Err = errors.New("service unavailable")
+	if _, err := checkPage(fv, page); err != fv.Err {
+		t.Errorf("checkPage returned %v; want %v", err, fv.Err)
+	}
+}
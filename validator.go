@@ -0,0 +1,110 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Validator validates documents.
+//
+// Code that accepts a Validator rather than calling HTML, CSS, and AMP directly
+// can be tested without network access or amphtml-validator by substituting a fake,
+// e.g. validatetest.FakeValidator.
+type Validator interface {
+	// HTML validates an HTML document. See the HTML function.
+	HTML(ctx context.Context, r io.Reader) ([]Issue, []byte, error)
+	// CSS validates the CSS in an HTML document or stylesheet. See the CSS function.
+	CSS(ctx context.Context, r io.Reader, ft FileType) ([]Issue, []byte, error)
+	// AMP validates an AMP HTML document. See the AMP function.
+	AMP(ctx context.Context, r io.Reader) ([]Issue, error)
+}
+
+// Services is a Validator that calls HTMLWithOptions, CSSWithOptions, and AMPWithOptions.
+// The zero value is ready to use.
+type Services struct {
+	// Options is passed to the validation functions. It may be nil.
+	Options *Options
+}
+
+func (s *Services) HTML(ctx context.Context, r io.Reader) ([]Issue, []byte, error) {
+	return HTMLWithOptions(ctx, r, s.Options)
+}
+
+func (s *Services) CSS(ctx context.Context, r io.Reader, ft FileType) ([]Issue, []byte, error) {
+	return CSSWithOptions(ctx, r, ft, s.Options)
+}
+
+func (s *Services) AMP(ctx context.Context, r io.Reader) ([]Issue, error) {
+	return AMPWithOptions(ctx, r, s.Options)
+}
+
+// ValidatorSet validates documents using a fixed configuration. It's intended for long-running
+// processes (e.g. servers): the HTTP client is shared across calls, and amphtml-validator's
+// location is looked up once and cached along with any resulting error.
+// A ValidatorSet is safe for concurrent use.
+type ValidatorSet struct {
+	opts Options // copy of options supplied to NewValidatorSet
+
+	ampOnce sync.Once
+	ampPath string // path to amphtml-validator
+	ampErr  error  // error from looking up amphtml-validator
+}
+
+// NewValidatorSet returns a new ValidatorSet that passes a copy of opts (which may be nil)
+// to HTMLWithOptions, CSSWithOptions, and AMPWithOptions. Options.HTMLServiceURL,
+// Options.CSSServiceURL, and Options.AMPValidatorPath can be used to configure the validators.
+// If opts.Client is nil, a new http.Client is created and reused. If opts.ProxyURL is set,
+// a copy of the client that uses the proxy is created and reused.
+func NewValidatorSet(opts *Options) *ValidatorSet {
+	vs := &ValidatorSet{}
+	if opts != nil {
+		vs.opts = *opts
+	}
+	if vs.opts.Client == nil {
+		vs.opts.Client = &http.Client{}
+	}
+	// Create the proxied client up front so it's owned by vs instead of being cached globally.
+	if vs.opts.ProxyURL != nil {
+		vs.opts.Client = newProxyClient(vs.opts.Client, vs.opts.ProxyURL)
+		vs.opts.ProxyURL = nil
+	}
+	return vs
+}
+
+// ValidateHTML validates an HTML document. See HTMLWithOptions.
+func (vs *ValidatorSet) ValidateHTML(ctx context.Context, r io.Reader) ([]Issue, []byte, error) {
+	return HTMLWithOptions(ctx, r, &vs.opts)
+}
+
+// ValidateCSS validates the CSS in an HTML document or stylesheet. See CSSWithOptions.
+func (vs *ValidatorSet) ValidateCSS(ctx context.Context, r io.Reader, ft FileType) ([]Issue, []byte, error) {
+	return CSSWithOptions(ctx, r, ft, &vs.opts)
+}
+
+// ValidateAMP validates an AMP HTML document. See AMPWithOptions. If amphtml-validator
+// couldn't be found when it was first needed, the same error is returned by all calls.
+func (vs *ValidatorSet) ValidateAMP(ctx context.Context, r io.Reader) ([]Issue, error) {
+	p, err := vs.AMPValidatorPath()
+	if err != nil {
+		return nil, err
+	}
+	opts := vs.opts
+	opts.AMPValidatorPath = p
+	return AMPWithOptions(ctx, r, &opts)
+}
+
+// AMPValidatorPath returns the path of the amphtml-validator executable used by ValidateAMP.
+// It is looked up in $PATH on the first call if Options.AMPValidatorPath is empty.
+func (vs *ValidatorSet) AMPValidatorPath() (string, error) {
+	vs.ampOnce.Do(func() {
+		if vs.ampPath = vs.opts.AMPValidatorPath; vs.ampPath == "" {
+			vs.ampPath, vs.ampErr = lookPath("amphtml-validator")
+		}
+	})
+	return vs.ampPath, vs.ampErr
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServices(t *testing.T) {
+	fakeService(t, &htmlURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, nuPage(nuError(2, 3, "Bad element")))
+	})
+	fakeService(t, &cssURL, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, jigsawPage(jigsawRow("error", 1, "body", "Bad value")))
+	})
+	stubAMPValidator(t)
+
+	var v Validator = &Services{Options: &Options{ContextLines: 1}}
+	ctx := context.Background()
+	const doc = "<!DOCTYPE html>\n<bogus>BAD\n"
+	if issues, _, err := v.HTML(ctx, strings.NewReader(doc)); err != nil {
+		t.Error("HTML failed: ", err)
+	} else if len(issues) != 1 || issues[0].Line != 2 || len(issues[0].ContextWindow) == 0 {
+		t.Errorf("HTML returned %+v; want one issue at line 2 with context", issues)
+	}
+	if issues, _, err := v.CSS(ctx, strings.NewReader("body { color: zzz }"), Stylesheet); err != nil {
+		t.Error("CSS failed: ", err)
+	} else if len(issues) != 1 || issues[0].Line != 1 {
+		t.Errorf("CSS returned %+v; want one issue at line 1", issues)
+	}
+	if issues, err := v.AMP(ctx, strings.NewReader(doc)); err != nil {
+		t.Error("AMP failed: ", err)
+	} else if len(issues) != 1 || issues[0].Code != "BAD" {
+		t.Errorf("AMP returned %+v; want one BAD issue", issues)
+	}
+}
+
+func TestValidatorSet_ProxyURL(t *testing.T) {
+	var proxied int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		io.WriteString(w, nuPage())
+	}))
+	defer proxy.Close()
+	pu, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxyClients.Lock()
+	before := len(proxyClients.m)
+	proxyClients.Unlock()
+
+	vs := NewValidatorSet(&Options{HTMLServiceURL: "http://validator.invalid/nu/", ProxyURL: pu})
+	for i := 0; i < 2; i++ {
+		if _, _, err := vs.ValidateHTML(context.Background(), strings.NewReader("<!DOCTYPE html>")); err != nil {
+			t.Error("ValidateHTML failed: ", err)
+		}
+	}
+	if proxied != 2 {
+		t.Errorf("Proxy got %d request(s); want 2", proxied)
+	}
+	// The ValidatorSet should own its proxied client rather than adding it to the global cache.
+	proxyClients.Lock()
+	after := len(proxyClients.m)
+	proxyClients.Unlock()
+	if after != before {
+		t.Errorf("Proxied client cache grew from %d to %d", before, after)
+	}
+}
+
+func TestValidatorSet(t *testing.T) {
+	var htmlReqs int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		htmlReqs++
+		io.WriteString(w, nuPage(nuError(2, 3, "Bad element")))
+	}))
+	defer srv.Close()
+
+	exe := stubAMPValidator(t)
+	var lookups int
+	origLookPath := lookPath
+	lookPath = func(file string) (string, error) {
+		lookups++
+		return origLookPath(file)
+	}
+	defer func() { lookPath = origLookPath }()
+
+	vs := NewValidatorSet(&Options{HTMLServiceURL: srv.URL})
+	ctx := context.Background()
+	const doc = "<!DOCTYPE html>\n<bogus>BAD\n"
+	for i := 0; i < 3; i++ {
+		if issues, _, err := vs.ValidateHTML(ctx, strings.NewReader(doc)); err != nil {
+			t.Error("ValidateHTML failed: ", err)
+		} else if len(issues) != 1 || issues[0].Line != 2 {
+			t.Errorf("ValidateHTML returned %+v; want one issue at line 2", issues)
+		}
+		if issues, err := vs.ValidateAMP(ctx, strings.NewReader(doc)); err != nil {
+			t.Error("ValidateAMP failed: ", err)
+		} else if len(issues) != 1 || issues[0].Code != "BAD" {
+			t.Errorf("ValidateAMP returned %+v; want one BAD issue", issues)
+		}
+	}
+	if htmlReqs != 3 {
+		t.Errorf("HTML service got %d request(s); want 3", htmlReqs)
+	}
+	if lookups != 1 {
+		t.Errorf("amphtml-validator was looked up %d time(s); want 1", lookups)
+	}
+	if p, err := vs.AMPValidatorPath(); err != nil || p != filepath.Join(exe, "amphtml-validator") {
+		t.Errorf("AMPValidatorPath() = %q, %v; want %q", p, err, filepath.Join(exe, "amphtml-validator"))
+	}
+
+	// Lookup failures should also be cached.
+	lookups = 0
+	setEnv(t, "PATH", "")
+	vs = NewValidatorSet(nil)
+	for i := 0; i < 2; i++ {
+		if _, err := vs.ValidateAMP(ctx, strings.NewReader(doc)); err == nil {
+			t.Error("ValidateAMP unexpectedly succeeded without amphtml-validator")
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Missing amphtml-validator was looked up %d time(s); want 1", lookups)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// viewportURL documents the <meta name="viewport"> content syntax.
+const viewportURL = "https://developer.mozilla.org/en-US/docs/Web/HTML/Viewport_meta_tag"
+
+// viewportKeys maps from recognized <meta name="viewport"> content keys to functions
+// that return true if the supplied lowercase value is valid.
+var viewportKeys = map[string]func(string) bool{
+	"width":              func(v string) bool { return v == "device-width" || isViewportLength(v) },
+	"height":             func(v string) bool { return v == "device-height" || isViewportLength(v) },
+	"initial-scale":      isViewportScale,
+	"minimum-scale":      isViewportScale,
+	"maximum-scale":      isViewportScale,
+	"user-scalable":      func(v string) bool { return v == "yes" || v == "no" || v == "1" || v == "0" },
+	"viewport-fit":       func(v string) bool { return v == "auto" || v == "contain" || v == "cover" },
+	"shrink-to-fit":      func(v string) bool { return v == "yes" || v == "no" },
+	"interactive-widget": func(v string) bool { _, ok := interactiveWidgets[v]; return ok },
+}
+
+// interactiveWidgets contains valid values for the viewport's interactive-widget key.
+var interactiveWidgets = stringSet([]string{"resizes-visual", "resizes-content", "overlays-content"}, nil)
+
+// isViewportLength returns true if v is a valid viewport width or height in pixels.
+func isViewportLength(v string) bool {
+	n, err := strconv.ParseFloat(v, 64)
+	return err == nil && n >= 1 && n <= 10000
+}
+
+// isViewportScale returns true if v is a valid viewport zoom factor.
+func isViewportScale(v string) bool {
+	n, err := strconv.ParseFloat(v, 64)
+	return err == nil && n >= 0.1 && n <= 10
+}
+
+// checkViewport reports problems with <meta name="viewport"> elements' content attributes.
+func checkViewport(toks []lineToken, opts *CheckOptions) []Issue {
+	if opts.SkipViewport {
+		return nil
+	}
+	return viewportIssues(toks)
+}
+
+// viewportIssues reports unknown keys, invalid values, and duplicate keys in the content
+// attributes of <meta name="viewport"> elements. It's used by both HTMLChecks and AMPQuickCheck.
+func viewportIssues(toks []lineToken) []Issue {
+	var issues []Issue
+	add := func(sev Severity, line int, code, msg string) {
+		issues = append(issues, Issue{Severity: sev, Line: line, Message: msg, Code: code, URL: viewportURL})
+	}
+	for _, t := range toks {
+		if (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) || t.Data != "meta" {
+			continue
+		}
+		if name, _ := tokenAttr(&t.Token, "name"); !strings.EqualFold(strings.TrimSpace(name), "viewport") {
+			continue
+		}
+		content, _ := tokenAttr(&t.Token, "content")
+		seen := make(map[string]struct{})
+		for _, part := range strings.Split(content, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			kv := strings.SplitN(part, "=", 2)
+			key := strings.ToLower(strings.TrimSpace(kv[0]))
+			valid, ok := viewportKeys[key]
+			if !ok {
+				add(Warning, t.line, "unknown-viewport-key", fmt.Sprintf("Unknown viewport key %q", key))
+				continue
+			}
+			if _, ok := seen[key]; ok {
+				add(Error, t.line, "duplicate-viewport-key", fmt.Sprintf("Duplicate viewport key %q", key))
+			}
+			seen[key] = struct{}{}
+			if len(kv) < 2 {
+				add(Error, t.line, "invalid-viewport-value", fmt.Sprintf("Viewport key %q missing value", key))
+				continue
+			}
+			if val := strings.ToLower(strings.TrimSpace(kv[1])); !valid(val) {
+				add(Error, t.line, "invalid-viewport-value",
+					fmt.Sprintf("Viewport key %q has invalid value %q", key, val))
+			}
+		}
+	}
+	return issues
+}
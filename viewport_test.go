@@ -0,0 +1,49 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHTMLChecks_Viewport(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <meta name="viewport" content="width=device-width initial-scale=1">
+    <meta name="viewport" content="width=device-width, initial-scale=big, zoom=2, width=500">
+    <meta name="viewport" content="width=device-width,minimum-scale=1,initial-scale=1,">
+  </head>
+</html>
+`
+	want := []string{
+		"4 invalid-viewport-value",
+		"5 invalid-viewport-value",
+		"5 unknown-viewport-key",
+		"5 duplicate-viewport-key",
+	}
+	if got := checkIssues(t, doc, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLChecks returned %q; want %q", got, want)
+	}
+	if got := checkIssues(t, doc, &CheckOptions{SkipViewport: true}); len(got) != 0 {
+		t.Errorf("HTMLChecks with SkipViewport returned %q", got)
+	}
+}
+
+func TestAMPQuickCheck_Viewport(t *testing.T) {
+	if got := ampQuickIssues(t, minimalAMP); len(got) != 0 {
+		t.Errorf("AMPQuickCheck returned %q for minimal AMP document", got)
+	}
+	const doc = `<!doctype html>
+<html ⚡>
+  <head>
+    <meta name="viewport" content="width=device-width;initial-scale=1">
+  </head>
+</html>
+`
+	if got, want := ampQuickIssues(t, doc), []string{"4 invalid-viewport-value"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AMPQuickCheck returned %q; want %q", got, want)
+	}
+}
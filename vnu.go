@@ -0,0 +1,127 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrNoVNU is returned by HTMLLocal if the Nu HTML Checker couldn't be found.
+var ErrNoVNU = errors.New("vnu.jar not found; set $VNU_JAR or install vnu in $PATH")
+
+// HTMLLocal reads an HTML document from r and validates it using a local copy of the
+// Nu HTML Checker (the same checker that powers https://validator.w3.org/nu/), which can be
+// useful when the online service isn't reachable. If the VNU_JAR environment variable is set,
+// "java -jar $VNU_JAR" is run; otherwise, a vnu wrapper script must be present in $PATH.
+// See https://validator.github.io/validator/#usage for installation instructions.
+// If the returned error is non-nil, an issue occurred in the validation process.
+func HTMLLocal(ctx context.Context, r io.Reader) ([]Issue, error) {
+	return HTMLLocalWithOptions(ctx, r, nil)
+}
+
+// HTMLLocalWithOptions is similar to HTMLLocal but accepts additional options.
+// opts may be nil. Options that only affect requests to the validation service are ignored.
+// The checker is killed if it runs for longer than Options.AMPTimeout rather than
+// Options.ServiceTimeout, since it's a local program like amphtml-validator.
+func HTMLLocalWithOptions(ctx context.Context, r io.Reader, opts *Options) ([]Issue, error) {
+	ctx, cancel := opts.ampContext(ctx)
+	defer cancel()
+
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	in, extra, err := opts.prepareInput(in)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := runVNU(ctx, opts, bytes.NewReader(in))
+	setSource(issues, HTMLSource)
+	issues = append(issues, extra...)
+	if opts != nil && opts.Region != WholeDocument {
+		issues = filterRegion(issues, tokenizeLines(in), opts.Region)
+	}
+	opts.mapSourceLines(issues)
+	opts.addContextWindows(issues, in)
+	normalizeColumns(issues)
+	return issues, err
+}
+
+// VNUExecError is returned by HTMLLocal if the Nu HTML Checker failed without printing
+// any results, e.g. because Java couldn't be started.
+type VNUExecError struct {
+	// ExitCode is the checker's exit code.
+	ExitCode int
+	// Stderr contains the message that the checker wrote to stderr.
+	Stderr string
+}
+
+func (e *VNUExecError) Error() string {
+	return fmt.Sprintf("vnu failed with exit code %d: %s", e.ExitCode, e.Stderr)
+}
+
+// vnuCommand returns the executable and initial arguments used to run the Nu HTML Checker.
+func vnuCommand() (string, []string, error) {
+	if jar := os.Getenv("VNU_JAR"); jar != "" {
+		java, err := lookPath("java")
+		if err != nil {
+			return "", nil, err
+		}
+		return java, []string{"-jar", jar}, nil
+	}
+	exe, err := lookPath("vnu")
+	if err != nil {
+		return "", nil, ErrNoVNU
+	}
+	return exe, nil, nil
+}
+
+// runVNU runs the Nu HTML Checker on the HTML document read from stdin and parses the results.
+func runVNU(ctx context.Context, opts *Options, stdin io.Reader) ([]Issue, error) {
+	exe, args, err := vnuCommand()
+	if err != nil {
+		return nil, err
+	}
+	// The checker writes its messages to stderr unless --stdout is passed.
+	args = append(args, "--format", "json", "--stdout", "-")
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// The checker exits with 1 if it identifies errors. Only report other errors here.
+	runErr := cmd.Run()
+	if err := ctx.Err(); err != nil {
+		return nil, err // the process was killed
+	}
+	if runErr != nil {
+		exitErr, ok := runErr.(*exec.ExitError)
+		if !ok {
+			return nil, runErr
+		}
+		// Report startup failures directly rather than failing to parse empty output.
+		if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+			return nil, &VNUExecError{ExitCode: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+		}
+	}
+
+	// The checker's JSON output matches the validation service's.
+	issues, err := parseHTMLJSON(stdout.Bytes(), opts.reportHTMLInfo())
+	if err != nil {
+		return nil, err
+	}
+	if runErr != nil && !hasErrors(issues) {
+		return issues, fmt.Errorf("%v reported no errors but exited with error: %v", exe, runErr)
+	}
+	return issues, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package validate
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// vnuStubScript prints Nu HTML Checker JSON output containing an error if stdin contains "BAD",
+// a warning if it contains "WARN", and an informational message if it contains "INFO".
+// Its arguments are written to a "calls" file in its directory.
+const vnuStubScript = `
+echo "$@" >> "$(dirname "$0")/calls"
+data=$(cat)
+msgs=
+add() { msgs="$msgs${msgs:+,}$1"; }
+case "$data" in *BAD*) add '{"type":"error","lastLine":2,"lastColumn":5,"message":"Bad","extract":" <bad>"}'; fail=1 ;; esac
+case "$data" in *WARN*) add '{"type":"info","subType":"warning","lastLine":3,"lastColumn":1,"message":"Warn"}' ;; esac
+case "$data" in *INFO*) add '{"type":"info","lastLine":4,"lastColumn":1,"message":"Info"}' ;; esac
+echo "{\"messages\":[$msgs]}"
+[ -z "$fail" ]
+`
+
+func TestHTMLLocal(t *testing.T) {
+	setEnv(t, "VNU_JAR", "")
+	dir := stubCommand(t, "vnu", vnuStubScript)
+
+	ctx := context.Background()
+	issues, err := HTMLLocal(ctx, strings.NewReader("<p>\nBAD\nWARN\nINFO\n"))
+	if err != nil {
+		t.Fatal("HTMLLocal failed: ", err)
+	}
+	want := []Issue{
+		{Severity: Error, Line: 2, Col: 5, Message: "Bad", Context: "<bad>", Source: HTMLSource},
+		{Severity: Warning, Line: 3, Col: 1, Message: "Warn", Source: HTMLSource},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("HTMLLocal returned %+v; want %+v", issues, want)
+	}
+
+	issues, err = HTMLLocalWithOptions(ctx, strings.NewReader("INFO\n"), &Options{ReportHTMLInfo: true})
+	if err != nil {
+		t.Fatal("HTMLLocalWithOptions failed: ", err)
+	}
+	want = []Issue{{Severity: Info, Line: 4, Col: 1, Message: "Info", Source: HTMLSource}}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("HTMLLocalWithOptions returned %+v; want %+v", issues, want)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Split(strings.TrimSpace(string(b)), "\n")[0], "--format json --stdout -"; got != want {
+		t.Errorf("vnu was run with %q; want %q", got, want)
+	}
+}
+
+func TestHTMLLocal_Jar(t *testing.T) {
+	dir := stubCommand(t, "java", vnuStubScript)
+	setEnv(t, "VNU_JAR", "/path/to/vnu.jar")
+
+	issues, err := HTMLLocal(context.Background(), strings.NewReader("<p>ok</p>\n"))
+	if err != nil {
+		t.Fatal("HTMLLocal failed: ", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("HTMLLocal returned %v; want no issues", issues)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(b)), "-jar /path/to/vnu.jar --format json --stdout -"; got != want {
+		t.Errorf("java was run with %q; want %q", got, want)
+	}
+}
+
+func TestHTMLLocal_ExecError(t *testing.T) {
+	setEnv(t, "VNU_JAR", "")
+	stubCommand(t, "vnu", "echo 'Error: Unable to access jarfile' >&2\nexit 1\n")
+
+	_, err := HTMLLocal(context.Background(), strings.NewReader("<p>ok</p>\n"))
+	if ee, ok := err.(*VNUExecError); !ok {
+		t.Errorf("HTMLLocal returned error %v; want *VNUExecError", err)
+	} else if ee.ExitCode != 1 || ee.Stderr != "Error: Unable to access jarfile" {
+		t.Errorf("HTMLLocal returned %+v", ee)
+	}
+}